@@ -79,6 +79,7 @@ type Message struct {
 	Entities   *[]Entity `json:",omitempty"`
 	Image      *Image    `json:",omitempty"`
 	ReplyTo    struct {
+		ID         int `json:",omitempty"`
 		From       User
 		Text       string `json:",omitempty"`
 		Sent       time.Time
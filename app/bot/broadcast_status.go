@@ -18,16 +18,18 @@ const (
 
 // BroadcastParams defines parameters for broadcast detection
 type BroadcastParams struct {
-	URL          string        // URL for "ping"
-	PingInterval time.Duration // ping interval
-	DelayToOff   time.Duration // state will be switched to off in no ok replies from URL in this interval
-	Client       http.Client   // http client
+	URL          string                            // URL for "ping"
+	PingInterval time.Duration                     // ping interval
+	DelayToOff   time.Duration                     // state will be switched to off in no ok replies from URL in this interval
+	Client       http.Client                       // http client
+	OnFinished   func(started, finished time.Time) // called when broadcast finished, with its time range, optional
 }
 
 // BroadcastStatus bot replies with current broadcast status
 type BroadcastStatus struct {
-	status         bool // current broadcast status
-	lastSentStatus bool // last status sent with OnMessage
+	status         bool      // current broadcast status
+	lastSentStatus bool      // last status sent with OnMessage
+	started        time.Time // start of the current broadcast
 	statusMx       sync.Mutex
 }
 
@@ -86,7 +88,8 @@ func (b *BroadcastStatus) check(ctx context.Context, lastOn time.Time, params Br
 	if !b.status && newStatus {
 		log.Print("[INFO] Broadcast started")
 		b.status = true
-		return time.Now()
+		b.started = time.Now()
+		return b.started
 	}
 
 	// 1 -> 0
@@ -95,6 +98,9 @@ func (b *BroadcastStatus) check(ctx context.Context, lastOn time.Time, params Br
 		if b.status && lastOn.Add(params.DelayToOff).Before(time.Now()) {
 			log.Print("[INFO] Broadcast finished")
 			b.status = false
+			if params.OnFinished != nil {
+				go params.OnFinished(b.started, lastOn) // last ok reply, not the switch off time delayed by DelayToOff
+			}
 		}
 		return lastOn
 	}
@@ -124,6 +130,13 @@ func ping(ctx context.Context, client http.Client, url string) (status bool) {
 	return
 }
 
+// Started returns start of the current or the last broadcast, zero if there was no broadcast since the bot start
+func (b *BroadcastStatus) Started() time.Time {
+	b.statusMx.Lock()
+	defer b.statusMx.Unlock()
+	return b.started
+}
+
 // nolint
 func (b *BroadcastStatus) getStatus() bool {
 	b.statusMx.Lock()
@@ -61,11 +61,13 @@ func TestBroadcast_StatusTransitions(t *testing.T) {
 	}))
 	defer ts.Close()
 
+	finished := make(chan [2]time.Time, 1)
 	b := NewBroadcastStatus(ctx, BroadcastParams{
 		URL:          ts.URL,
 		PingInterval: time.Millisecond,
 		DelayToOff:   100 * time.Millisecond,
 		Client:       http.Client{},
+		OnFinished:   func(started, last time.Time) { finished <- [2]time.Time{started, last} },
 	})
 
 	// test reacts on first message
@@ -90,6 +92,16 @@ func TestBroadcast_StatusTransitions(t *testing.T) {
 	time.Sleep(110 * time.Millisecond)
 	require.Equal(t, Response{Text: MsgBroadcastFinished, Send: true, Unpin: true}, b.OnMessage(Message{}))
 	require.False(t, b.getStatus())
+	select {
+	case res := <-finished:
+		started, last := res[0], res[1]
+		require.WithinDuration(t, time.Now(), started, time.Second)
+		require.Equal(t, started, b.Started(), "start of the last broadcast kept")
+		require.True(t, last.After(started))
+		require.True(t, last.Before(time.Now().Add(-100*time.Millisecond)), "finished at the last ok reply")
+	case <-time.After(time.Second):
+		t.Fatal("finished callback not called")
+	}
 }
 
 func TestBroadcast_StatusOffToOn(t *testing.T) {
@@ -0,0 +1,214 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tbapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+//go:generate moq --out mock_show_exporter.go . ShowExporter
+//go:generate moq --out mocks/direct_sender.go --pkg mocks --skip-ensure . DirectSender:DirectSender
+
+// ShowExporter exports chat log of the show broadcast between started and finished, returns location of the result.
+// With zero finished the broadcast is found by markers in the log of the started day.
+type ShowExporter interface {
+	ExportShow(showNum int, started, finished time.Time) (string, error)
+}
+
+// DirectSender is a subset of tg api to send direct messages
+type DirectSender interface {
+	Send(c tbapi.Chattable) (tbapi.Message, error)
+}
+
+// ChatExport bot exports the show chat log when the broadcast finishes and re-exports on "export! 900 2024-03-02"
+// from superusers. Results are sent to superusers directly, their ids are learned from the chat messages,
+// as telegram doesn't allow to message a user by name. Superuser must start the bot in private chat to get messages.
+type ChatExport struct {
+	ChatExportParams
+	superUser SuperUser
+
+	mu       sync.Mutex
+	superIDs map[string]int64   // superuser name -> id, seen in the chat
+	shows    map[int]showWindow // show number -> broadcast window, for re-export without the day
+	nowFn    func() time.Time   // for testing
+}
+
+// showWindow is the time range of the show broadcast, finished is zero if unknown
+type showWindow struct {
+	started  time.Time
+	finished time.Time
+}
+
+// ChatExportParams defines dependencies and parameters of ChatExport
+type ChatExportParams struct {
+	Exporter ShowExporter
+	Sender   DirectSender
+	Client   HTTPClient
+	SiteAPI  string         // to find out the show number
+	Delay    time.Duration  // wait after the broadcast finished, for aftershow chat and logs flush
+	Location *time.Location // of the broadcast day in re-export command
+}
+
+// NewChatExport makes a bot exporting chat logs
+func NewChatExport(params ChatExportParams, superUser SuperUser) *ChatExport {
+	log.Printf("[INFO] chat export bot, delay %v, api %s", params.Delay, params.SiteAPI)
+	return &ChatExport{ChatExportParams: params, superUser: superUser, superIDs: map[string]int64{},
+		shows: map[int]showWindow{}, nowFn: time.Now}
+}
+
+// Help returns help message
+func (c *ChatExport) Help() string {
+	return GenHelpMsg(c.ReactOn(), "перевыгрузить лог выпуска: номер [2006-01-02] (только для админов)")
+}
+
+// ReactOn keys
+func (c *ChatExport) ReactOn() []string {
+	return []string{"export!", "экспорт!"}
+}
+
+// OnMessage learns superuser ids and re-exports the show on request
+func (c *ChatExport) OnMessage(msg Message) (response Response) {
+	if !c.superUser.IsSuper(msg.From.Username) {
+		return Response{}
+	}
+	c.mu.Lock()
+	c.superIDs[msg.From.Username] = msg.From.ID
+	c.mu.Unlock()
+
+	ok, req := c.request(msg.Text)
+	if !ok {
+		return Response{}
+	}
+
+	fields := strings.Fields(req)
+	num, err := 0, fmt.Errorf("no show number")
+	if len(fields) > 0 {
+		num, err = strconv.Atoi(strings.TrimPrefix(fields[0], "#"))
+	}
+	if err != nil || num <= 0 || len(fields) > 2 {
+		return Response{Text: "нужен номер выпуска и, если надо, день: export! 900 2024-03-02", Send: true, ReplyTo: msg.ID}
+	}
+
+	// known broadcast window is kept unless another day requested
+	c.mu.Lock()
+	show, known := c.shows[num]
+	c.mu.Unlock()
+	if len(fields) == 2 {
+		day, err := time.ParseInLocation("2006-01-02", fields[1], c.Location)
+		if err != nil {
+			return Response{Text: "не понимаю день, нужно 2006-01-02", Send: true, ReplyTo: msg.ID}
+		}
+		if !known || c.day(show.started) != fields[1] {
+			show = showWindow{started: day}
+		}
+	} else if !known {
+		show = showWindow{started: c.nowFn().In(c.Location)}
+	}
+
+	log.Printf("[INFO] re-export of #%d for %s requested by %+v", num, c.day(show.started), msg.From)
+	go c.export(num, show)
+	return Response{Text: fmt.Sprintf("выгружаю лог выпуска #%d за %s", num, c.day(show.started)), Send: true, ReplyTo: msg.ID}
+}
+
+// BroadcastFinished exports the show broadcast between started and finished after the delay,
+// to be called when the broadcast status changed to finished
+func (c *ChatExport) BroadcastFinished(started, finished time.Time) {
+	time.AfterFunc(c.Delay, func() {
+		num, err := c.showNum(started)
+		if err != nil {
+			log.Printf("[WARN] can't get show number, %v", err)
+			c.notify(fmt.Sprintf("не получилось выгрузить лог эфира %s, номер выпуска неизвестен: %v",
+				started.In(c.Location).Format("2006-01-02 15:04"), err))
+			return
+		}
+		c.export(num, showWindow{started: started, finished: finished})
+	})
+}
+
+func (c *ChatExport) export(num int, show showWindow) {
+	c.mu.Lock()
+	c.shows[num] = show
+	c.mu.Unlock()
+
+	res, err := c.Exporter.ExportShow(num, show.started, show.finished)
+	if err != nil {
+		log.Printf("[WARN] export of #%d failed, %v", num, err)
+		c.notify(fmt.Sprintf("не получилось выгрузить лог выпуска #%d: %v", num, err))
+		return
+	}
+	log.Printf("[INFO] show #%d exported to %s", num, res)
+	c.notify(fmt.Sprintf("лог выпуска #%d выгружен: %s\nперевыгрузить: export! %d %s", num, res, num, c.day(show.started)))
+}
+
+// day formats the day of t in the broadcast location
+func (c *ChatExport) day(t time.Time) string {
+	return t.In(c.Location).Format("2006-01-02")
+}
+
+// showNum gets the number of the show broadcast at the started time from the site api. The show post is published
+// after the broadcast, so if the last post is older than the broadcast, the show is the next one.
+func (c *ChatExport) showNum(started time.Time) (int, error) {
+	reqURL := fmt.Sprintf("%s/last/1?categories=podcast", c.SiteAPI)
+	req, err := http.NewRequest("GET", reqURL, http.NoBody)
+	if err != nil {
+		return 0, fmt.Errorf("failed to make request %s: %w", reqURL, err)
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request %s: %w", reqURL, err)
+	}
+	defer resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("request %s returned %d", reqURL, resp.StatusCode)
+	}
+
+	posts := []siteAPIResp{}
+	if err = json.NewDecoder(resp.Body).Decode(&posts); err != nil {
+		return 0, fmt.Errorf("failed to parse response from %s: %w", reqURL, err)
+	}
+	if len(posts) == 0 || posts[0].ShowNum == 0 {
+		return 0, fmt.Errorf("no shows in response from %s", reqURL)
+	}
+	if posts[0].Date.Before(started) {
+		return posts[0].ShowNum + 1, nil
+	}
+	return posts[0].ShowNum, nil
+}
+
+// notify sends message to all known superusers directly
+func (c *ChatExport) notify(text string) {
+	c.mu.Lock()
+	ids := make([]int64, 0, len(c.superIDs))
+	for _, id := range c.superIDs {
+		ids = append(ids, id)
+	}
+	c.mu.Unlock()
+
+	if len(ids) == 0 {
+		log.Printf("[WARN] no superusers seen in the chat yet, can't send %q", text)
+		return
+	}
+	for _, id := range ids {
+		msg := tbapi.NewMessage(id, text)
+		msg.DisableWebPagePreview = true
+		if _, err := c.Sender.Send(msg); err != nil {
+			log.Printf("[WARN] can't send direct message to %d, %v", id, err)
+		}
+	}
+}
+
+func (c *ChatExport) request(text string) (react bool, req string) {
+	for _, prefix := range c.ReactOn() {
+		if strings.HasPrefix(strings.ToLower(text), prefix) {
+			return true, strings.TrimSpace(text[len(prefix):])
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tbapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot/mocks"
+)
+
+func TestChatExport_OnMessage(t *testing.T) {
+	now := time.Date(2024, 3, 2, 23, 0, 0, 0, time.UTC)
+	su := &mocks.SuperUser{IsSuperFunc: func(userName string) bool { return userName == "admin" }}
+
+	var mu sync.Mutex
+	exported := make(chan string, 10)
+	exp := &ShowExporterMock{ExportShowFunc: func(showNum int, started, finished time.Time) (string, error) {
+		if showNum == 13 {
+			return "", errors.New("no logs")
+		}
+		assert.True(t, finished.IsZero(), "day export without known window")
+		return "/srv/logs/radio-t-" + started.Format("20060102") + ".html", nil
+	}}
+	var sent []tbapi.MessageConfig
+	sender := &mocks.DirectSender{SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		msg := c.(tbapi.MessageConfig)
+		sent = append(sent, msg)
+		exported <- msg.Text
+		return tbapi.Message{}, nil
+	}}
+
+	c := NewChatExport(ChatExportParams{Exporter: exp, Sender: sender, Location: time.UTC}, su)
+	c.nowFn = func() time.Time { return now }
+
+	tbl := []struct {
+		name  string
+		text  string
+		user  string
+		resp  string
+		dmRes string
+	}{
+		{"not super", "export! 900", "user", "", ""},
+		{"not a command", "привет", "admin", "", ""},
+		{"no number", "export!", "admin", "нужен номер выпуска и, если надо, день: export! 900 2024-03-02", ""},
+		{"bad number", "export! abc", "admin", "нужен номер выпуска и, если надо, день: export! 900 2024-03-02", ""},
+		{"bad day", "export! 900 02.03.2024", "admin", "не понимаю день, нужно 2006-01-02", ""},
+		{"today", "export! 900", "admin", "выгружаю лог выпуска #900 за 2024-03-02",
+			"лог выпуска #900 выгружен: /srv/logs/radio-t-20240302.html\nперевыгрузить: export! 900 2024-03-02"},
+		{"with day", "Экспорт! #901 2024-03-09", "admin", "выгружаю лог выпуска #901 за 2024-03-09",
+			"лог выпуска #901 выгружен: /srv/logs/radio-t-20240309.html\nперевыгрузить: export! 901 2024-03-09"},
+		{"remembered day", "export! 901", "admin", "выгружаю лог выпуска #901 за 2024-03-09",
+			"лог выпуска #901 выгружен: /srv/logs/radio-t-20240309.html\nперевыгрузить: export! 901 2024-03-09"},
+		{"failed", "export! 13", "admin", "выгружаю лог выпуска #13 за 2024-03-02", "не получилось выгрузить лог выпуска #13: no logs"},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := c.OnMessage(Message{ID: 5, Text: tt.text, From: User{ID: 42, Username: tt.user}})
+			if tt.resp == "" {
+				assert.Equal(t, Response{}, resp)
+				return
+			}
+			assert.Equal(t, Response{Text: tt.resp, Send: true, ReplyTo: 5}, resp)
+			if tt.dmRes == "" {
+				return
+			}
+			select {
+			case text := <-exported:
+				assert.Equal(t, tt.dmRes, text)
+			case <-time.After(time.Second):
+				t.Fatal("no direct message")
+			}
+		})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, sent, 4)
+	for _, msg := range sent {
+		assert.Equal(t, int64(42), msg.ChatID, "sent to admin only")
+	}
+}
+
+func TestChatExport_BroadcastFinished(t *testing.T) {
+	su := &mocks.SuperUser{IsSuperFunc: func(userName string) bool { return userName == "admin" }}
+	started := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	client := &mocks.HTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+		assert.Equal(t, "https://radio-t.com/site-api/last/1?categories=podcast", req.URL.String())
+		return &http.Response{StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(`[{"show_num": 899, "date": "2024-02-24T23:00:00Z"}]`))}, nil
+	}}
+	exp := &ShowExporterMock{ExportShowFunc: func(showNum int, started, finished time.Time) (string, error) {
+		return "/srv/logs/radio-t-900.html", nil
+	}}
+	dm := make(chan string, 1)
+	sender := &mocks.DirectSender{SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) {
+		dm <- c.(tbapi.MessageConfig).Text
+		return tbapi.Message{}, nil
+	}}
+
+	c := NewChatExport(ChatExportParams{Exporter: exp, Sender: sender, Client: client,
+		SiteAPI: "https://radio-t.com/site-api", Delay: 10 * time.Millisecond, Location: time.UTC}, su)
+	assert.Equal(t, Response{}, c.OnMessage(Message{Text: "hi", From: User{ID: 42, Username: "admin"}}))
+
+	finished := started.Add(2 * time.Hour)
+	c.BroadcastFinished(started, finished)
+	select {
+	case text := <-dm:
+		assert.Equal(t, "лог выпуска #900 выгружен: /srv/logs/radio-t-900.html\nперевыгрузить: export! 900 2024-03-02", text)
+	case <-time.After(time.Second):
+		t.Fatal("no direct message")
+	}
+	require.Len(t, exp.ExportShowCalls(), 1)
+	assert.Equal(t, 900, exp.ExportShowCalls()[0].ShowNum)
+	assert.Equal(t, started, exp.ExportShowCalls()[0].Started)
+	assert.Equal(t, finished, exp.ExportShowCalls()[0].Finished)
+
+	// re-export of the exported show keeps the broadcast window, another day is exported by markers
+	for i, req := range []struct{ text, day string }{
+		{"export! 900", "2024-03-02"}, {"export! 900 2024-03-02", "2024-03-02"}, {"export! 900 2024-03-03", "2024-03-03"},
+	} {
+		assert.Equal(t, Response{Text: "выгружаю лог выпуска #900 за " + req.day, Send: true},
+			c.OnMessage(Message{Text: req.text, From: User{ID: 42, Username: "admin"}}))
+		select {
+		case <-dm:
+		case <-time.After(time.Second):
+			t.Fatal("no direct message")
+		}
+		require.Len(t, exp.ExportShowCalls(), i+2)
+	}
+	calls := exp.ExportShowCalls()
+	assert.Equal(t, started, calls[1].Started)
+	assert.Equal(t, finished, calls[1].Finished)
+	assert.Equal(t, started, calls[2].Started)
+	assert.Equal(t, finished, calls[2].Finished)
+	assert.Equal(t, time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC), calls[3].Started)
+	assert.True(t, calls[3].Finished.IsZero())
+}
+
+func TestChatExport_showNum(t *testing.T) {
+	started := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	tbl := []struct {
+		name   string
+		status int
+		body   string
+		num    int
+		fail   bool
+	}{
+		{"post before broadcast", 200, `[{"show_num": 899, "date": "2024-02-24T23:00:00Z"}]`, 900, false},
+		{"post after broadcast", 200, `[{"show_num": 900, "date": "2024-03-02T23:30:00Z"}]`, 900, false},
+		{"no posts", 200, `[]`, 0, true},
+		{"bad json", 200, `{`, 0, true},
+		{"bad status", 500, ``, 0, true},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mocks.HTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(tt.body))}, nil
+			}}
+			c := NewChatExport(ChatExportParams{Client: client, SiteAPI: "http://example.com"}, &mocks.SuperUser{})
+			num, err := c.showNum(started)
+			if tt.fail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.num, num)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:generate moq --out mock_history_searcher.go . HistorySearcher
+
+// HistoryRequest is a chat history search. Zero From and To are open range.
+type HistoryRequest struct {
+	Text  string
+	User  string
+	From  time.Time // inclusive
+	To    time.Time // exclusive
+	Show  int       // show number, search around the broadcast time
+	Limit int
+}
+
+// ErrHistoryNotReady returned by HistorySearcher until the history is loaded
+var ErrHistoryNotReady = errors.New("history is not ready yet")
+
+// HistorySearcher searches chat history, returns best matches first
+type HistorySearcher interface {
+	Search(req HistoryRequest) ([]Message, error)
+}
+
+// ChatHistory bot, searches chat logs with "history! postgres @user date:2024-03-02 show:890"
+type ChatHistory struct {
+	searcher   HistorySearcher
+	group      string // public group name or numeric id, for links to messages
+	location   *time.Location
+	maxResults int
+}
+
+// maxHistoryTextLen is the max number of runes of the found message shown in response
+const maxHistoryTextLen = 100
+
+// NewChatHistory makes a bot searching chat history
+func NewChatHistory(searcher HistorySearcher, group string, location *time.Location, maxResults int) *ChatHistory {
+	log.Printf("[INFO] chat history bot, group %s, max results %d", group, maxResults)
+	return &ChatHistory{searcher: searcher, group: group, location: location, maxResults: maxResults}
+}
+
+// Help returns help message
+func (h *ChatHistory) Help() string {
+	return GenHelpMsg(h.ReactOn(), "поиск по логу чата: слова, @user, date:2006-01-02, show:номер")
+}
+
+// ReactOn keys
+func (h *ChatHistory) ReactOn() []string {
+	return []string{"history!", "лог!"}
+}
+
+// OnMessage searches chat history and responds with the top matches
+func (h *ChatHistory) OnMessage(msg Message) (response Response) {
+	ok, reqText := h.request(msg.Text)
+	if !ok {
+		return Response{}
+	}
+
+	req, err := h.parse(reqText)
+	if err != nil {
+		return Response{Text: err.Error(), Send: true, ReplyTo: msg.ID}
+	}
+
+	found, err := h.searcher.Search(req)
+	if errors.Is(err, ErrHistoryNotReady) {
+		return Response{Text: "лог еще индексируется, попробуй позже", Send: true, ReplyTo: msg.ID}
+	}
+	if err != nil {
+		log.Printf("[WARN] history search %+v failed, %v", req, err)
+		return Response{Text: "не получилось поискать в логе", Send: true, ReplyTo: msg.ID}
+	}
+	if len(found) == 0 {
+		return Response{Text: "ничего не нашел", Send: true, ReplyTo: msg.ID}
+	}
+
+	lines := make([]string, 0, len(found))
+	for _, m := range found {
+		text := []rune(strings.Join(strings.Fields(m.Text), " "))
+		if len(text) > maxHistoryTextLen {
+			text = append(text[:maxHistoryTextLen], '…')
+		}
+		author := m.From.Username
+		if author == "" {
+			author = m.From.DisplayName
+		}
+		lines = append(lines, fmt.Sprintf("● [%s %s](%s): %s", EscapeMarkDownV1Text(author),
+			m.Sent.In(h.location).Format("2006-01-02 15:04"), h.link(m.ID), EscapeMarkDownV1Text(string(text))))
+	}
+	return Response{Text: strings.Join(lines, "\n"), Send: true, ReplyTo: msg.ID}
+}
+
+// parse extracts filters from the request, all other words are the text to search
+func (h *ChatHistory) parse(reqText string) (HistoryRequest, error) {
+	req := HistoryRequest{Limit: h.maxResults}
+	var words []string
+	for _, w := range strings.Fields(reqText) {
+		switch {
+		case strings.HasPrefix(w, "@") && len(w) > 1:
+			req.User = w[1:]
+		case strings.HasPrefix(w, "date:"):
+			day, err := time.ParseInLocation("2006-01-02", strings.TrimPrefix(w, "date:"), h.location)
+			if err != nil {
+				return req, fmt.Errorf("не понимаю дату %s, нужно date:2006-01-02", EscapeMarkDownV1Text(w))
+			}
+			req.From, req.To = day, day.AddDate(0, 0, 1)
+		case strings.HasPrefix(w, "show:"):
+			num, err := strconv.Atoi(strings.TrimPrefix(w, "show:"))
+			if err != nil || num <= 0 {
+				return req, fmt.Errorf("не понимаю номер выпуска %s, нужно show:890", EscapeMarkDownV1Text(w))
+			}
+			req.Show = num
+		default:
+			words = append(words, w)
+		}
+	}
+	req.Text = strings.Join(words, " ")
+	if req.Text == "" && req.User == "" {
+		return req, fmt.Errorf("что искать? например: %s postgres @user", h.ReactOn()[0])
+	}
+	return req, nil
+}
+
+// link makes t.me link to the message, numeric supergroup ids use t.me/c/ form without -100 prefix
+func (h *ChatHistory) link(msgID int) string {
+	if id, err := strconv.ParseInt(h.group, 10, 64); err == nil {
+		return fmt.Sprintf("https://t.me/c/%s/%d", strings.TrimPrefix(strconv.FormatInt(id, 10), "-100"), msgID)
+	}
+	return fmt.Sprintf("https://t.me/%s/%d", strings.TrimPrefix(h.group, "@"), msgID)
+}
+
+func (h *ChatHistory) request(text string) (react bool, reqText string) {
+	for _, prefix := range h.ReactOn() {
+		if strings.HasPrefix(strings.ToLower(text), prefix) {
+			return true, strings.TrimSpace(text[len(prefix):])
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatHistory_OnMessage(t *testing.T) {
+	ts := time.Date(2024, 3, 2, 20, 5, 0, 0, time.UTC)
+	searcher := &HistorySearcherMock{SearchFunc: func(req HistoryRequest) ([]Message, error) {
+		switch req.Text {
+		case "fail":
+			return nil, errors.New("failed")
+		case "building":
+			return nil, ErrHistoryNotReady
+		case "postgres":
+			return []Message{
+				{ID: 101, Sent: ts, Text: "статья про  postgres\nhttps://example.com/pg_article", From: User{Username: "user_1"}},
+				{ID: 102, Sent: ts.Add(time.Hour), Text: strings.Repeat("я", 120), From: User{DisplayName: "John"}},
+			}, nil
+		}
+		return nil, nil
+	}}
+	b := NewChatHistory(searcher, "radio_t_chat", time.UTC, 5)
+
+	tbl := []struct {
+		text string
+		resp string
+	}{
+		{"blah", ""},
+		{"history!", "что искать? например: history! postgres @user"},
+		{"лог! postgres", "● [user\\_1 2024-03-02 20:05](https://t.me/radio_t_chat/101): статья про postgres " +
+			"https://example.com/pg\\_article\n● [John 2024-03-02 21:05](https://t.me/radio_t_chat/102): " +
+			strings.Repeat("я", 100) + "…"},
+		{"history! oracle", "ничего не нашел"},
+		{"history! fail", "не получилось поискать в логе"},
+		{"history! building", "лог еще индексируется, попробуй позже"},
+		{"history! oracle date:2024-13-01", "не понимаю дату date:2024-13-01, нужно date:2006-01-02"},
+		{"history! oracle show:abc", "не понимаю номер выпуска show:abc, нужно show:890"},
+	}
+	for i, tt := range tbl {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			resp := b.OnMessage(Message{ID: 10, Text: tt.text})
+			if tt.resp == "" {
+				assert.False(t, resp.Send)
+				return
+			}
+			assert.True(t, resp.Send)
+			assert.Equal(t, 10, resp.ReplyTo)
+			assert.Equal(t, tt.resp, resp.Text)
+		})
+	}
+}
+
+func TestChatHistory_parse(t *testing.T) {
+	b := NewChatHistory(nil, "radio_t_chat", time.UTC, 5)
+	req, err := b.parse("кто кидал @umputun postgres date:2024-03-02 show:890")
+	require.NoError(t, err)
+	assert.Equal(t, HistoryRequest{Text: "кто кидал postgres", User: "umputun", Show: 890, Limit: 5,
+		From: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)}, req)
+
+	req, err = b.parse("@bobuk")
+	require.NoError(t, err)
+	assert.Equal(t, HistoryRequest{User: "bobuk", Limit: 5}, req)
+}
+
+func TestChatHistory_link(t *testing.T) {
+	assert.Equal(t, "https://t.me/radio_t_chat/12", (&ChatHistory{group: "radio_t_chat"}).link(12))
+	assert.Equal(t, "https://t.me/radio_t_chat/12", (&ChatHistory{group: "@radio_t_chat"}).link(12))
+	assert.Equal(t, "https://t.me/c/1234567/12", (&ChatHistory{group: "-1001234567"}).link(12))
+}
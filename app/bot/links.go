@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+//go:generate moq --out mock_link_collector.go . LinkCollector BroadcastInfo
+
+// LinkCollector collects links shared in the chat in the time range, grouped by domain
+type LinkCollector interface {
+	Links(from, to time.Time) ([]LinkGroup, error)
+}
+
+// BroadcastInfo knows the start of the current or the last broadcast, zero if there was no broadcast
+type BroadcastInfo interface {
+	Started() time.Time
+}
+
+// LinkGroup is links of the domain shared in the chat
+type LinkGroup struct {
+	Domain string
+	Links  []SharedLink
+}
+
+// SharedLink is a link shared in the chat
+type SharedLink struct {
+	URL      string    // canonical url, without tracking params
+	Title    string    // title of the page, empty if unknown
+	SharedBy []string  // users shared the link, the first one first
+	Sent     time.Time // when the link was shared first
+}
+
+// maxBroadcastDuration limits the links of the last broadcast if it has finished long ago
+const maxBroadcastDuration = 6 * time.Hour
+
+// maxLinksLen is the max number of runes in the response, telegram message limit is 4096
+const maxLinksLen = 4000
+
+// ChatLinks bot, responds on "links!" with links shared in the chat during the current or the last broadcast
+type ChatLinks struct {
+	collector LinkCollector
+	broadcast BroadcastInfo
+	location  *time.Location
+	nowFn     func() time.Time // for testing
+}
+
+// NewChatLinks makes a bot collecting links shared in the chat
+func NewChatLinks(collector LinkCollector, broadcast BroadcastInfo, location *time.Location) *ChatLinks {
+	log.Printf("[INFO] chat links bot")
+	return &ChatLinks{collector: collector, broadcast: broadcast, location: location, nowFn: time.Now}
+}
+
+// Help returns help message
+func (c *ChatLinks) Help() string {
+	return GenHelpMsg(c.ReactOn(), "ссылки из чата за время эфира")
+}
+
+// ReactOn keys
+func (c *ChatLinks) ReactOn() []string {
+	return []string{"links!", "ссылки!"}
+}
+
+// OnMessage responds with links of the broadcast grouped by domain
+func (c *ChatLinks) OnMessage(msg Message) (response Response) {
+	if !contains(c.ReactOn(), strings.ToLower(strings.TrimSpace(msg.Text))) {
+		return Response{}
+	}
+
+	started := c.broadcast.Started()
+	if started.IsZero() {
+		return Response{Text: "эфира еще не было", Send: true, ReplyTo: msg.ID}
+	}
+	to := c.nowFn()
+	if to.Sub(started) > maxBroadcastDuration {
+		to = started.Add(maxBroadcastDuration)
+	}
+	groups, err := c.collector.Links(started, to)
+	if err != nil {
+		log.Printf("[WARN] can't collect links since %v, %v", started, err)
+		return Response{Text: "не получилось собрать ссылки", Send: true, ReplyTo: msg.ID}
+	}
+	if len(groups) == 0 {
+		return Response{Text: "ссылок за эфир не было", Send: true, ReplyTo: msg.ID}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "ссылки из чата с %s:", started.In(c.location).Format("15:04"))
+	for _, g := range groups {
+		lines := []string{"\n*" + EscapeMarkDownV1Text(g.Domain) + "*"}
+		for _, l := range g.Links {
+			title := l.Title
+			if title == "" {
+				title = l.URL
+			}
+			lines = append(lines, fmt.Sprintf("● [%s](%s) – %s", EscapeMarkDownV1Text(title), l.URL,
+				EscapeMarkDownV1Text(strings.Join(l.SharedBy, ", "))))
+		}
+		text := strings.Join(lines, "\n")
+		if len([]rune(b.String()))+len([]rune(text)) > maxLinksLen {
+			b.WriteString("\n…")
+			break
+		}
+		b.WriteString(text)
+	}
+	return Response{Text: b.String(), Send: true, ReplyTo: msg.ID}
+}
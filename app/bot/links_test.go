@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatLinks_OnMessage(t *testing.T) {
+	started := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	now := started.Add(time.Hour)
+	collector := &LinkCollectorMock{LinksFunc: func(from, to time.Time) ([]LinkGroup, error) {
+		switch {
+		case from.Equal(started.Add(-24 * time.Hour)):
+			return nil, errors.New("no logs")
+		case from.Equal(started.Add(-time.Minute)):
+			return nil, nil
+		}
+		return []LinkGroup{
+			{Domain: "github.com", Links: []SharedLink{
+				{URL: "https://github.com/radio-t/super-bot", Title: "super_bot", SharedBy: []string{"user1", "user2"}},
+				{URL: "https://github.com/umputun/remark42", SharedBy: []string{"user2"}},
+			}},
+			{Domain: "radio-t.com", Links: []SharedLink{{URL: "https://radio-t.com", Title: "Радио-Т", SharedBy: []string{"user3"}}}},
+		}, nil
+	}}
+	var start time.Time
+	broadcast := &BroadcastInfoMock{StartedFunc: func() time.Time { return start }}
+	c := NewChatLinks(collector, broadcast, time.FixedZone("MSK", 3*60*60))
+	c.nowFn = func() time.Time { return now }
+
+	tbl := []struct {
+		name    string
+		text    string
+		started time.Time
+		resp    Response
+	}{
+		{"not a command", "links", started, Response{}},
+		{"no broadcast", "links!", time.Time{}, Response{Text: "эфира еще не было", Send: true, ReplyTo: 1}},
+		{"failed", "ссылки!", started.Add(-24 * time.Hour), Response{Text: "не получилось собрать ссылки", Send: true, ReplyTo: 1}},
+		{"no links", "links!", started.Add(-time.Minute), Response{Text: "ссылок за эфир не было", Send: true, ReplyTo: 1}},
+		{"links", "Links!", started, Response{Text: "ссылки из чата с 23:00:\n*github.com*\n" +
+			"● [super\\_bot](https://github.com/radio-t/super-bot) – user1, user2\n" +
+			"● [https://github.com/umputun/remark42](https://github.com/umputun/remark42) – user2\n" +
+			"*radio-t.com*\n● [Радио-Т](https://radio-t.com) – user3", Send: true, ReplyTo: 1}},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			start = tt.started
+			assert.Equal(t, tt.resp, c.OnMessage(Message{ID: 1, Text: tt.text}))
+		})
+	}
+
+	calls := collector.LinksCalls()
+	require.Len(t, calls, 3)
+	assert.Equal(t, started, calls[2].From)
+	assert.Equal(t, now, calls[2].To)
+	assert.Equal(t, started.Add(-24*time.Hour).Add(maxBroadcastDuration), calls[0].To, "last broadcast window limited")
+}
+
+func TestChatLinks_OnMessageLimit(t *testing.T) {
+	started := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	group := LinkGroup{Domain: "example.com"}
+	for i := 0; i < 100; i++ {
+		group.Links = append(group.Links, SharedLink{URL: "https://example.com/" + strings.Repeat("x", 100)})
+	}
+	collector := &LinkCollectorMock{LinksFunc: func(from, to time.Time) ([]LinkGroup, error) {
+		return []LinkGroup{{Domain: "radio-t.com", Links: []SharedLink{{URL: "https://radio-t.com", SharedBy: []string{"user"}}}}, group}, nil
+	}}
+	c := NewChatLinks(collector, &BroadcastInfoMock{StartedFunc: func() time.Time { return started }}, time.UTC)
+	resp := c.OnMessage(Message{Text: "links!"})
+	assert.True(t, strings.HasSuffix(resp.Text, "radio-t.com) – user\n…"), "domain not fitting into message skipped")
+	assert.LessOrEqual(t, len([]rune(resp.Text)), maxLinksLen)
+}
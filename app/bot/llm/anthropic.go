@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	anthropicAPI       = "https://api.anthropic.com/v1"
+	anthropicVersion   = "2023-06-01"
+	anthropicMaxTokens = 1024 // max_tokens is required by the messages API
+)
+
+// AnthropicParams for Anthropic API
+type AnthropicParams struct {
+	AuthToken  string
+	BaseURL    string // Anthropic API if empty
+	Model      string
+	HTTPClient *http.Client
+}
+
+// Anthropic makes chat completions with Anthropic messages API, implements Provider
+type Anthropic struct {
+	client  *http.Client
+	baseURL string
+	token   string
+	model   string
+}
+
+// NewAnthropic makes provider for Anthropic API
+func NewAnthropic(params AnthropicParams) *Anthropic {
+	log.Printf("[INFO] Anthropic provider, model=%s, base url=%q", params.Model, params.BaseURL)
+	res := &Anthropic{client: params.HTTPClient, baseURL: params.BaseURL, token: params.AuthToken, model: params.Model}
+	if res.client == nil {
+		res.client = http.DefaultClient
+	}
+	if res.baseURL == "" {
+		res.baseURL = anthropicAPI
+	}
+	res.baseURL = strings.TrimSuffix(res.baseURL, "/")
+	return res
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Complete makes chat completion, text blocks of the response joined
+func (a *Anthropic) Complete(ctx context.Context, req Request) (string, error) {
+	body, err := json.Marshal(a.request(req))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal anthropic request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to make anthropic request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Api-Key", a.token)
+	httpReq.Header.Set("Anthropic-Version", anthropicVersion)
+
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("anthropic request failed: %w", err)
+	}
+	defer resp.Body.Close() // nolint
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read anthropic response: %w", err)
+	}
+	res := anthropicResponse{}
+	if err = json.Unmarshal(data, &res); err != nil {
+		return "", fmt.Errorf("failed to parse anthropic response, status %d: %w", resp.StatusCode, err)
+	}
+	if res.Error != nil {
+		return "", fmt.Errorf("anthropic error, status %d: %s: %s", resp.StatusCode, res.Error.Type, res.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("anthropic request failed with status %d", resp.StatusCode)
+	}
+
+	var text []string
+	for _, c := range res.Content {
+		if c.Type == "text" {
+			text = append(text, c.Text)
+		}
+	}
+	if len(text) == 0 {
+		return "", errors.New("no text in response")
+	}
+	return strings.Join(text, ""), nil
+}
+
+// request converts chat to messages API format: system messages moved to the system prompt,
+// consecutive messages of the same role joined, as the API expects user and assistant turns alternating
+func (a *Anthropic) request(req Request) anthropicRequest {
+	res := anthropicRequest{Model: a.model, MaxTokens: req.MaxTokens, Messages: []anthropicMessage{}}
+	if res.MaxTokens <= 0 {
+		res.MaxTokens = anthropicMaxTokens
+	}
+	var system []string
+	for _, m := range req.Messages {
+		if m.Role == RoleSystem {
+			system = append(system, m.Content)
+			continue
+		}
+		if n := len(res.Messages); n > 0 && res.Messages[n-1].Role == m.Role {
+			res.Messages[n-1].Content += "\n\n" + m.Content
+			continue
+		}
+		res.Messages = append(res.Messages, anthropicMessage{Role: m.Role, Content: m.Content})
+	}
+	res.System = strings.Join(system, "\n\n")
+	return res
+}
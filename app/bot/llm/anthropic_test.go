@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnthropic_Complete(t *testing.T) {
+	var req anthropicRequest
+	var headers http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/messages", r.URL.Path)
+		headers = r.Header
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch req.Messages[len(req.Messages)-1].Content {
+		case "no text":
+			_, _ = w.Write([]byte(`{"content": [{"type": "tool_use"}]}`))
+		case "overloaded":
+			w.WriteHeader(529)
+			_, _ = w.Write([]byte(`{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`))
+		case "bad gateway":
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`{}`))
+		case "not json":
+			_, _ = w.Write([]byte(`<html>`))
+		default:
+			_, _ = w.Write([]byte(`{"type": "message", "role": "assistant",
+				"content": [{"type": "text", "text": "Mock "}, {"type": "text", "text": "response"}]}`))
+		}
+	}))
+	defer ts.Close()
+
+	a := NewAnthropic(AnthropicParams{AuthToken: "token", BaseURL: ts.URL + "/v1/", Model: "claude", HTTPClient: ts.Client()})
+	resp, err := a.Complete(context.Background(), Request{Messages: []Message{
+		{Role: RoleSystem, Content: "be short"}, {Role: RoleUser, Content: "message 1"}, {Role: RoleUser, Content: "message 2"},
+		{Role: RoleAssistant, Content: "answer"}, {Role: RoleUser, Content: "question"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "Mock response", resp)
+	assert.Equal(t, "token", headers.Get("x-api-key"))
+	assert.Equal(t, anthropicVersion, headers.Get("anthropic-version"))
+	assert.Equal(t, anthropicRequest{Model: "claude", MaxTokens: anthropicMaxTokens, System: "be short", Messages: []anthropicMessage{
+		{Role: "user", Content: "message 1\n\nmessage 2"}, {Role: "assistant", Content: "answer"}, {Role: "user", Content: "question"},
+	}}, req)
+
+	_, err = a.Complete(context.Background(), Request{MaxTokens: 10, Messages: []Message{{Role: RoleUser, Content: "no text"}}})
+	assert.EqualError(t, err, "no text in response")
+	assert.Equal(t, 10, req.MaxTokens)
+
+	_, err = a.Complete(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "overloaded"}}})
+	assert.EqualError(t, err, "anthropic error, status 529: overloaded_error: Overloaded")
+
+	_, err = a.Complete(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "bad gateway"}}})
+	assert.EqualError(t, err, "anthropic request failed with status 502")
+
+	_, err = a.Complete(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "not json"}}})
+	assert.ErrorContains(t, err, "failed to parse anthropic response")
+}
+
+func TestNewAnthropic(t *testing.T) {
+	a := NewAnthropic(AnthropicParams{Model: "claude"})
+	assert.Equal(t, anthropicAPI, a.baseURL)
+	assert.Equal(t, http.DefaultClient, a.client)
+}
@@ -0,0 +1,58 @@
+// Package llm makes chat completions with LLM APIs of different providers: OpenAI, OpenAI-compatible servers,
+// i.e. ollama or llama.cpp, and Anthropic. Requests are kept in the model limits with the tokenizer of the model.
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	tokenizer "github.com/sandwich-go/gpt3-encoder"
+)
+
+//go:generate moq --out mocks/provider.go --pkg mocks --skip-ensure . Provider:Provider
+
+// Provider makes chat completion with the model of LLM API
+type Provider interface {
+	Complete(ctx context.Context, req Request) (string, error)
+}
+
+// roles of the chat messages
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is a message of the chat with the model
+type Message struct {
+	Role    string
+	Content string
+}
+
+// Request is a chat completion request, the model is set by the provider
+type Request struct {
+	Messages  []Message
+	MaxTokens int // limit of tokens in the response, provider default if zero
+}
+
+// Tokenizer splits text into tokens of the model, i.e. *gpt3encoder.Encoder
+type Tokenizer interface {
+	Encode(text string) ([]int, error)
+	Decode(tokens []int) string
+}
+
+// NewTokenizer makes tokenizer by name, "gpt3" for OpenAI models.
+// Returns nil for "none", requests are limited by symbols then.
+func NewTokenizer(name string) (Tokenizer, error) {
+	switch name {
+	case "gpt3":
+		enc, err := tokenizer.NewEncoder()
+		if err != nil {
+			return nil, fmt.Errorf("can't init gpt3 tokenizer: %w", err)
+		}
+		return enc, nil
+	case "", "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown tokenizer %q", name)
+}
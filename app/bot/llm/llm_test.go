@@ -0,0 +1,26 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTokenizer(t *testing.T) {
+	tok, err := NewTokenizer("gpt3")
+	require.NoError(t, err)
+	tokens, err := tok.Encode("hello world")
+	require.NoError(t, err)
+	assert.Len(t, tokens, 2)
+	assert.Equal(t, "hello", tok.Decode(tokens[:1]))
+
+	for _, name := range []string{"", "none"} {
+		tok, err = NewTokenizer(name)
+		require.NoError(t, err)
+		assert.Nil(t, tok)
+	}
+
+	_, err = NewTokenizer("llama")
+	assert.Error(t, err)
+}
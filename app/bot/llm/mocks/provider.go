@@ -0,0 +1,78 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"github.com/radio-t/super-bot/app/bot/llm"
+	"sync"
+)
+
+// Provider is a mock implementation of llm.Provider.
+//
+//	func TestSomethingThatUsesProvider(t *testing.T) {
+//
+//		// make and configure a mocked llm.Provider
+//		mockedProvider := &Provider{
+//			CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
+//				panic("mock out the Complete method")
+//			},
+//		}
+//
+//		// use mockedProvider in code that requires llm.Provider
+//		// and then make assertions.
+//
+//	}
+type Provider struct {
+	// CompleteFunc mocks the Complete method.
+	CompleteFunc func(ctx context.Context, req llm.Request) (string, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Complete holds details about calls to the Complete method.
+		Complete []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req llm.Request
+		}
+	}
+	lockComplete sync.RWMutex
+}
+
+// Complete calls CompleteFunc.
+func (mock *Provider) Complete(ctx context.Context, req llm.Request) (string, error) {
+	if mock.CompleteFunc == nil {
+		panic("Provider.CompleteFunc: method is nil but Provider.Complete was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req llm.Request
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockComplete.Lock()
+	mock.calls.Complete = append(mock.calls.Complete, callInfo)
+	mock.lockComplete.Unlock()
+	return mock.CompleteFunc(ctx, req)
+}
+
+// CompleteCalls gets all the calls that were made to Complete.
+// Check the length with:
+//
+//	len(mockedProvider.CompleteCalls())
+func (mock *Provider) CompleteCalls() []struct {
+	Ctx context.Context
+	Req llm.Request
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req llm.Request
+	}
+	mock.lockComplete.RLock()
+	calls = mock.calls.Complete
+	mock.lockComplete.RUnlock()
+	return calls
+}
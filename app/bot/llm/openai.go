@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAIParams for OpenAI API or OpenAI-compatible server
+type OpenAIParams struct {
+	AuthToken  string
+	BaseURL    string // OpenAI-compatible API, i.e. http://localhost:11434/v1 for ollama, OpenAI API if empty
+	Model      string
+	HTTPClient *http.Client
+}
+
+// OpenAI makes chat completions with OpenAI API or OpenAI-compatible server, implements Provider
+type OpenAI struct {
+	client *openai.Client
+	model  string
+}
+
+// NewOpenAI makes provider for OpenAI API or OpenAI-compatible server
+func NewOpenAI(params OpenAIParams) *OpenAI {
+	log.Printf("[INFO] OpenAI provider with github.com/sashabaranov/go-openai, model=%s, base url=%q", params.Model, params.BaseURL)
+	config := openai.DefaultConfig(params.AuthToken)
+	if params.BaseURL != "" {
+		config.BaseURL = params.BaseURL
+	}
+	if params.HTTPClient != nil {
+		config.HTTPClient = params.HTTPClient
+	}
+	return &OpenAI{client: openai.NewClientWithConfig(config), model: params.Model}
+}
+
+// Complete makes chat completion, the first choice returned
+func (o *OpenAI) Complete(ctx context.Context, req Request) (string, error) {
+	messages := make([]openai.ChatCompletionMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		messages = append(messages, openai.ChatCompletionMessage{Role: m.Role, Content: m.Content})
+	}
+	resp, err := o.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:     o.model,
+		MaxTokens: req.MaxTokens,
+		Messages:  messages,
+	})
+	if err != nil {
+		return "", fmt.Errorf("openai chat completion failed: %w", err)
+	}
+	// openAI platform supports to return multiple chat completion choices, but we use only the first one
+	// https://platform.openai.com/docs/api-reference/chat/create#chat/create-n
+	if len(resp.Choices) == 0 {
+		return "", errors.New("no choices in response")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAI_Complete(t *testing.T) {
+	var req struct {
+		Model     string    `json:"model"`
+		MaxTokens int       `json:"max_tokens"`
+		Messages  []Message `json:"messages"`
+	}
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/chat/completions", r.URL.Path)
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch req.Messages[len(req.Messages)-1].Content {
+		case "no choices":
+			_, _ = w.Write([]byte(`{"choices": []}`))
+		case "fail":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": {"message": "bad request", "type": "invalid_request_error"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"choices": [{"index": 0, "message": {"role": "assistant", "content": "Mock response"}}]}`))
+		}
+	}))
+	defer ts.Close()
+
+	// any OpenAI-compatible server, i.e. ollama
+	o := NewOpenAI(OpenAIParams{AuthToken: "token", BaseURL: ts.URL + "/v1", Model: "llama3", HTTPClient: ts.Client()})
+	resp, err := o.Complete(context.Background(), Request{MaxTokens: 100,
+		Messages: []Message{{Role: RoleSystem, Content: "be short"}, {Role: RoleUser, Content: "hi"}}})
+	require.NoError(t, err)
+	assert.Equal(t, "Mock response", resp)
+	assert.Equal(t, "Bearer token", auth)
+	assert.Equal(t, "llama3", req.Model)
+	assert.Equal(t, 100, req.MaxTokens)
+	assert.Equal(t, []Message{{Role: "system", Content: "be short"}, {Role: "user", Content: "hi"}}, req.Messages)
+
+	_, err = o.Complete(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "no choices"}}})
+	assert.EqualError(t, err, "no choices in response")
+
+	_, err = o.Complete(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "fail"}}})
+	assert.ErrorContains(t, err, "bad request")
+}
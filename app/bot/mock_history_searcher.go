@@ -0,0 +1,74 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package bot
+
+import (
+	"sync"
+)
+
+// Ensure, that HistorySearcherMock does implement HistorySearcher.
+// If this is not the case, regenerate this file with moq.
+var _ HistorySearcher = &HistorySearcherMock{}
+
+// HistorySearcherMock is a mock implementation of HistorySearcher.
+//
+//	func TestSomethingThatUsesHistorySearcher(t *testing.T) {
+//
+//		// make and configure a mocked HistorySearcher
+//		mockedHistorySearcher := &HistorySearcherMock{
+//			SearchFunc: func(req HistoryRequest) ([]Message, error) {
+//				panic("mock out the Search method")
+//			},
+//		}
+//
+//		// use mockedHistorySearcher in code that requires HistorySearcher
+//		// and then make assertions.
+//
+//	}
+type HistorySearcherMock struct {
+	// SearchFunc mocks the Search method.
+	SearchFunc func(req HistoryRequest) ([]Message, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Search holds details about calls to the Search method.
+		Search []struct {
+			// Req is the req argument value.
+			Req HistoryRequest
+		}
+	}
+	lockSearch sync.RWMutex
+}
+
+// Search calls SearchFunc.
+func (mock *HistorySearcherMock) Search(req HistoryRequest) ([]Message, error) {
+	if mock.SearchFunc == nil {
+		panic("HistorySearcherMock.SearchFunc: method is nil but HistorySearcher.Search was just called")
+	}
+	callInfo := struct {
+		Req HistoryRequest
+	}{
+		Req: req,
+	}
+	mock.lockSearch.Lock()
+	mock.calls.Search = append(mock.calls.Search, callInfo)
+	mock.lockSearch.Unlock()
+	return mock.SearchFunc(req)
+}
+
+// SearchCalls gets all the calls that were made to Search.
+// Check the length with:
+//
+//	len(mockedHistorySearcher.SearchCalls())
+func (mock *HistorySearcherMock) SearchCalls() []struct {
+	Req HistoryRequest
+} {
+	var calls []struct {
+		Req HistoryRequest
+	}
+	mock.lockSearch.RLock()
+	calls = mock.calls.Search
+	mock.lockSearch.RUnlock()
+	return calls
+}
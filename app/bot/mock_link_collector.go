@@ -0,0 +1,140 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+// Ensure, that LinkCollectorMock does implement LinkCollector.
+// If this is not the case, regenerate this file with moq.
+var _ LinkCollector = &LinkCollectorMock{}
+
+// LinkCollectorMock is a mock implementation of LinkCollector.
+//
+//	func TestSomethingThatUsesLinkCollector(t *testing.T) {
+//
+//		// make and configure a mocked LinkCollector
+//		mockedLinkCollector := &LinkCollectorMock{
+//			LinksFunc: func(from time.Time, to time.Time) ([]LinkGroup, error) {
+//				panic("mock out the Links method")
+//			},
+//		}
+//
+//		// use mockedLinkCollector in code that requires LinkCollector
+//		// and then make assertions.
+//
+//	}
+type LinkCollectorMock struct {
+	// LinksFunc mocks the Links method.
+	LinksFunc func(from time.Time, to time.Time) ([]LinkGroup, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Links holds details about calls to the Links method.
+		Links []struct {
+			// From is the from argument value.
+			From time.Time
+			// To is the to argument value.
+			To time.Time
+		}
+	}
+	lockLinks sync.RWMutex
+}
+
+// Links calls LinksFunc.
+func (mock *LinkCollectorMock) Links(from time.Time, to time.Time) ([]LinkGroup, error) {
+	if mock.LinksFunc == nil {
+		panic("LinkCollectorMock.LinksFunc: method is nil but LinkCollector.Links was just called")
+	}
+	callInfo := struct {
+		From time.Time
+		To   time.Time
+	}{
+		From: from,
+		To:   to,
+	}
+	mock.lockLinks.Lock()
+	mock.calls.Links = append(mock.calls.Links, callInfo)
+	mock.lockLinks.Unlock()
+	return mock.LinksFunc(from, to)
+}
+
+// LinksCalls gets all the calls that were made to Links.
+// Check the length with:
+//
+//	len(mockedLinkCollector.LinksCalls())
+func (mock *LinkCollectorMock) LinksCalls() []struct {
+	From time.Time
+	To   time.Time
+} {
+	var calls []struct {
+		From time.Time
+		To   time.Time
+	}
+	mock.lockLinks.RLock()
+	calls = mock.calls.Links
+	mock.lockLinks.RUnlock()
+	return calls
+}
+
+// Ensure, that BroadcastInfoMock does implement BroadcastInfo.
+// If this is not the case, regenerate this file with moq.
+var _ BroadcastInfo = &BroadcastInfoMock{}
+
+// BroadcastInfoMock is a mock implementation of BroadcastInfo.
+//
+//	func TestSomethingThatUsesBroadcastInfo(t *testing.T) {
+//
+//		// make and configure a mocked BroadcastInfo
+//		mockedBroadcastInfo := &BroadcastInfoMock{
+//			StartedFunc: func() time.Time {
+//				panic("mock out the Started method")
+//			},
+//		}
+//
+//		// use mockedBroadcastInfo in code that requires BroadcastInfo
+//		// and then make assertions.
+//
+//	}
+type BroadcastInfoMock struct {
+	// StartedFunc mocks the Started method.
+	StartedFunc func() time.Time
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Started holds details about calls to the Started method.
+		Started []struct {
+		}
+	}
+	lockStarted sync.RWMutex
+}
+
+// Started calls StartedFunc.
+func (mock *BroadcastInfoMock) Started() time.Time {
+	if mock.StartedFunc == nil {
+		panic("BroadcastInfoMock.StartedFunc: method is nil but BroadcastInfo.Started was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockStarted.Lock()
+	mock.calls.Started = append(mock.calls.Started, callInfo)
+	mock.lockStarted.Unlock()
+	return mock.StartedFunc()
+}
+
+// StartedCalls gets all the calls that were made to Started.
+// Check the length with:
+//
+//	len(mockedBroadcastInfo.StartedCalls())
+func (mock *BroadcastInfoMock) StartedCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockStarted.RLock()
+	calls = mock.calls.Started
+	mock.lockStarted.RUnlock()
+	return calls
+}
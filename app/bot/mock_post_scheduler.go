@@ -0,0 +1,155 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package bot
+
+import (
+	"sync"
+)
+
+// Ensure, that PostSchedulerMock does implement PostScheduler.
+// If this is not the case, regenerate this file with moq.
+var _ PostScheduler = &PostSchedulerMock{}
+
+// PostSchedulerMock is a mock implementation of PostScheduler.
+//
+//	func TestSomethingThatUsesPostScheduler(t *testing.T) {
+//
+//		// make and configure a mocked PostScheduler
+//		mockedPostScheduler := &PostSchedulerMock{
+//			CancelFunc: func(id string) error {
+//				panic("mock out the Cancel method")
+//			},
+//			ScheduleFunc: func(post ScheduledPost) (string, error) {
+//				panic("mock out the Schedule method")
+//			},
+//			ScheduledFunc: func() []ScheduledPost {
+//				panic("mock out the Scheduled method")
+//			},
+//		}
+//
+//		// use mockedPostScheduler in code that requires PostScheduler
+//		// and then make assertions.
+//
+//	}
+type PostSchedulerMock struct {
+	// CancelFunc mocks the Cancel method.
+	CancelFunc func(id string) error
+
+	// ScheduleFunc mocks the Schedule method.
+	ScheduleFunc func(post ScheduledPost) (string, error)
+
+	// ScheduledFunc mocks the Scheduled method.
+	ScheduledFunc func() []ScheduledPost
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Cancel holds details about calls to the Cancel method.
+		Cancel []struct {
+			// ID is the id argument value.
+			ID string
+		}
+		// Schedule holds details about calls to the Schedule method.
+		Schedule []struct {
+			// Post is the post argument value.
+			Post ScheduledPost
+		}
+		// Scheduled holds details about calls to the Scheduled method.
+		Scheduled []struct {
+		}
+	}
+	lockCancel    sync.RWMutex
+	lockSchedule  sync.RWMutex
+	lockScheduled sync.RWMutex
+}
+
+// Cancel calls CancelFunc.
+func (mock *PostSchedulerMock) Cancel(id string) error {
+	if mock.CancelFunc == nil {
+		panic("PostSchedulerMock.CancelFunc: method is nil but PostScheduler.Cancel was just called")
+	}
+	callInfo := struct {
+		ID string
+	}{
+		ID: id,
+	}
+	mock.lockCancel.Lock()
+	mock.calls.Cancel = append(mock.calls.Cancel, callInfo)
+	mock.lockCancel.Unlock()
+	return mock.CancelFunc(id)
+}
+
+// CancelCalls gets all the calls that were made to Cancel.
+// Check the length with:
+//
+//	len(mockedPostScheduler.CancelCalls())
+func (mock *PostSchedulerMock) CancelCalls() []struct {
+	ID string
+} {
+	var calls []struct {
+		ID string
+	}
+	mock.lockCancel.RLock()
+	calls = mock.calls.Cancel
+	mock.lockCancel.RUnlock()
+	return calls
+}
+
+// Schedule calls ScheduleFunc.
+func (mock *PostSchedulerMock) Schedule(post ScheduledPost) (string, error) {
+	if mock.ScheduleFunc == nil {
+		panic("PostSchedulerMock.ScheduleFunc: method is nil but PostScheduler.Schedule was just called")
+	}
+	callInfo := struct {
+		Post ScheduledPost
+	}{
+		Post: post,
+	}
+	mock.lockSchedule.Lock()
+	mock.calls.Schedule = append(mock.calls.Schedule, callInfo)
+	mock.lockSchedule.Unlock()
+	return mock.ScheduleFunc(post)
+}
+
+// ScheduleCalls gets all the calls that were made to Schedule.
+// Check the length with:
+//
+//	len(mockedPostScheduler.ScheduleCalls())
+func (mock *PostSchedulerMock) ScheduleCalls() []struct {
+	Post ScheduledPost
+} {
+	var calls []struct {
+		Post ScheduledPost
+	}
+	mock.lockSchedule.RLock()
+	calls = mock.calls.Schedule
+	mock.lockSchedule.RUnlock()
+	return calls
+}
+
+// Scheduled calls ScheduledFunc.
+func (mock *PostSchedulerMock) Scheduled() []ScheduledPost {
+	if mock.ScheduledFunc == nil {
+		panic("PostSchedulerMock.ScheduledFunc: method is nil but PostScheduler.Scheduled was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockScheduled.Lock()
+	mock.calls.Scheduled = append(mock.calls.Scheduled, callInfo)
+	mock.lockScheduled.Unlock()
+	return mock.ScheduledFunc()
+}
+
+// ScheduledCalls gets all the calls that were made to Scheduled.
+// Check the length with:
+//
+//	len(mockedPostScheduler.ScheduledCalls())
+func (mock *PostSchedulerMock) ScheduledCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockScheduled.RLock()
+	calls = mock.calls.Scheduled
+	mock.lockScheduled.RUnlock()
+	return calls
+}
@@ -0,0 +1,87 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+// Ensure, that ShowExporterMock does implement ShowExporter.
+// If this is not the case, regenerate this file with moq.
+var _ ShowExporter = &ShowExporterMock{}
+
+// ShowExporterMock is a mock implementation of ShowExporter.
+//
+//	func TestSomethingThatUsesShowExporter(t *testing.T) {
+//
+//		// make and configure a mocked ShowExporter
+//		mockedShowExporter := &ShowExporterMock{
+//			ExportShowFunc: func(showNum int, started time.Time, finished time.Time) (string, error) {
+//				panic("mock out the ExportShow method")
+//			},
+//		}
+//
+//		// use mockedShowExporter in code that requires ShowExporter
+//		// and then make assertions.
+//
+//	}
+type ShowExporterMock struct {
+	// ExportShowFunc mocks the ExportShow method.
+	ExportShowFunc func(showNum int, started time.Time, finished time.Time) (string, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// ExportShow holds details about calls to the ExportShow method.
+		ExportShow []struct {
+			// ShowNum is the showNum argument value.
+			ShowNum int
+			// Started is the started argument value.
+			Started time.Time
+			// Finished is the finished argument value.
+			Finished time.Time
+		}
+	}
+	lockExportShow sync.RWMutex
+}
+
+// ExportShow calls ExportShowFunc.
+func (mock *ShowExporterMock) ExportShow(showNum int, started time.Time, finished time.Time) (string, error) {
+	if mock.ExportShowFunc == nil {
+		panic("ShowExporterMock.ExportShowFunc: method is nil but ShowExporter.ExportShow was just called")
+	}
+	callInfo := struct {
+		ShowNum  int
+		Started  time.Time
+		Finished time.Time
+	}{
+		ShowNum:  showNum,
+		Started:  started,
+		Finished: finished,
+	}
+	mock.lockExportShow.Lock()
+	mock.calls.ExportShow = append(mock.calls.ExportShow, callInfo)
+	mock.lockExportShow.Unlock()
+	return mock.ExportShowFunc(showNum, started, finished)
+}
+
+// ExportShowCalls gets all the calls that were made to ExportShow.
+// Check the length with:
+//
+//	len(mockedShowExporter.ExportShowCalls())
+func (mock *ShowExporterMock) ExportShowCalls() []struct {
+	ShowNum  int
+	Started  time.Time
+	Finished time.Time
+} {
+	var calls []struct {
+		ShowNum  int
+		Started  time.Time
+		Finished time.Time
+	}
+	mock.lockExportShow.RLock()
+	calls = mock.calls.ExportShow
+	mock.lockExportShow.RUnlock()
+	return calls
+}
@@ -0,0 +1,71 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	tbapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"sync"
+)
+
+// DirectSender is a mock implementation of bot.DirectSender.
+//
+//	func TestSomethingThatUsesDirectSender(t *testing.T) {
+//
+//		// make and configure a mocked bot.DirectSender
+//		mockedDirectSender := &DirectSender{
+//			SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) {
+//				panic("mock out the Send method")
+//			},
+//		}
+//
+//		// use mockedDirectSender in code that requires bot.DirectSender
+//		// and then make assertions.
+//
+//	}
+type DirectSender struct {
+	// SendFunc mocks the Send method.
+	SendFunc func(c tbapi.Chattable) (tbapi.Message, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Send holds details about calls to the Send method.
+		Send []struct {
+			// C is the c argument value.
+			C tbapi.Chattable
+		}
+	}
+	lockSend sync.RWMutex
+}
+
+// Send calls SendFunc.
+func (mock *DirectSender) Send(c tbapi.Chattable) (tbapi.Message, error) {
+	if mock.SendFunc == nil {
+		panic("DirectSender.SendFunc: method is nil but DirectSender.Send was just called")
+	}
+	callInfo := struct {
+		C tbapi.Chattable
+	}{
+		C: c,
+	}
+	mock.lockSend.Lock()
+	mock.calls.Send = append(mock.calls.Send, callInfo)
+	mock.lockSend.Unlock()
+	return mock.SendFunc(c)
+}
+
+// SendCalls gets all the calls that were made to Send.
+// Check the length with:
+//
+//	len(mockedDirectSender.SendCalls())
+func (mock *DirectSender) SendCalls() []struct {
+	C tbapi.Chattable
+} {
+	var calls []struct {
+		C tbapi.Chattable
+	}
+	mock.lockSend.RLock()
+	calls = mock.calls.Send
+	mock.lockSend.RUnlock()
+	return calls
+}
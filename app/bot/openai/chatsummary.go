@@ -0,0 +1,106 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const chatChunkPrompt = "You are given a part of the live chat transcript of the Radio-T podcast, one message per line " +
+	"in the format 'time name: text'. Write short notes, up to 150 words, about what the listeners discussed, " +
+	"in russian. Skip greetings and jokes without context."
+
+const chatSummaryPrompt = "You are given the live chat of the Radio-T podcast, as a transcript or notes about its parts. " +
+	"Describe what the listeners talked about. Answer in russian with JSON object only, without markdown: " +
+	`{"summary": "short summary, up to 50 words", "threads": ["main discussion thread, up to 15 words each, up to 7 in total"]}`
+
+// ChatSummary makes a short summary of the chat transcript and the list of its main discussion threads.
+// Long transcript is split into chunks fitting the request limit, each chunk summarized separately
+// and the summary made from the notes of the chunks.
+func (o *OpenAI) ChatSummary(lines []string) (summary string, threads []string, err error) {
+	chunks := o.splitChunks(lines)
+	if len(chunks) == 0 {
+		return "", nil, fmt.Errorf("empty transcript")
+	}
+
+	text := chunks[0]
+	if len(chunks) > 1 {
+		notes := make([]string, 0, len(chunks))
+		for i, chunk := range chunks {
+			note, err := o.chatGPTRequest(chunk, "", chatChunkPrompt)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to summarize chunk %d of %d: %w", i+1, len(chunks), err)
+			}
+			notes = append(notes, strings.TrimSpace(note))
+		}
+		text = strings.Join(notes, "\n\n")
+	}
+
+	resp, err := o.chatGPTRequest(text, "", chatSummaryPrompt)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to summarize chat: %w", err)
+	}
+	return parseChatSummary(resp)
+}
+
+// splitChunks joins lines into chunks up to MaxTokensRequest tokens, or MaxSymbolsRequest symbols
+// if there is no tokenizer. Line longer than the limit makes a chunk alone and reduced by chatGPTRequest.
+func (o *OpenAI) splitChunks(lines []string) []string {
+	size := func(s string) int { return len(s) }
+	limit := o.params.MaxSymbolsRequest
+	if encoder := o.params.Tokenizer; encoder != nil {
+		size = func(s string) int {
+			tokens, err := encoder.Encode(s)
+			if err != nil {
+				return len(s)
+			}
+			return len(tokens)
+		}
+		limit = o.params.MaxTokensRequest
+	}
+
+	var res []string
+	var chunk []string
+	chunkSize := 0
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lineSize := size(line + "\n")
+		if len(chunk) > 0 && chunkSize+lineSize > limit {
+			res = append(res, strings.Join(chunk, "\n"))
+			chunk, chunkSize = nil, 0
+		}
+		chunk = append(chunk, line)
+		chunkSize += lineSize
+	}
+	if len(chunk) > 0 {
+		res = append(res, strings.Join(chunk, "\n"))
+	}
+	return res
+}
+
+// parseChatSummary gets summary and threads from the JSON response, which may be wrapped in markdown code block
+func parseChatSummary(resp string) (summary string, threads []string, err error) {
+	start, end := strings.Index(resp, "{"), strings.LastIndex(resp, "}")
+	if start < 0 || end < start {
+		return "", nil, fmt.Errorf("no JSON in chat summary response %q", resp)
+	}
+	res := struct {
+		Summary string   `json:"summary"`
+		Threads []string `json:"threads"`
+	}{}
+	if err = json.Unmarshal([]byte(resp[start:end+1]), &res); err != nil {
+		return "", nil, fmt.Errorf("failed to parse chat summary response %q: %w", resp, err)
+	}
+	if strings.TrimSpace(res.Summary) == "" {
+		return "", nil, fmt.Errorf("empty chat summary in response %q", resp)
+	}
+	for _, t := range res.Threads {
+		if t = strings.TrimSpace(t); t != "" {
+			threads = append(threads, t)
+		}
+	}
+	return strings.TrimSpace(res.Summary), threads, nil
+}
@@ -0,0 +1,116 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot/llm"
+	"github.com/radio-t/super-bot/app/bot/llm/mocks"
+)
+
+func TestOpenAI_ChatSummary(t *testing.T) {
+	newClient := func() *mocks.Provider {
+		return &mocks.Provider{CompleteFunc: func(_ context.Context, req llm.Request) (string, error) {
+			if req.Messages[0].Content == chatChunkPrompt {
+				return " note ", nil
+			}
+			return "```json\n{\"summary\": \" обсуждали go \", \"threads\": [\"дженерики\", \" \", \"итераторы\"]}\n```", nil
+		}}
+	}
+	tokenizer, err := llm.NewTokenizer("gpt3")
+	require.NoError(t, err)
+	params := Params{MaxTokensRequest: 20, MaxSymbolsRequest: 100, Tokenizer: tokenizer}
+
+	t.Run("single chunk", func(t *testing.T) {
+		client := newClient()
+		o := &OpenAI{client: client, params: params}
+		summary, threads, err := o.ChatSummary([]string{"20:00 user1: привет", "", "20:01 user2: go"})
+		require.NoError(t, err)
+		assert.Equal(t, "обсуждали go", summary)
+		assert.Equal(t, []string{"дженерики", "итераторы"}, threads)
+		calls := client.CompleteCalls()
+		require.Len(t, calls, 1)
+		assert.Equal(t, chatSummaryPrompt, calls[0].Req.Messages[0].Content)
+		assert.Equal(t, "20:00 user1: привет\n20:01 user2: go", calls[0].Req.Messages[1].Content)
+	})
+
+	t.Run("chunks", func(t *testing.T) {
+		client := newClient()
+		o := &OpenAI{client: client, params: params}
+		var lines []string
+		for i := 0; i < 10; i++ {
+			lines = append(lines, "20:00 user: generics and iterators in go")
+		}
+		summary, _, err := o.ChatSummary(lines)
+		require.NoError(t, err)
+		assert.Equal(t, "обсуждали go", summary)
+		calls := client.CompleteCalls()
+		require.Greater(t, len(calls), 2)
+		last := calls[len(calls)-1].Req.Messages
+		assert.Equal(t, chatSummaryPrompt, last[0].Content)
+		assert.Equal(t, strings.TrimSuffix(strings.Repeat("note\n\n", len(calls)-1), "\n\n"), last[1].Content,
+			"summary made from notes of all chunks")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		o := &OpenAI{client: newClient(), params: params}
+		_, _, err := o.ChatSummary([]string{" ", ""})
+		assert.Error(t, err)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		o := &OpenAI{params: params, client: &mocks.Provider{
+			CompleteFunc: func(context.Context, llm.Request) (string, error) {
+				return "", errors.New("too many requests")
+			}}}
+		_, _, err := o.ChatSummary([]string{"20:00 user: hi"})
+		assert.ErrorContains(t, err, "too many requests")
+	})
+}
+
+func TestSplitChunks(t *testing.T) {
+	lines := []string{"one two three", "four five six", "a very long line which doesn't fit into the chunk alone", "seven"}
+	tokenizer, err := llm.NewTokenizer("gpt3")
+	require.NoError(t, err)
+	o := &OpenAI{params: Params{MaxTokensRequest: 8, MaxSymbolsRequest: 30, Tokenizer: tokenizer}}
+	assert.Equal(t, []string{"one two three\nfour five six", "a very long line which doesn't fit into the chunk alone", "seven"},
+		o.splitChunks(lines))
+	assert.Empty(t, o.splitChunks(nil))
+
+	o.params.Tokenizer = nil
+	assert.Equal(t, []string{"one two three\nfour five six", "a very long line which doesn't fit into the chunk alone", "seven"},
+		o.splitChunks(lines), "limited by symbols without tokenizer")
+}
+
+func TestParseChatSummary(t *testing.T) {
+	tbl := []struct {
+		resp    string
+		summary string
+		threads []string
+		fail    bool
+	}{
+		{`{"summary": "s", "threads": ["a", "b"]}`, "s", []string{"a", "b"}, false},
+		{"вот ответ:\n```json\n{\"summary\": \"s\"}\n```", "s", nil, false},
+		{`{"summary": "", "threads": ["a"]}`, "", nil, true},
+		{`{"summary": "s", "threads": "a"}`, "", nil, true},
+		{"просто текст", "", nil, true},
+		{"} {", "", nil, true},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.resp, func(t *testing.T) {
+			summary, threads, err := parseChatSummary(tt.resp)
+			if tt.fail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.summary, summary)
+			assert.Equal(t, tt.threads, threads)
+		})
+	}
+}
@@ -5,41 +5,30 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
-	"net/http"
 	"strings"
 	"time"
 
-	tokenizer "github.com/sandwich-go/gpt3-encoder"
-	"github.com/sashabaranov/go-openai"
-
 	"github.com/radio-t/super-bot/app/bot"
+	"github.com/radio-t/super-bot/app/bot/llm"
 )
 
-//go:generate moq --out mocks/openai_client.go --pkg mocks --skip-ensure . openAIClient:OpenAIClient
-
-// openAIClient is interface for OpenAI client with the possibility to mock it
-type openAIClient interface {
-	CreateChatCompletion(context.Context, openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
-}
-
-// Params contains parameters for OpenAI bot
+// Params contains parameters for OpenAI bot, limits and tokenizer are of the model of the provider
 type Params struct {
-	AuthToken string
 	// https://platform.openai.com/docs/api-reference/chat/create#chat/create-max_tokens
 	MaxTokensResponse int // hard limit for the number of tokens in the response
 	// the OpenAI has a limit for the number of tokens in the request + response (4097)
-	MaxTokensRequest        int // max request length in tokens
-	MaxSymbolsRequest       int // fallback: Max request length in symbols, if tokenizer was failed
+	MaxTokensRequest        int           // max request length in tokens
+	MaxSymbolsRequest       int           // fallback: Max request length in symbols, if there is no tokenizer or it failed
+	Tokenizer               llm.Tokenizer // tokenizer of the model, requests limited by MaxSymbolsRequest only if nil
 	Prompt                  string
 	EnableAutoResponse      bool
 	HistorySize             int
 	HistoryReplyProbability int // percentage of the probability to reply with history
-	Model                   string
 }
 
-// OpenAI bot, returns responses from ChatGPT via OpenAI API
+// OpenAI bot, returns responses from ChatGPT via OpenAI API or from other LLM provider
 type OpenAI struct {
-	client openAIClient
+	client llm.Provider
 
 	params    Params
 	superUser bot.SuperUser
@@ -53,17 +42,14 @@ type OpenAI struct {
 
 const cooldownDuration = 5 * time.Minute
 
-// NewOpenAI makes a bot for ChatGPT
-func NewOpenAI(params Params, httpClient *http.Client, superUser bot.SuperUser) *OpenAI {
-	log.Printf("[INFO] OpenAI bot with github.com/sashabaranov/go-openai, Prompt=%s, max=%d. Auto response is %v",
-		params.Prompt, params.MaxTokensResponse, params.EnableAutoResponse)
+// NewOpenAI makes a bot for ChatGPT or other model of the LLM provider
+func NewOpenAI(params Params, provider llm.Provider, superUser bot.SuperUser) *OpenAI {
+	log.Printf("[INFO] OpenAI bot with %T, Prompt=%s, max=%d. Auto response is %v",
+		provider, params.Prompt, params.MaxTokensResponse, params.EnableAutoResponse)
 
-	openAIConfig := openai.DefaultConfig(params.AuthToken)
-	openAIConfig.HTTPClient = httpClient
-	client := openai.NewClientWithConfig(openAIConfig)
 	history := NewLimitedMessageHistory(params.HistorySize)
 
-	return &OpenAI{client: client, params: params, superUser: superUser,
+	return &OpenAI{client: provider, params: params, superUser: superUser,
 		history: history, rand: rand.Int63n, nowFn: time.Now}
 }
 
@@ -207,7 +193,7 @@ func (o *OpenAI) chatGPTRequest(request, userPrompt, sysPrompt string) (response
 	// the response is limited to 1000 tokens and OpenAI always reserved it for the result
 	// so the max length of the request should be 3000 tokens or ~12000 characters
 	reduceRequest := func(text string) (result string) {
-		// defaultReducer is a fallback if tokenizer fails
+		// defaultReducer is a fallback if there is no tokenizer or it fails
 		defaultReducer := func(text string) (result string) {
 			if len(text) <= o.params.MaxSymbolsRequest {
 				return text
@@ -216,9 +202,8 @@ func (o *OpenAI) chatGPTRequest(request, userPrompt, sysPrompt string) (response
 			return text[:o.params.MaxSymbolsRequest]
 		}
 
-		encoder, err := tokenizer.NewEncoder()
-		if err != nil {
-			log.Printf("[WARN] Can't init tokenizer: %v", err)
+		encoder := o.params.Tokenizer
+		if encoder == nil {
 			return defaultReducer(text)
 		}
 
@@ -242,13 +227,13 @@ func (o *OpenAI) chatGPTRequest(request, userPrompt, sysPrompt string) (response
 
 	r = reduceRequest(r)
 
-	return o.chatGPTRequestInternal([]openai.ChatCompletionMessage{
+	return o.chatGPTRequestInternal([]llm.Message{
 		{
-			Role:    openai.ChatMessageRoleSystem,
+			Role:    llm.RoleSystem,
 			Content: sysPrompt,
 		},
 		{
-			Role:    openai.ChatMessageRoleUser,
+			Role:    llm.RoleUser,
 			Content: r,
 		},
 	})
@@ -268,16 +253,16 @@ func (o *OpenAI) shouldAnswerWithHistory(msg bot.Message) bool {
 }
 
 func (o *OpenAI) chatGPTRequestWithHistory(sysPrompt string) (response string, err error) {
-	messages := make([]openai.ChatCompletionMessage, 0, len(o.history.messages)+1)
+	messages := make([]llm.Message, 0, len(o.history.messages)+1)
 
-	messages = append(messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleSystem,
+	messages = append(messages, llm.Message{
+		Role:    llm.RoleSystem,
 		Content: sysPrompt,
 	})
 
 	for _, message := range o.history.messages {
-		messages = append(messages, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleUser,
+		messages = append(messages, llm.Message{
+			Role:    llm.RoleUser,
 			Content: message.Text,
 		})
 	}
@@ -288,19 +273,19 @@ func (o *OpenAI) chatGPTRequestWithHistory(sysPrompt string) (response string, e
 // chatGPTRequestWithHistoryAndFocus works like chatGPTRequest but includes conversation history
 // while making the current message more prominent for focused responses
 func (o *OpenAI) chatGPTRequestWithHistoryAndFocus(currentRequest, userPrompt, sysPrompt string) (response string, err error) {
-	messages := make([]openai.ChatCompletionMessage, 0, len(o.history.messages)+2)
+	messages := make([]llm.Message, 0, len(o.history.messages)+2)
 
 	// add system prompt
-	messages = append(messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleSystem,
+	messages = append(messages, llm.Message{
+		Role:    llm.RoleSystem,
 		Content: sysPrompt + " Use the conversation history for context, but focus on responding to the latest message.",
 	})
 
 	// add previous messages from history, except the last one which was just added
 	if len(o.history.messages) > 1 {
 		for _, message := range o.history.messages[:len(o.history.messages)-1] {
-			messages = append(messages, openai.ChatCompletionMessage{
-				Role:    openai.ChatMessageRoleUser,
+			messages = append(messages, llm.Message{
+				Role:    llm.RoleUser,
 				Content: message.Text,
 			})
 		}
@@ -313,35 +298,27 @@ func (o *OpenAI) chatGPTRequestWithHistoryAndFocus(currentRequest, userPrompt, s
 	}
 
 	// add current request as the final message to emphasize it
-	messages = append(messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
+	messages = append(messages, llm.Message{
+		Role:    llm.RoleUser,
 		Content: r,
 	})
 
 	return o.chatGPTRequestInternal(messages)
 }
 
-func (o *OpenAI) chatGPTRequestInternal(messages []openai.ChatCompletionMessage) (response string, err error) {
-	resp, err := o.client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model:     o.params.Model,
-			MaxTokens: o.params.MaxTokensResponse,
-			Messages:  messages,
-		},
-	)
+func (o *OpenAI) chatGPTRequestInternal(messages []llm.Message) (response string, err error) {
+	response, err = o.client.Complete(context.Background(), llm.Request{
+		MaxTokens: o.params.MaxTokensResponse,
+		Messages:  messages,
+	})
 	if err != nil {
-		reqDetails := fmt.Sprintf("request: %v, model: %s, max_tokens: %d", messages, o.params.Model, o.params.MaxTokensResponse)
+		reqDetails := fmt.Sprintf("request: %v, max_tokens: %d", messages, o.params.MaxTokensResponse)
 		return "", fmt.Errorf("OpenAI request failed %s: %w", reqDetails, err)
 	}
-	// openAI platform supports to return multiple chat completion choices
-	// but we use only the first one
-	// https://platform.openai.com/docs/api-reference/chat/create#chat/create-n
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+	if strings.TrimSpace(response) == "" {
+		return "", fmt.Errorf("empty response to OpenAI request %v", messages)
 	}
-
-	return resp.Choices[0].Message.Content, nil
+	return response, nil
 }
 
 // Summary returns summary of the text
@@ -354,11 +331,11 @@ func (o *OpenAI) ReactOn() []string {
 	return []string{"chat!", "gpt!", "ai!", "чат!"}
 }
 
-// CreateChatCompletion exposes the underlying openai.CreateChatCompletion method
-func (o *OpenAI) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
-	resp, err := o.client.CreateChatCompletion(ctx, req)
+// Complete exposes chat completion of the underlying provider, implements llm.Provider
+func (o *OpenAI) Complete(ctx context.Context, req llm.Request) (string, error) {
+	resp, err := o.client.Complete(ctx, req)
 	if err != nil {
-		return openai.ChatCompletionResponse{}, fmt.Errorf("failed to create chat completion: %w", err)
+		return "", fmt.Errorf("failed to create chat completion: %w", err)
 	}
 	return resp, nil
 }
@@ -2,22 +2,20 @@ package openai
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
-	"os"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/radio-t/super-bot/app/bot"
 
-	ai "github.com/sashabaranov/go-openai"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/radio-t/super-bot/app/bot/llm"
+	lmocks "github.com/radio-t/super-bot/app/bot/llm/mocks"
 	bmocks "github.com/radio-t/super-bot/app/bot/mocks"
-	"github.com/radio-t/super-bot/app/bot/openai/mocks"
 )
 
 func TestOpenAI_Help(t *testing.T) {
@@ -26,7 +24,6 @@ func TestOpenAI_Help(t *testing.T) {
 
 func getDefaultTestingConfig() Params {
 	return Params{
-		AuthToken:               "ss-mockToken",
 		MaxTokensResponse:       100,
 		Prompt:                  "",
 		HistorySize:             2,
@@ -38,24 +35,19 @@ func getDefaultTestingConfig() Params {
 }
 
 func TestOpenAI_OnMessage(t *testing.T) {
-	// example of response from OpenAI API
-	// https://platform.openai.com/docs/api-reference/chat
-	jsonResponse, err := os.ReadFile("testdata/chat_completion_response.json")
-	require.NoError(t, err)
-
 	tbl := []struct {
 		request    string
 		prompt     string
-		json       []byte
+		result     string
 		mockResult bool
 		username   string
 		response   bot.Response
 	}{
-		{"Good result", "Prompt", jsonResponse, true, "", bot.Response{Text: "Mock response", Send: true, ReplyTo: 756}},
-		{"Good result", "", jsonResponse, true, "", bot.Response{Text: "Mock response", Send: true, ReplyTo: 756}},
-		{"Error result", "", jsonResponse, false, "", bot.Response{}},
-		{"Error result for super user", "", jsonResponse, false, "super", bot.Response{Text: "OpenAI API error occurred. Please check logs for details.", Send: true, ReplyTo: 756}},
-		{"Empty result", "", []byte(`{}`), true, "", bot.Response{}},
+		{"Good result", "Prompt", "Mock response", true, "", bot.Response{Text: "Mock response", Send: true, ReplyTo: 756}},
+		{"Good result", "", "Mock response", true, "", bot.Response{Text: "Mock response", Send: true, ReplyTo: 756}},
+		{"Error result", "", "Mock response", false, "", bot.Response{}},
+		{"Error result for super user", "", "Mock response", false, "super", bot.Response{Text: "OpenAI API error occurred. Please check logs for details.", Send: true, ReplyTo: 756}},
+		{"Empty result", "", " ", true, "", bot.Response{}},
 	}
 
 	su := &bmocks.SuperUser{IsSuperFunc: func(userName string) bool {
@@ -67,25 +59,18 @@ func TestOpenAI_OnMessage(t *testing.T) {
 
 	for i, tt := range tbl {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
-			mockOpenAIClient := &mocks.OpenAIClient{
-				CreateChatCompletionFunc: func(ctx context.Context, request ai.ChatCompletionRequest) (ai.ChatCompletionResponse, error) {
-					var response ai.ChatCompletionResponse
-
-					err = json.Unmarshal(tt.json, &response)
-					require.NoError(t, err)
-
+			mockProvider := &lmocks.Provider{
+				CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
 					if !tt.mockResult {
-						return ai.ChatCompletionResponse{}, fmt.Errorf("mock error")
+						return "", fmt.Errorf("mock error")
 					}
-
-					return response, nil
+					return tt.result, nil
 				},
 			}
 			config := getDefaultTestingConfig()
 			config.Prompt = tt.prompt
 
-			o := NewOpenAI(config, &http.Client{Timeout: 10 * time.Second}, su)
-			o.client = mockOpenAIClient
+			o := NewOpenAI(config, mockProvider, su)
 
 			msg := bot.Message{Text: fmt.Sprintf("chat! %s", tt.request), ID: 756}
 			if tt.username != "" {
@@ -94,26 +79,22 @@ func TestOpenAI_OnMessage(t *testing.T) {
 
 			assert.Equal(t, tt.response, o.OnMessage(msg))
 
-			calls := mockOpenAIClient.CreateChatCompletionCalls()
+			calls := mockProvider.CompleteCalls()
 			require.Equal(t, 1, len(calls))
 			// first message is system role setup
 			expRequest := tt.request
 			if tt.prompt != "" {
 				expRequest = tt.prompt + ".\n" + tt.request
 			}
-			assert.Equal(t, expRequest, calls[0].ChatCompletionRequest.Messages[1].Content)
+			assert.Equal(t, expRequest, calls[0].Req.Messages[1].Content)
 		})
 	}
 }
 
 func TestOpenAI_OnMessage_TooManyRequests(t *testing.T) {
-	mockOpenAIClient := &mocks.OpenAIClient{
-		CreateChatCompletionFunc: func(ctx context.Context, r ai.ChatCompletionRequest) (ai.ChatCompletionResponse, error) {
-			jsonResponse, err := os.ReadFile("testdata/chat_completion_response.json")
-			require.NoError(t, err)
-			var response ai.ChatCompletionResponse
-			err = json.Unmarshal(jsonResponse, &response)
-			return response, err
+	mockProvider := &lmocks.Provider{
+		CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
+			return "Mock response", nil
 		},
 	}
 
@@ -124,8 +105,7 @@ func TestOpenAI_OnMessage_TooManyRequests(t *testing.T) {
 		return false
 	}}
 
-	o := NewOpenAI(getDefaultTestingConfig(), &http.Client{Timeout: 10 * time.Second}, su)
-	o.client = mockOpenAIClient
+	o := NewOpenAI(getDefaultTestingConfig(), mockProvider, su)
 
 	{ // first request, allowed
 		resp := o.OnMessage(bot.Message{Text: "chat! something", ID: 756})
@@ -190,13 +170,9 @@ func TestOpenAI_OnMessage_TooManyRequests(t *testing.T) {
 }
 
 func TestOpenAI_OnMessage_ResponseWithWTF(t *testing.T) {
-	mockOpenAIClient := &mocks.OpenAIClient{
-		CreateChatCompletionFunc: func(ctx context.Context, r ai.ChatCompletionRequest) (ai.ChatCompletionResponse, error) {
-			jsonResponse, err := os.ReadFile("testdata/chat_completion_wtf_response.json")
-			require.NoError(t, err)
-			var response ai.ChatCompletionResponse
-			err = json.Unmarshal(jsonResponse, &response)
-			return response, err
+	mockProvider := &lmocks.Provider{
+		CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
+			return "Mock response with wtf", nil
 		},
 	}
 
@@ -207,8 +183,7 @@ func TestOpenAI_OnMessage_ResponseWithWTF(t *testing.T) {
 		return false
 	}}
 
-	o := NewOpenAI(getDefaultTestingConfig(), &http.Client{Timeout: 10 * time.Second}, su)
-	o.client = mockOpenAIClient
+	o := NewOpenAI(getDefaultTestingConfig(), mockProvider, su)
 
 	{ // first request by regular User, banned
 		resp := o.OnMessage(bot.Message{Text: "chat! something", ID: 756})
@@ -230,13 +205,9 @@ func TestOpenAI_OnMessage_ResponseWithWTF(t *testing.T) {
 }
 
 func TestOpenAI_OnMessage_RequestWithHistory(t *testing.T) {
-	mockOpenAIClient := &mocks.OpenAIClient{
-		CreateChatCompletionFunc: func(ctx context.Context, r ai.ChatCompletionRequest) (ai.ChatCompletionResponse, error) {
-			jsonResponse, err := os.ReadFile("testdata/chat_completion_response.json")
-			require.NoError(t, err)
-			var response ai.ChatCompletionResponse
-			err = json.Unmarshal(jsonResponse, &response)
-			return response, err
+	mockProvider := &lmocks.Provider{
+		CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
+			return "Mock response", nil
 		},
 	}
 
@@ -247,8 +218,7 @@ func TestOpenAI_OnMessage_RequestWithHistory(t *testing.T) {
 		return false
 	}}
 
-	o := NewOpenAI(getDefaultTestingConfig(), &http.Client{Timeout: 10 * time.Second}, su)
-	o.client = mockOpenAIClient
+	o := NewOpenAI(getDefaultTestingConfig(), mockProvider, su)
 	// always pass the probability check
 	o.rand = func(n int64) int64 { return 1 }
 	// history is limited  to 2 messages for easier testing
@@ -276,24 +246,20 @@ func TestOpenAI_OnMessage_RequestWithHistory(t *testing.T) {
 		assert.Equal(t, 0, resp.ReplyTo)
 		assert.Equal(t, 2, len(o.history.messages))
 
-		calls := mockOpenAIClient.CreateChatCompletionCalls()
+		calls := mockProvider.CompleteCalls()
 		assert.Equal(t, 1, len(calls))
 		// first message is system role setup
-		assert.Equal(t, 3, len(calls[0].ChatCompletionRequest.Messages))
-		assert.Equal(t, "message 2", calls[0].ChatCompletionRequest.Messages[1].Content)
-		assert.Equal(t, "message 3?", calls[0].ChatCompletionRequest.Messages[2].Content)
+		assert.Equal(t, 3, len(calls[0].Req.Messages))
+		assert.Equal(t, "message 2", calls[0].Req.Messages[1].Content)
+		assert.Equal(t, "message 3?", calls[0].Req.Messages[2].Content)
 	}
 
 }
 
 func TestOpenAI_OnMessage_shouldAnswerWithHistory(t *testing.T) {
-	mockOpenAIClient := &mocks.OpenAIClient{
-		CreateChatCompletionFunc: func(ctx context.Context, r ai.ChatCompletionRequest) (ai.ChatCompletionResponse, error) {
-			jsonResponse, err := os.ReadFile("testdata/chat_completion_response.json")
-			require.NoError(t, err)
-			var response ai.ChatCompletionResponse
-			err = json.Unmarshal(jsonResponse, &response)
-			return response, err
+	mockProvider := &lmocks.Provider{
+		CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
+			return "Mock response", nil
 		},
 	}
 
@@ -304,8 +270,7 @@ func TestOpenAI_OnMessage_shouldAnswerWithHistory(t *testing.T) {
 		return false
 	}}
 
-	o := NewOpenAI(getDefaultTestingConfig(), &http.Client{Timeout: 10 * time.Second}, su)
-	o.client = mockOpenAIClient
+	o := NewOpenAI(getDefaultTestingConfig(), mockProvider, su)
 	// always pass the probability check
 	o.rand = func(n int64) int64 { return 1 }
 
@@ -331,13 +296,9 @@ func TestOpenAI_OnMessage_shouldAnswerWithHistory(t *testing.T) {
 }
 
 func TestOpenAI_OnMessage_shouldAnswerWithHistory_NotEnoughMessages(t *testing.T) {
-	mockOpenAIClient := &mocks.OpenAIClient{
-		CreateChatCompletionFunc: func(ctx context.Context, r ai.ChatCompletionRequest) (ai.ChatCompletionResponse, error) {
-			jsonResponse, err := os.ReadFile("testdata/chat_completion_response.json")
-			require.NoError(t, err)
-			var response ai.ChatCompletionResponse
-			err = json.Unmarshal(jsonResponse, &response)
-			return response, err
+	mockProvider := &lmocks.Provider{
+		CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
+			return "Mock response", nil
 		},
 	}
 
@@ -348,8 +309,7 @@ func TestOpenAI_OnMessage_shouldAnswerWithHistory_NotEnoughMessages(t *testing.T
 		return false
 	}}
 
-	o := NewOpenAI(getDefaultTestingConfig(), &http.Client{Timeout: 10 * time.Second}, su)
-	o.client = mockOpenAIClient
+	o := NewOpenAI(getDefaultTestingConfig(), mockProvider, su)
 	// always pass the probability check
 	o.rand = func(n int64) int64 { return 1 }
 
@@ -374,13 +334,9 @@ func TestOpenAI_OnMessage_shouldAnswerWithHistory_NotEnoughMessages(t *testing.T
 }
 
 func TestOpenAI_OnMessage_shouldAnswerWithHistory_Random(t *testing.T) {
-	mockOpenAIClient := &mocks.OpenAIClient{
-		CreateChatCompletionFunc: func(ctx context.Context, r ai.ChatCompletionRequest) (ai.ChatCompletionResponse, error) {
-			jsonResponse, err := os.ReadFile("testdata/chat_completion_response.json")
-			require.NoError(t, err)
-			var response ai.ChatCompletionResponse
-			err = json.Unmarshal(jsonResponse, &response)
-			return response, err
+	mockProvider := &lmocks.Provider{
+		CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
+			return "Mock response", nil
 		},
 	}
 
@@ -391,8 +347,7 @@ func TestOpenAI_OnMessage_shouldAnswerWithHistory_Random(t *testing.T) {
 		return false
 	}}
 
-	o := NewOpenAI(getDefaultTestingConfig(), &http.Client{Timeout: 10 * time.Second}, su)
-	o.client = mockOpenAIClient
+	o := NewOpenAI(getDefaultTestingConfig(), mockProvider, su)
 
 	// history is limited  to 2 messages for easier testing
 	o.history.Add(bot.Message{Text: "message 1", ID: 756})
@@ -492,13 +447,9 @@ func TestOpenAI_UserNameOrDisplayName_NoUsernameOrDisplayName(t *testing.T) {
 }
 
 func TestOpenAI_chatGPTRequestWithHistoryAndFocus(t *testing.T) {
-	mockOpenAIClient := &mocks.OpenAIClient{
-		CreateChatCompletionFunc: func(ctx context.Context, r ai.ChatCompletionRequest) (ai.ChatCompletionResponse, error) {
-			jsonResponse, err := os.ReadFile("testdata/chat_completion_response.json")
-			require.NoError(t, err)
-			var response ai.ChatCompletionResponse
-			err = json.Unmarshal(jsonResponse, &response)
-			return response, err
+	mockProvider := &lmocks.Provider{
+		CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
+			return "Mock response", nil
 		},
 	}
 
@@ -506,8 +457,7 @@ func TestOpenAI_chatGPTRequestWithHistoryAndFocus(t *testing.T) {
 		return false
 	}}
 
-	o := NewOpenAI(getDefaultTestingConfig(), &http.Client{Timeout: 10 * time.Second}, su)
-	o.client = mockOpenAIClient
+	o := NewOpenAI(getDefaultTestingConfig(), mockProvider, su)
 
 	// add some messages to history
 	o.history.Add(bot.Message{Text: "first message", ID: 1})
@@ -520,34 +470,30 @@ func TestOpenAI_chatGPTRequestWithHistoryAndFocus(t *testing.T) {
 	assert.Equal(t, "Mock response", respText)
 
 	// verify the request sent to OpenAI
-	calls := mockOpenAIClient.CreateChatCompletionCalls()
+	calls := mockProvider.CompleteCalls()
 	require.Equal(t, 1, len(calls))
-	messages := calls[0].ChatCompletionRequest.Messages
+	messages := calls[0].Req.Messages
 
 	// should have system prompt and all history messages except the last one (which is the current request)
 	require.Equal(t, 3, len(messages))
 
 	// check system prompt has the history context instruction
-	assert.Equal(t, ai.ChatMessageRoleSystem, messages[0].Role)
+	assert.Equal(t, llm.RoleSystem, messages[0].Role)
 	assert.Contains(t, messages[0].Content, "Use the conversation history for context")
 
 	// check previous messages are included
-	assert.Equal(t, ai.ChatMessageRoleUser, messages[1].Role)
+	assert.Equal(t, llm.RoleUser, messages[1].Role)
 	assert.Equal(t, "second message", messages[1].Content)
 
 	// check that the final message is the current request with prompt
-	assert.Equal(t, ai.ChatMessageRoleUser, messages[2].Role)
+	assert.Equal(t, llm.RoleUser, messages[2].Role)
 	assert.Equal(t, "test prompt.\ncurrent question?", messages[2].Content)
 }
 
 func TestOpenAI_OnMessage_WithDirectHistoryUsage(t *testing.T) {
-	mockOpenAIClient := &mocks.OpenAIClient{
-		CreateChatCompletionFunc: func(ctx context.Context, r ai.ChatCompletionRequest) (ai.ChatCompletionResponse, error) {
-			jsonResponse, err := os.ReadFile("testdata/chat_completion_response.json")
-			require.NoError(t, err)
-			var response ai.ChatCompletionResponse
-			err = json.Unmarshal(jsonResponse, &response)
-			return response, err
+	mockProvider := &lmocks.Provider{
+		CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
+			return "Mock response", nil
 		},
 	}
 
@@ -555,8 +501,7 @@ func TestOpenAI_OnMessage_WithDirectHistoryUsage(t *testing.T) {
 		return false
 	}}
 
-	o := NewOpenAI(getDefaultTestingConfig(), &http.Client{Timeout: 10 * time.Second}, su)
-	o.client = mockOpenAIClient
+	o := NewOpenAI(getDefaultTestingConfig(), mockProvider, su)
 
 	// first message - indirect, should be stored but not trigger response
 	firstMsg := bot.Message{Text: "This is context message", ID: 1}
@@ -573,18 +518,18 @@ func TestOpenAI_OnMessage_WithDirectHistoryUsage(t *testing.T) {
 	assert.Equal(t, 2, len(o.history.messages))
 
 	// verify the API was called with both messages (the history and current query)
-	calls := mockOpenAIClient.CreateChatCompletionCalls()
+	calls := mockProvider.CompleteCalls()
 	require.Equal(t, 1, len(calls))
-	messages := calls[0].ChatCompletionRequest.Messages
+	messages := calls[0].Req.Messages
 
 	// should have system prompt and history message and current request
 	assert.GreaterOrEqual(t, len(messages), 3)
 
 	// system prompt should be first
-	assert.Equal(t, ai.ChatMessageRoleSystem, messages[0].Role)
+	assert.Equal(t, llm.RoleSystem, messages[0].Role)
 
 	// last message should be the current request
-	assert.Equal(t, ai.ChatMessageRoleUser, messages[len(messages)-1].Role)
+	assert.Equal(t, llm.RoleUser, messages[len(messages)-1].Role)
 	assert.Contains(t, messages[len(messages)-1].Content, "reference the previous message")
 }
 
@@ -592,32 +537,27 @@ func TestOpenAI_chatGPTRequestInternal_APIError(t *testing.T) {
 	// test for detailed error handling in chatGPTRequestInternal
 
 	// create an OpenAI API error
-	apiErr := &ai.APIError{
-		Type:    "invalid_request_error",
-		Code:    "model_not_found",
-		Message: "The model 'gpt-4-turbo' does not exist",
-	}
+	apiErr := errors.New("model_not_found: The model 'gpt-4-turbo' does not exist")
 
 	su := &bmocks.SuperUser{IsSuperFunc: func(userName string) bool {
 		return false
 	}}
 
-	mockOpenAIClient := &mocks.OpenAIClient{
-		CreateChatCompletionFunc: func(ctx context.Context, request ai.ChatCompletionRequest) (ai.ChatCompletionResponse, error) {
-			return ai.ChatCompletionResponse{}, apiErr
+	mockProvider := &lmocks.Provider{
+		CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
+			return "", apiErr
 		},
 	}
 
-	o := NewOpenAI(getDefaultTestingConfig(), &http.Client{Timeout: 10 * time.Second}, su)
-	o.client = mockOpenAIClient
+	o := NewOpenAI(getDefaultTestingConfig(), mockProvider, su)
 
-	_, err := o.chatGPTRequestInternal([]ai.ChatCompletionMessage{
+	_, err := o.chatGPTRequestInternal([]llm.Message{
 		{
-			Role:    ai.ChatMessageRoleSystem,
+			Role:    llm.RoleSystem,
 			Content: "Test system prompt",
 		},
 		{
-			Role:    ai.ChatMessageRoleUser,
+			Role:    llm.RoleUser,
 			Content: "Test user message",
 		},
 	})
@@ -628,7 +568,7 @@ func TestOpenAI_chatGPTRequestInternal_APIError(t *testing.T) {
 	assert.Contains(t, err.Error(), "OpenAI request failed")
 
 	// verify API was called
-	calls := mockOpenAIClient.CreateChatCompletionCalls()
+	calls := mockProvider.CompleteCalls()
 	require.Equal(t, 1, len(calls))
 }
 
@@ -636,11 +576,7 @@ func TestOpenAI_OnMessage_APIError_SuperUserMessage(t *testing.T) {
 	// test to ensure super users get a special error message
 
 	// create an OpenAI API error
-	apiErr := &ai.APIError{
-		Type:    "invalid_request_error",
-		Code:    "model_not_found",
-		Message: "The model 'gpt-4-turbo' does not exist",
-	}
+	apiErr := errors.New("model_not_found: The model 'gpt-4-turbo' does not exist")
 
 	su := &bmocks.SuperUser{IsSuperFunc: func(userName string) bool {
 		if userName == "super" {
@@ -649,14 +585,13 @@ func TestOpenAI_OnMessage_APIError_SuperUserMessage(t *testing.T) {
 		return false
 	}}
 
-	mockOpenAIClient := &mocks.OpenAIClient{
-		CreateChatCompletionFunc: func(ctx context.Context, request ai.ChatCompletionRequest) (ai.ChatCompletionResponse, error) {
-			return ai.ChatCompletionResponse{}, apiErr
+	mockProvider := &lmocks.Provider{
+		CompleteFunc: func(ctx context.Context, req llm.Request) (string, error) {
+			return "", apiErr
 		},
 	}
 
-	o := NewOpenAI(getDefaultTestingConfig(), &http.Client{Timeout: 10 * time.Second}, su)
-	o.client = mockOpenAIClient
+	o := NewOpenAI(getDefaultTestingConfig(), mockProvider, su)
 
 	// test with regular user - should get empty response
 	regularUserMsg := bot.Message{
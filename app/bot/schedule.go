@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:generate moq --out mock_post_scheduler.go . PostScheduler
+
+// ScheduledPost is a message to be posted to the chat at the given time
+type ScheduledPost struct {
+	ID     string    `json:"id"`
+	At     time.Time `json:"at"`
+	Text   string    `json:"text"`
+	Pin    bool      `json:"pin,omitempty"`
+	HTML   bool      `json:"html,omitempty"` // text in HTML mode, markdown otherwise
+	Author string    `json:"author,omitempty"`
+}
+
+// PostScheduler keeps scheduled posts and delivers them at the due time
+type PostScheduler interface {
+	Schedule(post ScheduledPost) (id string, err error)
+	Scheduled() []ScheduledPost
+	Cancel(id string) error
+}
+
+// Schedule bot, allows (superusers only) to schedule, list and cancel delayed posts
+type Schedule struct {
+	scheduler PostScheduler
+	superUser SuperUser
+	location  *time.Location
+	nowFn     func() time.Time // for testing
+}
+
+// NewSchedule makes a bot for admins reacting on "schedule! 10m текст", "schedule! list" and "schedule! cancel id"
+func NewSchedule(scheduler PostScheduler, superUser SuperUser, location *time.Location) *Schedule {
+	log.Printf("[INFO] Schedule bot, location: %v", location)
+	return &Schedule{scheduler: scheduler, superUser: superUser, location: location, nowFn: time.Now}
+}
+
+// Help returns help message
+func (s *Schedule) Help() string {
+	return GenHelpMsg(s.ReactOn(), "отложенный пост: 10m|20:00|2006-01-02T15:04:05Z07:00 [pin] текст, list, cancel id (только для админов)")
+}
+
+// ReactOn keys
+func (s *Schedule) ReactOn() []string {
+	return []string{"schedule!", "отложить!"}
+}
+
+// OnMessage schedules, lists or cancels delayed posts
+func (s *Schedule) OnMessage(msg Message) (response Response) {
+	ok, req := s.request(msg.Text)
+	if !ok || !s.superUser.IsSuper(msg.From.Username) {
+		return Response{}
+	}
+
+	cmd, args, _ := strings.Cut(req, " ")
+	switch strings.ToLower(cmd) {
+	case "list":
+		return Response{Text: s.list(), Send: true, ReplyTo: msg.ID}
+
+	case "cancel":
+		id := strings.TrimSpace(args)
+		if err := s.scheduler.Cancel(id); err != nil {
+			log.Printf("[WARN] can't cancel scheduled post %q, %v", id, err)
+			return Response{Text: fmt.Sprintf("не получилось отменить пост %s", EscapeMarkDownV1Text(id)), Send: true, ReplyTo: msg.ID}
+		}
+		log.Printf("[INFO] scheduled post %s canceled by %+v", id, msg.From)
+		return Response{Text: fmt.Sprintf("пост %s отменен", EscapeMarkDownV1Text(id)), Send: true, ReplyTo: msg.ID}
+	}
+
+	when, rest, _ := strings.Cut(req, " ")
+	at, err := ParseScheduleTime(when, s.nowFn(), s.location)
+	if err != nil {
+		return Response{Text: "не понимаю когда, нужно 10m, 20:00 или 2006-01-02T15:04:05Z07:00", Send: true, ReplyTo: msg.ID}
+	}
+	post := ScheduledPost{At: at, Author: msg.From.Username}
+	if p, text, found := strings.Cut(strings.TrimSpace(rest), " "); found && strings.EqualFold(p, "pin") {
+		post.Pin, rest = true, text
+	}
+	post.Text = strings.TrimSpace(rest)
+	if post.Text == "" {
+		return Response{Text: "нечего публиковать", Send: true, ReplyTo: msg.ID}
+	}
+
+	id, err := s.scheduler.Schedule(post)
+	if err != nil {
+		log.Printf("[WARN] can't schedule post %+v, %v", post, err)
+		return Response{Text: "не получилось запланировать пост", Send: true, ReplyTo: msg.ID}
+	}
+	log.Printf("[INFO] post %s scheduled at %v by %+v", id, at, msg.From)
+	return Response{Text: fmt.Sprintf("пост %s будет опубликован %s", id, at.In(s.location).Format("2006-01-02 15:04")),
+		Send: true, ReplyTo: msg.ID}
+}
+
+func (s *Schedule) list() string {
+	posts := s.scheduler.Scheduled()
+	if len(posts) == 0 {
+		return "нет запланированных постов"
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].At.Before(posts[j].At) })
+	lines := make([]string, 0, len(posts))
+	for _, p := range posts {
+		pin := ""
+		if p.Pin {
+			pin = " 📌"
+		}
+		lines = append(lines, fmt.Sprintf("%s, %s%s: %s", p.ID, p.At.In(s.location).Format("2006-01-02 15:04"), pin,
+			EscapeMarkDownV1Text(p.Text)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *Schedule) request(text string) (react bool, reqText string) {
+	for _, prefix := range s.ReactOn() {
+		if strings.HasPrefix(strings.ToLower(text), prefix) {
+			return true, strings.TrimSpace(text[len(prefix):])
+		}
+	}
+	return false, ""
+}
+
+// ParseScheduleTime parses time of scheduled post. Supported formats are duration from now ("10m", "1h30m"),
+// time of day in the location ("20:00", the next occurrence) and RFC3339 timestamp.
+func ParseScheduleTime(s string, now time.Time, location *time.Location) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+
+	if t, err := time.ParseInLocation("15:04", s, location); err == nil {
+		nowLoc := now.In(location)
+		res := time.Date(nowLoc.Year(), nowLoc.Month(), nowLoc.Day(), t.Hour(), t.Minute(), 0, 0, location)
+		if !res.After(now) {
+			res = res.AddDate(0, 0, 1)
+		}
+		return res, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil && t.After(now) {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("can't parse schedule time %q", s)
+}
@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot/mocks"
+)
+
+func TestSchedule_OnMessage(t *testing.T) {
+	now := time.Date(2023, 4, 8, 16, 0, 0, 0, time.UTC)
+	su := &mocks.SuperUser{IsSuperFunc: func(userName string) bool { return userName == "admin" }}
+	sch := &PostSchedulerMock{
+		ScheduleFunc: func(post ScheduledPost) (string, error) {
+			if post.Text == "fail" {
+				return "", fmt.Errorf("failed")
+			}
+			return "1", nil
+		},
+		ScheduledFunc: func() []ScheduledPost {
+			return []ScheduledPost{
+				{ID: "2", At: now.Add(2 * time.Hour), Text: "second"},
+				{ID: "1", At: now.Add(time.Hour), Text: "first_post", Pin: true},
+			}
+		},
+		CancelFunc: func(id string) error {
+			if id != "1" {
+				return fmt.Errorf("not found")
+			}
+			return nil
+		},
+	}
+	b := NewSchedule(sch, su, time.UTC)
+	b.nowFn = func() time.Time { return now }
+
+	tbl := []struct {
+		text string
+		user string
+		resp string
+	}{
+		{"schedule! 10m text", "user", ""},
+		{"blah", "admin", ""},
+		{"schedule! 10m через 10 минут начинаем", "admin", "пост 1 будет опубликован 2023-04-08 16:10"},
+		{"отложить! 20:00 pin начинаем", "admin", "пост 1 будет опубликован 2023-04-08 20:00"},
+		{"schedule! 10m fail", "admin", "не получилось запланировать пост"},
+		{"schedule! 10m", "admin", "нечего публиковать"},
+		{"schedule! когда-нибудь text", "admin", "не понимаю когда, нужно 10m, 20:00 или 2006-01-02T15:04:05Z07:00"},
+		{"schedule! list", "admin", "1, 2023-04-08 17:00 📌: first\\_post\n2, 2023-04-08 18:00: second"},
+		{"schedule! cancel 1", "admin", "пост 1 отменен"},
+		{"schedule! cancel 5", "admin", "не получилось отменить пост 5"},
+	}
+
+	for i, tt := range tbl {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			resp := b.OnMessage(Message{ID: 10, Text: tt.text, From: User{Username: tt.user}})
+			if tt.resp == "" {
+				assert.Equal(t, Response{}, resp)
+				return
+			}
+			assert.Equal(t, Response{Text: tt.resp, Send: true, ReplyTo: 10}, resp)
+		})
+	}
+
+	require.Equal(t, 3, len(sch.ScheduleCalls()))
+	assert.Equal(t, ScheduledPost{At: now.Add(10 * time.Minute), Text: "через 10 минут начинаем", Author: "admin"},
+		sch.ScheduleCalls()[0].Post)
+	assert.Equal(t, ScheduledPost{At: time.Date(2023, 4, 8, 20, 0, 0, 0, time.UTC), Text: "начинаем", Pin: true, Author: "admin"},
+		sch.ScheduleCalls()[1].Post)
+}
+
+func TestParseScheduleTime(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	now := time.Date(2023, 4, 8, 20, 30, 0, 0, time.UTC) // 23:30 MSK
+
+	tbl := []struct {
+		inp string
+		res time.Time
+		err bool
+	}{
+		{"10m", now.Add(10 * time.Minute), false},
+		{"1h30m", now.Add(90 * time.Minute), false},
+		{"-10m", time.Time{}, true},
+		{"23:45", time.Date(2023, 4, 8, 23, 45, 0, 0, msk), false},
+		{"23:00", time.Date(2023, 4, 9, 23, 0, 0, 0, msk), false},
+		{"2023-04-09T10:00:00Z", time.Date(2023, 4, 9, 10, 0, 0, 0, time.UTC), false},
+		{"2023-04-01T10:00:00Z", time.Time{}, true},
+		{"tomorrow", time.Time{}, true},
+	}
+
+	for i, tt := range tbl {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, err := ParseScheduleTime(tt.inp, now, msk)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.res.Equal(res), "expected %v, got %v", tt.res, res)
+		})
+	}
+}
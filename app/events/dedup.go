@@ -0,0 +1,123 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dedup keeps keys of recently processed rtjc submissions to skip duplicates resent by clients.
+// State is stored in a json file and restored on start, so duplicates are detected across restarts.
+type Dedup struct {
+	path   string
+	window time.Duration
+	nowFn  func() time.Time // for testing
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewDedup makes Dedup with state file and window to keep submission keys for.
+// Empty path disables persistence.
+func NewDedup(path string, window time.Duration) (*Dedup, error) {
+	res := &Dedup{path: path, window: window, nowFn: time.Now, seen: map[string]time.Time{}}
+	if path == "" {
+		return res, nil
+	}
+
+	data, err := os.ReadFile(path) // nolint
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return res, nil
+		}
+		return nil, fmt.Errorf("failed to read dedup state %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &res.seen); err != nil {
+		log.Printf("[WARN] can't parse dedup state %s, starting from scratch: %v", path, err)
+		res.seen = map[string]time.Time{}
+	}
+	res.cleanup()
+	log.Printf("[INFO] dedup state loaded from %s, %d keys", path, len(res.seen))
+	return res, nil
+}
+
+// Seen reports if the key was marked within the window
+func (d *Dedup) Seen(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.cleanup()
+	_, ok := d.seen[key]
+	return ok
+}
+
+// Mark records the key of processed submission, should be called after successful processing only
+// to let the client resend failed one
+func (d *Dedup) Mark(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.seen[key] = d.nowFn()
+	if err := d.save(); err != nil {
+		log.Printf("[WARN] can't save dedup state, %v", err)
+	}
+}
+
+// cleanup removes keys older than window, caller should hold the lock
+func (d *Dedup) cleanup() {
+	for k, ts := range d.seen {
+		if d.nowFn().Sub(ts) > d.window {
+			delete(d.seen, k)
+		}
+	}
+}
+
+// save writes state to the file, caller should hold the lock
+func (d *Dedup) save() error {
+	if d.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(d.seen)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dedup state: %w", err)
+	}
+	return writeFileAtomic(d.path, data)
+}
+
+// writeFileAtomic writes data to temp file and renames it to avoid partial writes
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to make dir for %s: %w", path, err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to rename %s: %w", tmp, err)
+	}
+	return nil
+}
+
+// idempotencyKeyPrefix starts an optional explicit key for rtjc submission, i.e. "key:abc123 ⚠️ topic"
+const idempotencyKeyPrefix = "key:"
+
+// submissionKey extracts explicit idempotency key from the message or makes one from the content hash.
+// Returns message text without the key.
+func submissionKey(msg string) (key, text string) {
+	if strings.HasPrefix(msg, idempotencyKeyPrefix) {
+		if k, rest, found := strings.Cut(strings.TrimPrefix(msg, idempotencyKeyPrefix), " "); found && k != "" {
+			return "key:" + k, rest
+		}
+	}
+	h := sha256.Sum256([]byte(strings.TrimSpace(msg)))
+	return "hash:" + hex.EncodeToString(h[:]), msg
+}
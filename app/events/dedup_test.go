@@ -0,0 +1,68 @@
+package events
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedup_SeenMark(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.json")
+	d, err := NewDedup(path, time.Minute)
+	require.NoError(t, err)
+
+	now := time.Now()
+	d.nowFn = func() time.Time { return now }
+
+	assert.False(t, d.Seen("key1"))
+	assert.False(t, d.Seen("key1"), "not marked yet")
+	assert.NoFileExists(t, path)
+	d.Mark("key1")
+	assert.True(t, d.Seen("key1"))
+	assert.False(t, d.Seen("key2"))
+	assert.FileExists(t, path)
+
+	// state restored after restart
+	d2, err := NewDedup(path, time.Hour)
+	require.NoError(t, err)
+	d2.nowFn = func() time.Time { return now.Add(30 * time.Second) }
+	assert.True(t, d2.Seen("key1"))
+
+	// expired keys are not duplicates
+	d.nowFn = func() time.Time { return now.Add(2 * time.Minute) }
+	assert.False(t, d.Seen("key1"))
+}
+
+func TestDedup_BrokenState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.json")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+	d, err := NewDedup(path, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, d.Seen("key1"))
+
+	d, err = NewDedup("", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, d.Seen("key1"))
+	d.Mark("key1")
+	assert.True(t, d.Seen("key1"))
+}
+
+func TestSubmissionKey(t *testing.T) {
+	key, text := submissionKey("key:abc123 ⚠️ topic\n")
+	assert.Equal(t, "key:abc123", key)
+	assert.Equal(t, "⚠️ topic\n", text)
+
+	key, text = submissionKey("⚠️ topic\n")
+	assert.Equal(t, "⚠️ topic\n", text)
+	key2, _ := submissionKey(" ⚠️ topic ")
+	assert.Equal(t, key, key2, "hash ignores surrounding spaces")
+	assert.Contains(t, key, "hash:")
+
+	key, text = submissionKey("key: topic")
+	assert.Contains(t, key, "hash:", "empty key ignored")
+	assert.Equal(t, "key: topic", text)
+}
@@ -4,7 +4,7 @@
 package events
 
 import (
-	bot "github.com/radio-t/super-bot/app/bot"
+	"github.com/radio-t/super-bot/app/bot"
 	"sync"
 )
 
@@ -18,6 +18,9 @@ var _ msgLogger = &msgLoggerMock{}
 //
 //		// make and configure a mocked msgLogger
 //		mockedmsgLogger := &msgLoggerMock{
+//			DeleteFunc: func(msgID int)  {
+//				panic("mock out the Delete method")
+//			},
 //			SaveFunc: func(msg *bot.Message)  {
 //				panic("mock out the Save method")
 //			},
@@ -28,18 +31,59 @@ var _ msgLogger = &msgLoggerMock{}
 //
 //	}
 type msgLoggerMock struct {
+	// DeleteFunc mocks the Delete method.
+	DeleteFunc func(msgID int)
+
 	// SaveFunc mocks the Save method.
 	SaveFunc func(msg *bot.Message)
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// Delete holds details about calls to the Delete method.
+		Delete []struct {
+			// MsgID is the msgID argument value.
+			MsgID int
+		}
 		// Save holds details about calls to the Save method.
 		Save []struct {
 			// Msg is the msg argument value.
 			Msg *bot.Message
 		}
 	}
-	lockSave sync.RWMutex
+	lockDelete sync.RWMutex
+	lockSave   sync.RWMutex
+}
+
+// Delete calls DeleteFunc.
+func (mock *msgLoggerMock) Delete(msgID int) {
+	if mock.DeleteFunc == nil {
+		panic("msgLoggerMock.DeleteFunc: method is nil but msgLogger.Delete was just called")
+	}
+	callInfo := struct {
+		MsgID int
+	}{
+		MsgID: msgID,
+	}
+	mock.lockDelete.Lock()
+	mock.calls.Delete = append(mock.calls.Delete, callInfo)
+	mock.lockDelete.Unlock()
+	mock.DeleteFunc(msgID)
+}
+
+// DeleteCalls gets all the calls that were made to Delete.
+// Check the length with:
+//
+//	len(mockedmsgLogger.DeleteCalls())
+func (mock *msgLoggerMock) DeleteCalls() []struct {
+	MsgID int
+} {
+	var calls []struct {
+		MsgID int
+	}
+	mock.lockDelete.RLock()
+	calls = mock.calls.Delete
+	mock.lockDelete.RUnlock()
+	return calls
 }
 
 // Save calls SaveFunc.
@@ -14,12 +14,24 @@ import (
 //
 //		// make and configure a mocked events.submitter
 //		mockedsubmitter := &Submitter{
-//			SubmitFunc: func(ctx context.Context, text string, pin bool) error {
+//			SubmitFunc: func(ctx context.Context, text string, pin bool) (int, error) {
 //				panic("mock out the Submit method")
 //			},
 //			SubmitHTMLFunc: func(ctx context.Context, text string, pin bool) error {
 //				panic("mock out the SubmitHTML method")
 //			},
+//			SubmitHTMLAndWaitFunc: func(ctx context.Context, text string, pin bool) (int, error) {
+//				panic("mock out the SubmitHTMLAndWait method")
+//			},
+//			SubmitHTMLQuoteFunc: func(ctx context.Context, text string, replyTo int) error {
+//				panic("mock out the SubmitHTMLQuote method")
+//			},
+//			SubmitHTMLReplyFunc: func(ctx context.Context, text string, replyTo int) error {
+//				panic("mock out the SubmitHTMLReply method")
+//			},
+//			SubmitTopicFunc: func(ctx context.Context, text string) (int, error) {
+//				panic("mock out the SubmitTopic method")
+//			},
 //		}
 //
 //		// use mockedsubmitter in code that requires events.submitter
@@ -28,11 +40,23 @@ import (
 //	}
 type Submitter struct {
 	// SubmitFunc mocks the Submit method.
-	SubmitFunc func(ctx context.Context, text string, pin bool) error
+	SubmitFunc func(ctx context.Context, text string, pin bool) (int, error)
 
 	// SubmitHTMLFunc mocks the SubmitHTML method.
 	SubmitHTMLFunc func(ctx context.Context, text string, pin bool) error
 
+	// SubmitHTMLAndWaitFunc mocks the SubmitHTMLAndWait method.
+	SubmitHTMLAndWaitFunc func(ctx context.Context, text string, pin bool) (int, error)
+
+	// SubmitHTMLQuoteFunc mocks the SubmitHTMLQuote method.
+	SubmitHTMLQuoteFunc func(ctx context.Context, text string, replyTo int) error
+
+	// SubmitHTMLReplyFunc mocks the SubmitHTMLReply method.
+	SubmitHTMLReplyFunc func(ctx context.Context, text string, replyTo int) error
+
+	// SubmitTopicFunc mocks the SubmitTopic method.
+	SubmitTopicFunc func(ctx context.Context, text string) (int, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Submit holds details about calls to the Submit method.
@@ -53,13 +77,51 @@ type Submitter struct {
 			// Pin is the pin argument value.
 			Pin bool
 		}
+		// SubmitHTMLAndWait holds details about calls to the SubmitHTMLAndWait method.
+		SubmitHTMLAndWait []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Text is the text argument value.
+			Text string
+			// Pin is the pin argument value.
+			Pin bool
+		}
+		// SubmitHTMLQuote holds details about calls to the SubmitHTMLQuote method.
+		SubmitHTMLQuote []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Text is the text argument value.
+			Text string
+			// ReplyTo is the replyTo argument value.
+			ReplyTo int
+		}
+		// SubmitHTMLReply holds details about calls to the SubmitHTMLReply method.
+		SubmitHTMLReply []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Text is the text argument value.
+			Text string
+			// ReplyTo is the replyTo argument value.
+			ReplyTo int
+		}
+		// SubmitTopic holds details about calls to the SubmitTopic method.
+		SubmitTopic []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Text is the text argument value.
+			Text string
+		}
 	}
-	lockSubmit     sync.RWMutex
-	lockSubmitHTML sync.RWMutex
+	lockSubmit            sync.RWMutex
+	lockSubmitHTML        sync.RWMutex
+	lockSubmitHTMLAndWait sync.RWMutex
+	lockSubmitHTMLQuote   sync.RWMutex
+	lockSubmitHTMLReply   sync.RWMutex
+	lockSubmitTopic       sync.RWMutex
 }
 
 // Submit calls SubmitFunc.
-func (mock *Submitter) Submit(ctx context.Context, text string, pin bool) error {
+func (mock *Submitter) Submit(ctx context.Context, text string, pin bool) (int, error) {
 	if mock.SubmitFunc == nil {
 		panic("Submitter.SubmitFunc: method is nil but submitter.Submit was just called")
 	}
@@ -137,3 +199,159 @@ func (mock *Submitter) SubmitHTMLCalls() []struct {
 	mock.lockSubmitHTML.RUnlock()
 	return calls
 }
+
+// SubmitHTMLAndWait calls SubmitHTMLAndWaitFunc.
+func (mock *Submitter) SubmitHTMLAndWait(ctx context.Context, text string, pin bool) (int, error) {
+	if mock.SubmitHTMLAndWaitFunc == nil {
+		panic("Submitter.SubmitHTMLAndWaitFunc: method is nil but submitter.SubmitHTMLAndWait was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Text string
+		Pin  bool
+	}{
+		Ctx:  ctx,
+		Text: text,
+		Pin:  pin,
+	}
+	mock.lockSubmitHTMLAndWait.Lock()
+	mock.calls.SubmitHTMLAndWait = append(mock.calls.SubmitHTMLAndWait, callInfo)
+	mock.lockSubmitHTMLAndWait.Unlock()
+	return mock.SubmitHTMLAndWaitFunc(ctx, text, pin)
+}
+
+// SubmitHTMLAndWaitCalls gets all the calls that were made to SubmitHTMLAndWait.
+// Check the length with:
+//
+//	len(mockedsubmitter.SubmitHTMLAndWaitCalls())
+func (mock *Submitter) SubmitHTMLAndWaitCalls() []struct {
+	Ctx  context.Context
+	Text string
+	Pin  bool
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Text string
+		Pin  bool
+	}
+	mock.lockSubmitHTMLAndWait.RLock()
+	calls = mock.calls.SubmitHTMLAndWait
+	mock.lockSubmitHTMLAndWait.RUnlock()
+	return calls
+}
+
+// SubmitHTMLQuote calls SubmitHTMLQuoteFunc.
+func (mock *Submitter) SubmitHTMLQuote(ctx context.Context, text string, replyTo int) error {
+	if mock.SubmitHTMLQuoteFunc == nil {
+		panic("Submitter.SubmitHTMLQuoteFunc: method is nil but submitter.SubmitHTMLQuote was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Text    string
+		ReplyTo int
+	}{
+		Ctx:     ctx,
+		Text:    text,
+		ReplyTo: replyTo,
+	}
+	mock.lockSubmitHTMLQuote.Lock()
+	mock.calls.SubmitHTMLQuote = append(mock.calls.SubmitHTMLQuote, callInfo)
+	mock.lockSubmitHTMLQuote.Unlock()
+	return mock.SubmitHTMLQuoteFunc(ctx, text, replyTo)
+}
+
+// SubmitHTMLQuoteCalls gets all the calls that were made to SubmitHTMLQuote.
+// Check the length with:
+//
+//	len(mockedsubmitter.SubmitHTMLQuoteCalls())
+func (mock *Submitter) SubmitHTMLQuoteCalls() []struct {
+	Ctx     context.Context
+	Text    string
+	ReplyTo int
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Text    string
+		ReplyTo int
+	}
+	mock.lockSubmitHTMLQuote.RLock()
+	calls = mock.calls.SubmitHTMLQuote
+	mock.lockSubmitHTMLQuote.RUnlock()
+	return calls
+}
+
+// SubmitHTMLReply calls SubmitHTMLReplyFunc.
+func (mock *Submitter) SubmitHTMLReply(ctx context.Context, text string, replyTo int) error {
+	if mock.SubmitHTMLReplyFunc == nil {
+		panic("Submitter.SubmitHTMLReplyFunc: method is nil but submitter.SubmitHTMLReply was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Text    string
+		ReplyTo int
+	}{
+		Ctx:     ctx,
+		Text:    text,
+		ReplyTo: replyTo,
+	}
+	mock.lockSubmitHTMLReply.Lock()
+	mock.calls.SubmitHTMLReply = append(mock.calls.SubmitHTMLReply, callInfo)
+	mock.lockSubmitHTMLReply.Unlock()
+	return mock.SubmitHTMLReplyFunc(ctx, text, replyTo)
+}
+
+// SubmitHTMLReplyCalls gets all the calls that were made to SubmitHTMLReply.
+// Check the length with:
+//
+//	len(mockedsubmitter.SubmitHTMLReplyCalls())
+func (mock *Submitter) SubmitHTMLReplyCalls() []struct {
+	Ctx     context.Context
+	Text    string
+	ReplyTo int
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Text    string
+		ReplyTo int
+	}
+	mock.lockSubmitHTMLReply.RLock()
+	calls = mock.calls.SubmitHTMLReply
+	mock.lockSubmitHTMLReply.RUnlock()
+	return calls
+}
+
+// SubmitTopic calls SubmitTopicFunc.
+func (mock *Submitter) SubmitTopic(ctx context.Context, text string) (int, error) {
+	if mock.SubmitTopicFunc == nil {
+		panic("Submitter.SubmitTopicFunc: method is nil but submitter.SubmitTopic was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Text string
+	}{
+		Ctx:  ctx,
+		Text: text,
+	}
+	mock.lockSubmitTopic.Lock()
+	mock.calls.SubmitTopic = append(mock.calls.SubmitTopic, callInfo)
+	mock.lockSubmitTopic.Unlock()
+	return mock.SubmitTopicFunc(ctx, text)
+}
+
+// SubmitTopicCalls gets all the calls that were made to SubmitTopic.
+// Check the length with:
+//
+//	len(mockedsubmitter.SubmitTopicCalls())
+func (mock *Submitter) SubmitTopicCalls() []struct {
+	Ctx  context.Context
+	Text string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Text string
+	}
+	mock.lockSubmitTopic.RLock()
+	calls = mock.calls.SubmitTopic
+	mock.lockSubmitTopic.RUnlock()
+	return calls
+}
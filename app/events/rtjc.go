@@ -12,6 +12,8 @@ import (
 
 	"github.com/go-pkgz/syncs"
 	"golang.org/x/time/rate"
+
+	"github.com/radio-t/super-bot/app/bot"
 )
 
 //go:generate moq --out mocks/submitter.go --pkg mocks --skip-ensure . submitter:Submitter
@@ -28,6 +30,12 @@ type Rtjc struct {
 	Port       int
 	Submitter  submitter
 	Summarizer summarizer
+	EditTopics bool              // edit the current topic message on a new topic instead of posting a new one
+	Dedup      *Dedup            // skip duplicated submissions, optional
+	Scheduler  bot.PostScheduler // schedule delayed posts with "schedule:" command, optional
+	Location   *time.Location    // of time of day in "schedule:" command, same as in the chat command, UTC if not set
+
+	CollapseSummaries bool // post all summaries as one expandable quote instead of separate messages
 
 	Swg             *syncs.SizedGroup
 	SubmitRateLimit rate.Limit
@@ -36,8 +44,12 @@ type Rtjc struct {
 
 // submitter defines interface to submit (usually asynchronously) to the chat
 type submitter interface {
-	Submit(ctx context.Context, text string, pin bool) error
+	Submit(ctx context.Context, text string, pin bool) (msgID int, err error)
 	SubmitHTML(ctx context.Context, text string, pin bool) error
+	SubmitHTMLAndWait(ctx context.Context, text string, pin bool) (msgID int, err error)
+	SubmitHTMLReply(ctx context.Context, text string, replyTo int) error
+	SubmitHTMLQuote(ctx context.Context, text string, replyTo int) error
+	SubmitTopic(ctx context.Context, text string) (msgID int, err error)
 }
 
 type summarizer interface {
@@ -64,23 +76,103 @@ func (l Rtjc) Listen(ctx context.Context) {
 	}
 }
 
-func (l Rtjc) processMessage(ctx context.Context, conn io.Reader) {
+// processMessage reads a message from the connection, publishes or schedules it and acknowledges to the client
+// with "ok", "duplicate", "scheduled <id>" or "error <reason>" line. Duplicates are not published.
+func (l Rtjc) processMessage(ctx context.Context, conn io.ReadWriter) {
 	if message, rerr := bufio.NewReader(conn).ReadString('\n'); rerr == nil {
+		key, message := submissionKey(message)
+		if l.Dedup != nil && l.Dedup.Seen(key) {
+			log.Printf("[INFO] duplicated rtjc message %q skipped, key %s", strings.TrimSpace(message), key)
+			l.ack(conn, "duplicate")
+			return
+		}
+		if strings.HasPrefix(message, schedulePrefix) {
+			status := l.schedule(message)
+			if strings.HasPrefix(status, "scheduled ") {
+				l.markProcessed(key)
+			}
+			l.ack(conn, status)
+			return
+		}
+		l.ack(conn, "ok")
+
 		pin, msg := l.isPinned(message)
-		if serr := l.Submitter.Submit(ctx, msg, pin); serr != nil {
+		var replyTo int // summaries are posted as replies to the announcement
+		var serr error
+		if l.EditTopics && !pin && isTopic(msg) {
+			if replyTo, serr = l.Submitter.SubmitTopic(ctx, msg); serr != nil {
+				log.Printf("[WARN] can't send topic, %v", serr)
+			}
+		} else if replyTo, serr = l.Submitter.Submit(ctx, msg, pin); serr != nil {
 			log.Printf("[WARN] can't send message, %v", serr)
 		}
+		if serr == nil {
+			l.markProcessed(key)
+		}
 
 		l.Swg.Go(func(ctx context.Context) {
-			l.sendSummary(ctx, msg)
+			l.sendSummary(ctx, msg, replyTo)
 		})
 	} else {
 		log.Printf("[WARN] can't read message, %v", rerr)
 	}
 }
 
-func (l Rtjc) sendSummary(ctx context.Context, msg string) {
-	if !strings.HasPrefix(msg, "⚠") {
+// markProcessed records the key of successfully published or scheduled message, failed ones are not recorded
+// to keep resent copies from being skipped as duplicates
+func (l Rtjc) markProcessed(key string) {
+	if l.Dedup != nil {
+		l.Dedup.Mark(key)
+	}
+}
+
+func (l Rtjc) ack(conn io.Writer, status string) {
+	if _, err := io.WriteString(conn, status+"\n"); err != nil {
+		log.Printf("[DEBUG] can't send %q ack to rtjc client, %v", status, err)
+	}
+}
+
+// schedulePrefix starts rtjc command to schedule a delayed post, i.e. "schedule:10m,pin text".
+// Time can be a duration from now, time of day in Location or RFC3339 timestamp, followed by optional "pin" and "html" flags.
+const schedulePrefix = "schedule:"
+
+// schedule adds delayed post from rtjc command, returns ack status with post id
+func (l Rtjc) schedule(message string) (status string) {
+	if l.Scheduler == nil {
+		return "error scheduler disabled"
+	}
+	params, text, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(message), schedulePrefix), " ")
+	flags := strings.Split(params, ",")
+	location := l.Location
+	if location == nil {
+		location = time.UTC
+	}
+	at, err := bot.ParseScheduleTime(flags[0], time.Now(), location)
+	if err != nil {
+		log.Printf("[WARN] can't schedule rtjc message %q, %v", message, err)
+		return "error bad time"
+	}
+	post := bot.ScheduledPost{At: at, Text: strings.TrimSpace(text), Author: "rtjc"}
+	for _, f := range flags[1:] {
+		switch f {
+		case "pin":
+			post.Pin = true
+		case "html":
+			post.HTML = true
+		}
+	}
+	id, err := l.Scheduler.Schedule(post)
+	if err != nil {
+		log.Printf("[WARN] can't schedule rtjc message %q, %v", message, err)
+		return "error " + err.Error()
+	}
+	log.Printf("[INFO] rtjc post %s scheduled at %v", id, at)
+	return "scheduled " + id
+}
+
+// sendSummary posts summaries for the topic message, as replies to replyTo message if it is set
+func (l Rtjc) sendSummary(ctx context.Context, msg string, replyTo int) {
+	if !isTopic(msg) {
 		return
 	}
 
@@ -93,23 +185,72 @@ func (l Rtjc) sendSummary(ctx context.Context, msg string) {
 		summaryMsgs = summaryMsgs[:5]
 	}
 
-	// by default, rate limit to 15 messages per 2 minutes (1 per 8 sec)
-	// telegram asks 30 sec of waiting after sending 20 messages
-	rl := rate.NewLimiter(l.SubmitRateLimit, l.SubmitRateBurst)
+	nonEmpty := make([]string, 0, len(summaryMsgs))
 	for i, sumMsg := range summaryMsgs {
 		if sumMsg == "" {
 			log.Printf("[WARN] empty summary item #%d for %q", i, msg)
 			continue
 		}
+		nonEmpty = append(nonEmpty, sumMsg)
+	}
+	if l.CollapseSummaries && replyTo != 0 {
+		nonEmpty = collapseSummaries(nonEmpty)
+	}
+
+	// by default, rate limit to 15 messages per 2 minutes (1 per 8 sec)
+	// telegram asks 30 sec of waiting after sending 20 messages
+	rl := rate.NewLimiter(l.SubmitRateLimit, l.SubmitRateBurst)
+	for _, sumMsg := range nonEmpty {
 		if err := rl.Wait(ctx); err != nil {
 			log.Printf("[WARN] can't wait for rate limit, %v", err)
 		}
-		if err := l.Submitter.SubmitHTML(ctx, sumMsg, false); err != nil {
+		var err error
+		switch {
+		case replyTo != 0 && l.CollapseSummaries:
+			err = l.Submitter.SubmitHTMLQuote(ctx, sumMsg, replyTo)
+		case replyTo != 0:
+			err = l.Submitter.SubmitHTMLReply(ctx, sumMsg, replyTo)
+		default:
+			err = l.Submitter.SubmitHTML(ctx, sumMsg, false)
+		}
+		if err != nil {
 			log.Printf("[WARN] can't send summary, %v", err)
 		}
 	}
 }
 
+// maxCollapsedSummaryLen is a limit for collapsed summaries message, leaves some room
+// for the quote markup within telegram's limit of 4096 characters
+const maxCollapsedSummaryLen = 4000
+
+// collapseSummaries joins summaries to as few messages as possible, each within maxCollapsedSummaryLen.
+// Summary too long to fit is kept as a separate message.
+func collapseSummaries(summaries []string) []string {
+	res := []string{}
+	current := ""
+	for _, s := range summaries {
+		if current == "" {
+			current = s
+			continue
+		}
+		if len([]rune(current))+len([]rune(s))+2 > maxCollapsedSummaryLen {
+			res = append(res, current)
+			current = s
+			continue
+		}
+		current += "\n\n" + s
+	}
+	if current != "" {
+		res = append(res, current)
+	}
+	return res
+}
+
+// isTopic checks if the message announces a new topic, news.radio-t.com prefixes them with "⚠"
+func isTopic(msg string) bool {
+	return strings.HasPrefix(msg, "⚠")
+}
+
 func (l Rtjc) isPinned(msg string) (ok bool, m string) {
 	cleanedMsg := strings.TrimSpace(msg)
 	cleanedMsg = strings.TrimSuffix(cleanedMsg, "\n")
@@ -3,14 +3,17 @@ package events
 import (
 	"bytes"
 	"context"
+	"errors"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-pkgz/syncs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/radio-t/super-bot/app/bot"
 	"github.com/radio-t/super-bot/app/events/mocks"
 )
 
@@ -49,11 +52,11 @@ func makeTestingRtjc(submitter *mocks.Submitter, summarizer *mocks.Summarizer) R
 
 func TestRtjc_ReadMessage(t *testing.T) {
 	tbl := []struct {
-		name            string
-		input           string
-		callsSubmit     int
-		callsSubmitHTML int
-		callsSummary    int
+		name                 string
+		input                string
+		callsSubmit          int
+		callsSubmitHTMLReply int
+		callsSummary         int
 	}{
 		{"Begin", "⚠️ Вещание подкаста началось - https://stream.radio-t.com/", 1, 0, 1},
 		{"New theme", "⚠️ blah blah - https://link.example.com", 1, 1, 1},
@@ -64,10 +67,11 @@ func TestRtjc_ReadMessage(t *testing.T) {
 	for _, tt := range tbl {
 		t.Run(tt.name, func(t *testing.T) {
 			sb := &mocks.Submitter{
-				SubmitFunc: func(ctx context.Context, text string, pin bool) error {
-					return nil
+				SubmitFunc: func(ctx context.Context, text string, pin bool) (int, error) {
+					return 42, nil
 				},
-				SubmitHTMLFunc: func(ctx context.Context, text string, pin bool) error {
+				SubmitHTMLReplyFunc: func(ctx context.Context, text string, replyTo int) error {
+					assert.Equal(t, 42, replyTo)
 					return nil
 				},
 			}
@@ -102,7 +106,7 @@ func TestRtjc_ReadMessage(t *testing.T) {
 			if tt.callsSubmit == 1 {
 				assert.Contains(t, sb.SubmitCalls()[0].Text, tt.input)
 			}
-			assert.Equal(t, tt.callsSubmitHTML, len(sb.SubmitHTMLCalls()))
+			assert.Equal(t, tt.callsSubmitHTMLReply, len(sb.SubmitHTMLReplyCalls()))
 			assert.Equal(t, tt.callsSummary, len(sm.GetSummariesByMessageCalls()))
 		})
 	}
@@ -127,8 +131,8 @@ func TestRtjc_SendSummary(t *testing.T) {
 	for _, tt := range tbl {
 		t.Run(tt.name, func(t *testing.T) {
 			sb := &mocks.Submitter{
-				SubmitFunc: func(ctx context.Context, text string, pin bool) error {
-					return nil
+				SubmitFunc: func(ctx context.Context, text string, pin bool) (int, error) {
+					return 42, nil
 				},
 				SubmitHTMLFunc: func(ctx context.Context, text string, pin bool) error {
 					return nil
@@ -142,7 +146,7 @@ func TestRtjc_SendSummary(t *testing.T) {
 			}
 
 			rtjc := makeTestingRtjc(sb, sm)
-			rtjc.sendSummary(context.Background(), tt.input+"\n")
+			rtjc.sendSummary(context.Background(), tt.input+"\n", 0)
 			rtjc.Swg.Wait()
 
 			assert.Equal(t, tt.callsSubmit, len(sb.SubmitCalls()))
@@ -164,3 +168,193 @@ func TestRtjc_SendSummary(t *testing.T) {
 		})
 	}
 }
+
+func TestRtjc_EditTopics(t *testing.T) {
+	tbl := []struct {
+		name                 string
+		input                string
+		callsSubmit          int
+		callsSubmitTopic     int
+		callsSubmitHTMLReply int
+	}{
+		{"Begin", "⚠️ Официальный кАт! - https://stream.radio-t.com/", 1, 0, 0},
+		{"New theme", "⚠️ blah blah - https://link.example.com", 0, 1, 1},
+		{"Blah", "blah blah - https://link.example.com", 1, 0, 0},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := &mocks.Submitter{
+				SubmitFunc: func(ctx context.Context, text string, pin bool) (int, error) {
+					return 42, nil
+				},
+				SubmitTopicFunc: func(ctx context.Context, text string) (int, error) {
+					return 42, nil
+				},
+				SubmitHTMLReplyFunc: func(ctx context.Context, text string, replyTo int) error {
+					assert.Equal(t, 42, replyTo)
+					return nil
+				},
+			}
+			sm := &mocks.Summarizer{
+				GetSummariesByMessageFunc: func(link string) (messages []string, err error) {
+					if strings.Contains(link, "stream.radio-t.com") {
+						return []string{}, nil
+					}
+					return []string{"summary"}, nil
+				},
+			}
+
+			var buf bytes.Buffer
+			buf.WriteString(tt.input + "\n")
+
+			rtjc := makeTestingRtjc(sb, sm)
+			rtjc.EditTopics = true
+			rtjc.processMessage(context.Background(), &buf)
+			rtjc.Swg.Wait()
+
+			assert.Equal(t, tt.callsSubmit, len(sb.SubmitCalls()))
+			assert.Equal(t, tt.callsSubmitTopic, len(sb.SubmitTopicCalls()))
+			assert.Equal(t, tt.callsSubmitHTMLReply, len(sb.SubmitHTMLReplyCalls()))
+		})
+	}
+}
+
+func TestRtjc_Dedup(t *testing.T) {
+	sb := &mocks.Submitter{
+		SubmitFunc: func(ctx context.Context, text string, pin bool) (int, error) {
+			if strings.HasPrefix(text, "fail") {
+				return 0, errors.New("failed")
+			}
+			return 42, nil
+		},
+	}
+	sm := &mocks.Summarizer{
+		GetSummariesByMessageFunc: func(link string) (messages []string, err error) {
+			return []string{}, nil
+		},
+	}
+	dedup, err := NewDedup("", time.Minute)
+	require.NoError(t, err)
+
+	rtjc := makeTestingRtjc(sb, sm)
+	rtjc.Dedup = dedup
+
+	tbl := []struct {
+		input string
+		ack   string
+		text  string
+	}{
+		{"blah blah - https://link.example.com\n", "ok\n", "blah blah - https://link.example.com\n"},
+		{"blah blah - https://link.example.com\n", "duplicate\n", ""},
+		{"key:k1 blah blah - https://link.example.com\n", "ok\n", "blah blah - https://link.example.com\n"},
+		{"key:k1 something else\n", "duplicate\n", ""},
+		{"fail to send\n", "ok\n", "fail to send\n"},
+		{"fail to send\n", "ok\n", "fail to send\n"}, // failed message is not marked as processed
+	}
+
+	for i, tt := range tbl {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			calls := len(sb.SubmitCalls())
+			var buf bytes.Buffer
+			buf.WriteString(tt.input)
+			rtjc.processMessage(context.Background(), &buf)
+			rtjc.Swg.Wait()
+			assert.Equal(t, tt.ack, buf.String())
+			if tt.text == "" {
+				assert.Equal(t, calls, len(sb.SubmitCalls()))
+				return
+			}
+			require.Equal(t, calls+1, len(sb.SubmitCalls()))
+			assert.Equal(t, tt.text, sb.SubmitCalls()[calls].Text)
+		})
+	}
+}
+
+func TestRtjc_Schedule(t *testing.T) {
+	sb := &mocks.Submitter{}
+	sm := &mocks.Summarizer{}
+	rtjc := makeTestingRtjc(sb, sm)
+
+	var buf bytes.Buffer
+	buf.WriteString("schedule:10m text\n")
+	rtjc.processMessage(context.Background(), &buf)
+	assert.Equal(t, "error scheduler disabled\n", buf.String())
+
+	scheduler, err := NewScheduler("", time.Minute)
+	require.NoError(t, err)
+	rtjc.Scheduler = scheduler
+
+	tbl := []struct {
+		input string
+		ack   string
+	}{
+		{"schedule:10m,pin через 10 минут начинаем\n", "scheduled 1\n"},
+		{"schedule:2100-01-01T20:00:00Z,html <b>text</b>\n", "scheduled 2\n"},
+		{"schedule:blah text\n", "error bad time\n"},
+		{"schedule:10m\n", "error empty post\n"},
+	}
+	for i, tt := range tbl {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			buf.Reset()
+			buf.WriteString(tt.input)
+			rtjc.processMessage(context.Background(), &buf)
+			assert.Equal(t, tt.ack, buf.String())
+		})
+	}
+
+	posts := scheduler.Scheduled()
+	require.Equal(t, 2, len(posts))
+	assert.Equal(t, "через 10 минут начинаем", posts[0].Text)
+	assert.True(t, posts[0].Pin)
+	assert.False(t, posts[0].HTML)
+	assert.Equal(t, "<b>text</b>", posts[1].Text)
+	assert.True(t, posts[1].HTML)
+	assert.Equal(t, 0, len(sb.SubmitCalls()))
+}
+
+func TestRtjc_ScheduleLocation(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+	scheduler, err := NewScheduler("", time.Minute)
+	require.NoError(t, err)
+	rtjc := makeTestingRtjc(&mocks.Submitter{}, &mocks.Summarizer{})
+	rtjc.Scheduler, rtjc.Location = scheduler, location
+
+	var buf bytes.Buffer
+	buf.WriteString("schedule:23:00 from rtjc\n")
+	rtjc.processMessage(context.Background(), &buf)
+	require.Equal(t, "scheduled 1\n", buf.String())
+
+	chatCmd := bot.NewSchedule(scheduler, SuperUser{"admin"}, location)
+	resp := chatCmd.OnMessage(bot.Message{Text: "schedule! 23:00 from chat", From: bot.User{Username: "admin"}})
+	require.True(t, strings.HasPrefix(resp.Text, "пост 2 будет опубликован"), resp.Text)
+
+	posts := scheduler.Scheduled()
+	require.Equal(t, 2, len(posts))
+	assert.Equal(t, posts[0].At, posts[1].At, "same time from rtjc and chat command")
+	assert.Equal(t, "23:00", posts[0].At.In(location).Format("15:04"))
+}
+
+func TestRtjc_SendSummaryCollapsed(t *testing.T) {
+	sb := &mocks.Submitter{
+		SubmitHTMLQuoteFunc: func(ctx context.Context, text string, replyTo int) error {
+			return nil
+		},
+	}
+	sm := &mocks.Summarizer{
+		GetSummariesByMessageFunc: func(link string) (messages []string, err error) {
+			return []string{"summary1", "", "summary2", strings.Repeat("a", maxCollapsedSummaryLen)}, nil
+		},
+	}
+
+	rtjc := makeTestingRtjc(sb, sm)
+	rtjc.CollapseSummaries = true
+	rtjc.sendSummary(context.Background(), "⚠️ blah blah - https://link.example.com\n", 42)
+
+	require.Equal(t, 2, len(sb.SubmitHTMLQuoteCalls()))
+	assert.Equal(t, "summary1\n\nsummary2", sb.SubmitHTMLQuoteCalls()[0].Text)
+	assert.Equal(t, 42, sb.SubmitHTMLQuoteCalls()[0].ReplyTo)
+	assert.Equal(t, strings.Repeat("a", maxCollapsedSummaryLen), sb.SubmitHTMLQuoteCalls()[1].Text)
+	assert.Equal(t, 42, sb.SubmitHTMLQuoteCalls()[1].ReplyTo)
+}
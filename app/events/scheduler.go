@@ -0,0 +1,211 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// Scheduler keeps scheduled posts in a json file and submits them to the chat at the due time
+type Scheduler struct {
+	Submitter submitter
+
+	path          string
+	checkInterval time.Duration
+	nowFn         func() time.Time // for testing
+
+	mu      sync.Mutex
+	posts   []bot.ScheduledPost
+	lastID  int
+	retries map[string]retry // failed posts by id, not persisted
+}
+
+// retry keeps delivery attempts of the failed post
+type retry struct {
+	attempts int
+	next     time.Time
+}
+
+// maxRetryDelay limits backoff between attempts to submit the failed post
+const maxRetryDelay = time.Hour
+
+// schedulerState is stored in the state file, keeps last id to avoid reusing ids after restart
+type schedulerState struct {
+	LastID int                 `json:"last_id"`
+	Posts  []bot.ScheduledPost `json:"posts"`
+}
+
+// NewScheduler makes Scheduler and loads posts from the state file. Empty path disables persistence.
+func NewScheduler(path string, checkInterval time.Duration) (*Scheduler, error) {
+	res := &Scheduler{path: path, checkInterval: checkInterval, nowFn: time.Now, retries: map[string]retry{}}
+	if path == "" {
+		return res, nil
+	}
+
+	data, err := os.ReadFile(path) // nolint
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return res, nil
+		}
+		return nil, fmt.Errorf("failed to read scheduled posts %s: %w", path, err)
+	}
+	state := schedulerState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse scheduled posts %s: %w", path, err)
+	}
+	res.posts, res.lastID = state.Posts, state.LastID
+	log.Printf("[INFO] scheduled posts loaded from %s, %d posts", path, len(res.posts))
+	return res, nil
+}
+
+// Schedule adds post and returns its id
+func (s *Scheduler) Schedule(post bot.ScheduledPost) (id string, err error) {
+	if post.Text == "" {
+		return "", fmt.Errorf("empty post")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	post.ID = strconv.Itoa(s.lastID)
+	s.posts = append(s.posts, post)
+	if err := s.save(); err != nil {
+		s.posts = s.posts[:len(s.posts)-1]
+		return "", err
+	}
+	return post.ID, nil
+}
+
+// Scheduled returns all pending posts ordered by time
+func (s *Scheduler) Scheduled() []bot.ScheduledPost {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]bot.ScheduledPost, len(s.posts))
+	copy(res, s.posts)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].At.Before(res[j].At) })
+	return res
+}
+
+// Cancel removes pending post by id
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.remove(id) {
+		return fmt.Errorf("post %q not found", id)
+	}
+	return s.save()
+}
+
+// Run delivers due posts every checkInterval, blocks until context canceled
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("[INFO] scheduler started, check every %v", s.checkInterval)
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.deliver(ctx)
+		}
+	}
+}
+
+// deliver submits all due posts. Submitted post removed from the list, failed one kept
+// and retried with the delay doubled on each attempt, up to maxRetryDelay.
+func (s *Scheduler) deliver(ctx context.Context) {
+	for _, p := range s.due() {
+		var err error
+		if p.HTML {
+			_, err = s.Submitter.SubmitHTMLAndWait(ctx, p.Text, p.Pin)
+		} else {
+			_, err = s.Submitter.Submit(ctx, p.Text, p.Pin)
+		}
+		if err != nil {
+			delay := s.retryLater(p.ID)
+			log.Printf("[WARN] can't submit scheduled post %s, retry in %v, %v", p.ID, delay, err)
+			continue
+		}
+		log.Printf("[INFO] scheduled post %s submitted", p.ID)
+		s.delivered(p.ID)
+	}
+}
+
+// due returns posts with time in the past, skipping failed ones waiting for the next attempt
+func (s *Scheduler) due() (res []bot.ScheduledPost) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.nowFn()
+	for _, p := range s.posts {
+		if p.At.After(now) {
+			continue
+		}
+		if r, ok := s.retries[p.ID]; ok && r.next.After(now) {
+			continue
+		}
+		res = append(res, p)
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].At.Before(res[j].At) })
+	return res
+}
+
+// delivered removes submitted post and saves the list
+func (s *Scheduler) delivered(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.remove(id) {
+		return // canceled while submitting
+	}
+	if err := s.save(); err != nil {
+		log.Printf("[WARN] can't save scheduled posts, %v", err)
+	}
+}
+
+// retryLater sets the time of the next attempt to submit the failed post and returns the delay
+func (s *Scheduler) retryLater(id string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.retries[id]
+	delay := s.checkInterval
+	for i := 0; i < r.attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxRetryDelay)
+	r.attempts++
+	r.next = s.nowFn().Add(delay)
+	s.retries[id] = r
+	return delay
+}
+
+// remove deletes post by id, returns false if not found. Caller should hold the lock.
+func (s *Scheduler) remove(id string) bool {
+	for i, p := range s.posts {
+		if p.ID == id {
+			s.posts = append(s.posts[:i], s.posts[i+1:]...)
+			delete(s.retries, id)
+			return true
+		}
+	}
+	return false
+}
+
+// save writes posts to the file, caller should hold the lock
+func (s *Scheduler) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(schedulerState{LastID: s.lastID, Posts: s.posts}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled posts: %w", err)
+	}
+	return writeFileAtomic(s.path, data)
+}
@@ -0,0 +1,171 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+	"github.com/radio-t/super-bot/app/events/mocks"
+)
+
+func TestScheduler_ScheduleListCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduled.json")
+	s, err := NewScheduler(path, time.Second)
+	require.NoError(t, err)
+
+	now := time.Now()
+	id1, err := s.Schedule(bot.ScheduledPost{At: now.Add(time.Hour), Text: "later"})
+	require.NoError(t, err)
+	assert.Equal(t, "1", id1)
+	id2, err := s.Schedule(bot.ScheduledPost{At: now.Add(time.Minute), Text: "sooner", Pin: true})
+	require.NoError(t, err)
+	assert.Equal(t, "2", id2)
+	_, err = s.Schedule(bot.ScheduledPost{At: now.Add(time.Minute)})
+	assert.Error(t, err)
+
+	posts := s.Scheduled()
+	require.Equal(t, 2, len(posts))
+	assert.Equal(t, "sooner", posts[0].Text)
+	assert.Equal(t, "later", posts[1].Text)
+
+	// restored after restart
+	s2, err := NewScheduler(path, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(s2.Scheduled()))
+	require.NoError(t, s2.Cancel("1"))
+	assert.Error(t, s2.Cancel("1"))
+	id3, err := s2.Schedule(bot.ScheduledPost{At: now.Add(time.Hour), Text: "new"})
+	require.NoError(t, err)
+	assert.Equal(t, "3", id3, "ids are not reused")
+
+	s3, err := NewScheduler(path, time.Second)
+	require.NoError(t, err)
+	posts = s3.Scheduled()
+	require.Equal(t, 2, len(posts))
+	assert.Equal(t, "2", posts[0].ID)
+	assert.Equal(t, "3", posts[1].ID)
+}
+
+func TestScheduler_Run(t *testing.T) {
+	sb := &mocks.Submitter{
+		SubmitFunc: func(ctx context.Context, text string, pin bool) (int, error) {
+			if text == "fail" {
+				return 0, fmt.Errorf("failed")
+			}
+			return 1, nil
+		},
+		SubmitHTMLAndWaitFunc: func(ctx context.Context, text string, pin bool) (int, error) {
+			return 2, nil
+		},
+	}
+	s, err := NewScheduler("", 10*time.Millisecond)
+	require.NoError(t, err)
+	s.Submitter = sb
+
+	now := time.Now()
+	_, err = s.Schedule(bot.ScheduledPost{At: now.Add(-time.Second), Text: "due", Pin: true})
+	require.NoError(t, err)
+	_, err = s.Schedule(bot.ScheduledPost{At: now.Add(-time.Minute), Text: "<b>due html</b>", HTML: true})
+	require.NoError(t, err)
+	_, err = s.Schedule(bot.ScheduledPost{At: now.Add(-time.Second), Text: "fail"})
+	require.NoError(t, err)
+	_, err = s.Schedule(bot.ScheduledPost{At: now.Add(time.Hour), Text: "later"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	require.Greater(t, len(sb.SubmitCalls()), 2, "failed post retried")
+	assert.Equal(t, "due", sb.SubmitCalls()[0].Text)
+	assert.True(t, sb.SubmitCalls()[0].Pin)
+	for _, c := range sb.SubmitCalls()[1:] {
+		assert.Equal(t, "fail", c.Text)
+	}
+	require.Equal(t, 1, len(sb.SubmitHTMLAndWaitCalls()))
+	assert.Equal(t, "<b>due html</b>", sb.SubmitHTMLAndWaitCalls()[0].Text)
+
+	posts := s.Scheduled()
+	require.Equal(t, 2, len(posts), "failed post kept")
+	assert.Equal(t, "fail", posts[0].Text)
+	assert.Equal(t, "later", posts[1].Text)
+}
+
+func TestScheduler_Retry(t *testing.T) {
+	fail := true
+	sb := &mocks.Submitter{
+		SubmitFunc: func(ctx context.Context, text string, pin bool) (int, error) {
+			if fail {
+				return 0, fmt.Errorf("failed")
+			}
+			return 1, nil
+		},
+	}
+	path := filepath.Join(t.TempDir(), "scheduled.json")
+	s, err := NewScheduler(path, time.Minute)
+	require.NoError(t, err)
+	s.Submitter = sb
+	now := time.Date(2024, 5, 18, 20, 0, 0, 0, time.UTC)
+	s.nowFn = func() time.Time { return now }
+
+	_, err = s.Schedule(bot.ScheduledPost{At: now.Add(-time.Second), Text: "post"})
+	require.NoError(t, err)
+
+	s.deliver(context.Background())
+	require.Equal(t, 1, len(sb.SubmitCalls()))
+	s2, err := NewScheduler(path, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(s2.Scheduled()), "failed post kept in the state file")
+
+	now = now.Add(30 * time.Second)
+	s.deliver(context.Background())
+	assert.Equal(t, 1, len(sb.SubmitCalls()), "not retried before the delay")
+
+	now = now.Add(30 * time.Second)
+	s.deliver(context.Background())
+	assert.Equal(t, 2, len(sb.SubmitCalls()), "retried after the delay")
+
+	now = now.Add(time.Minute)
+	s.deliver(context.Background())
+	assert.Equal(t, 2, len(sb.SubmitCalls()), "delay doubled")
+
+	fail = false
+	now = now.Add(time.Minute)
+	s.deliver(context.Background())
+	assert.Equal(t, 3, len(sb.SubmitCalls()))
+	assert.Empty(t, s.Scheduled(), "submitted post removed")
+	s2, err = NewScheduler(path, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, s2.Scheduled())
+}
+
+func TestScheduler_RetryHTML(t *testing.T) {
+	sb := &mocks.Submitter{
+		SubmitHTMLAndWaitFunc: func(ctx context.Context, text string, pin bool) (int, error) {
+			return 0, fmt.Errorf("message not posted")
+		},
+	}
+	s, err := NewScheduler("", time.Minute)
+	require.NoError(t, err)
+	s.Submitter = sb
+	now := time.Date(2024, 5, 18, 20, 0, 0, 0, time.UTC)
+	s.nowFn = func() time.Time { return now }
+
+	_, err = s.Schedule(bot.ScheduledPost{At: now.Add(-time.Second), Text: "<b>post</b>", HTML: true, Pin: true})
+	require.NoError(t, err)
+
+	s.deliver(context.Background())
+	require.Equal(t, 1, len(sb.SubmitHTMLAndWaitCalls()))
+	assert.True(t, sb.SubmitHTMLAndWaitCalls()[0].Pin)
+	require.Equal(t, 1, len(s.Scheduled()), "failed html post kept")
+
+	now = now.Add(time.Minute)
+	s.deliver(context.Background())
+	assert.Equal(t, 2, len(sb.SubmitHTMLAndWaitCalls()), "failed html post retried")
+}
@@ -36,8 +36,16 @@ type TelegramListener struct {
 
 	msgs struct {
 		once sync.Once
-		ch   chan bot.Response
+		ch   chan submission
 	}
+	topic topicMessage // current topic message, edited on each new topic
+}
+
+// submission is a message from outside clients, like rtjc, to be sent by the listener
+type submission struct {
+	resp   bot.Response
+	topic  bool     // edit current topic message instead of posting a new one
+	result chan int // optional, receives id of posted message or 0 on failure
 }
 
 type tbAPI interface {
@@ -49,6 +57,7 @@ type tbAPI interface {
 
 type msgLogger interface {
 	Save(msg *bot.Message)
+	Delete(msgID int)
 }
 
 // Do process all events, blocked call
@@ -61,7 +70,7 @@ func (l *TelegramListener) Do(ctx context.Context) error {
 	}
 
 	l.msgs.once.Do(func() {
-		l.msgs.ch = make(chan bot.Response, 100)
+		l.msgs.ch = make(chan submission, 100)
 		if l.IdleDuration == 0 {
 			l.IdleDuration = 30 * time.Second
 		}
@@ -119,9 +128,8 @@ func (l *TelegramListener) Do(ctx context.Context) error {
 				if err := l.banUserOrChannel(permBanDuration, fromChat, 0, msg.SenderChat.ID); err != nil {
 					log.Printf("[ERROR] can't ban channel/group: %v", err)
 				}
-				_, err := l.TbAPI.Request(tbapi.DeleteMessageConfig{ChatID: l.chatID, MessageID: update.Message.MessageID})
-				if err != nil {
-					log.Printf("[WARN] failed to delete message %d, %v", update.Message.MessageID, err)
+				if err := l.deleteMessage(update.Message.MessageID); err != nil {
+					log.Printf("[WARN] %v", err)
 				}
 				continue
 			}
@@ -170,16 +178,13 @@ func (l *TelegramListener) Do(ctx context.Context) error {
 
 			// delete message if requested by bot
 			if resp.DeleteReplyTo && resp.ReplyTo != 0 {
-				_, err := l.TbAPI.Request(tbapi.DeleteMessageConfig{ChatID: l.chatID, MessageID: resp.ReplyTo})
-				if err != nil {
-					log.Printf("[WARN] failed to delete message %d, %v", resp.ReplyTo, err)
+				if err := l.deleteMessage(resp.ReplyTo); err != nil {
+					log.Printf("[WARN] %v", err)
 				}
 			}
 
-		case resp := <-l.msgs.ch: // publish messages from outside clients
-			if err := l.sendBotResponse(resp, l.chatID); err != nil {
-				log.Printf("[WARN] failed to respond on rtjc event, %v", err)
-			}
+		case sub := <-l.msgs.ch: // publish messages from outside clients
+			l.sendSubmission(sub)
 
 		case <-time.After(l.IdleDuration): // hit bots on idle timeout
 			resp := l.Bots.OnMessage(bot.Message{Text: "idle"})
@@ -239,10 +244,73 @@ func (l *TelegramListener) botActivityBan(resp bot.Response, msg bot.Message, fr
 	return false
 }
 
+// sendSubmission publishes message from outside clients and reports posted message id if requested
+func (l *TelegramListener) sendSubmission(sub submission) {
+	var msgID int
+	var err error
+	if sub.topic {
+		msgID, err = l.sendTopic(sub.resp.Text)
+	} else {
+		if sub.resp.Pin {
+			l.topic.reset() // pinned message starts a new show, topics collected from scratch
+		}
+		msgID, err = l.sendBotResponseWithID(sub.resp, l.chatID)
+	}
+	if err != nil {
+		log.Printf("[WARN] failed to respond on rtjc event, %v", err)
+	}
+	if sub.result != nil {
+		sub.result <- msgID
+	}
+}
+
+// sendTopic posts a new topic message or edits the current one, keeping the list of past topics in it
+func (l *TelegramListener) sendTopic(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	if l.topic.msgID == 0 {
+		msgID, err := l.sendBotResponseWithID(bot.Response{Text: text, Send: true, Preview: true}, l.chatID)
+		if err != nil {
+			return 0, err
+		}
+		l.topic.start(msgID, text)
+		return msgID, nil
+	}
+
+	l.topic.add(text)
+	edit := tbapi.NewEditMessageText(l.chatID, l.topic.msgID, l.topic.render())
+	edit.ParseMode = tbapi.ModeMarkdown
+	res, err := l.TbAPI.Send(edit)
+	if err != nil && strings.Contains(err.Error(), "Bad Request: can't parse entities:") {
+		log.Printf("[WARN] failed to edit topic message as markdown, retrying as plain text. Error: %v", err)
+		edit.ParseMode = ""
+		res, err = l.TbAPI.Send(edit)
+	}
+	if err != nil {
+		// message could be deleted or too old to edit, post the topic as a new message
+		log.Printf("[WARN] can't edit topic message %d, posting new one: %v", l.topic.msgID, err)
+		l.topic.reset()
+		return l.sendTopic(text)
+	}
+
+	// log only the new topic, the rest of the edited message is already in the log.
+	// The edit result keeps id and time of the original message, so the topic gets its own.
+	msg := l.transform(&res)
+	msg.ID, msg.Sent = l.topic.editID(), time.Now()
+	msg.Text, msg.Entities = text, nil
+	l.MsgLogger.Save(msg)
+	return l.topic.msgID, nil
+}
+
 // sendBotResponse sends bot's answer to tg channel and saves it to log
 func (l *TelegramListener) sendBotResponse(resp bot.Response, chatID int64) error {
+	_, err := l.sendBotResponseWithID(resp, chatID)
+	return err
+}
+
+// sendBotResponseWithID sends bot's answer to tg channel, saves it to log and returns id of posted message
+func (l *TelegramListener) sendBotResponseWithID(resp bot.Response, chatID int64) (int, error) {
 	if !resp.Send {
-		return nil
+		return 0, nil
 	}
 
 	log.Printf("[DEBUG] bot response - %+v, pin: %t, reply-to:%d, parse-mode:%s", resp.Text, resp.Pin, resp.ReplyTo, resp.ParseMode)
@@ -250,7 +318,7 @@ func (l *TelegramListener) sendBotResponse(resp bot.Response, chatID int64) erro
 	res, err := l.sendMdWithFallback(resp, chatID)
 
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return 0, fmt.Errorf("failed to send message: %w", err)
 	}
 
 	l.saveBotMessage(&res, chatID)
@@ -258,18 +326,18 @@ func (l *TelegramListener) sendBotResponse(resp bot.Response, chatID int64) erro
 	if resp.Pin {
 		_, err = l.TbAPI.Request(tbapi.PinChatMessageConfig{ChatID: chatID, MessageID: res.MessageID, DisableNotification: true})
 		if err != nil {
-			return fmt.Errorf("can't pin message to telegram: %w", err)
+			return res.MessageID, fmt.Errorf("can't pin message to telegram: %w", err)
 		}
 	}
 
 	if resp.Unpin {
 		_, err = l.TbAPI.Request(tbapi.UnpinChatMessageConfig{ChatID: chatID})
 		if err != nil {
-			return fmt.Errorf("can't unpin message to telegram: %w", err)
+			return res.MessageID, fmt.Errorf("can't unpin message to telegram: %w", err)
 		}
 	}
 
-	return nil
+	return res.MessageID, nil
 }
 
 // sendMdWithFallback sends message with markdown mode and fallback to plain text
@@ -327,28 +395,68 @@ func (l *TelegramListener) applyBan(msg bot.Message, duration time.Duration, cha
 	return nil
 }
 
-// Submit message text to telegram's group
-func (l *TelegramListener) Submit(ctx context.Context, text string, pin bool) error {
-	l.msgs.once.Do(func() { l.msgs.ch = make(chan bot.Response, 100) })
-
-	select {
-	case <-ctx.Done():
-		return fmt.Errorf("submit operation canceled: %w", ctx.Err())
-	case l.msgs.ch <- bot.Response{Text: text, Pin: pin, Send: true, Preview: true}:
-	}
-	return nil
+// Submit message text to telegram's group. Waits for the message to be sent and returns its id.
+func (l *TelegramListener) Submit(ctx context.Context, text string, pin bool) (msgID int, err error) {
+	return l.submitAndWait(ctx, submission{resp: bot.Response{Text: text, Pin: pin, Send: true, Preview: true}})
 }
 
 // SubmitHTML message to telegram's group with HTML mode
 func (l *TelegramListener) SubmitHTML(ctx context.Context, text string, pin bool) error {
 	// remove unsupported HTML tags
 	text = notify.TelegramSupportedHTML(text)
-	l.msgs.once.Do(func() { l.msgs.ch = make(chan bot.Response, 100) })
+	return l.submit(ctx, submission{resp: bot.Response{Text: text, Pin: pin, Send: true, ParseMode: tbapi.ModeHTML, Preview: false}})
+}
+
+// SubmitHTMLAndWait submits message in HTML mode and waits for the listener to post it, returns id of posted message
+func (l *TelegramListener) SubmitHTMLAndWait(ctx context.Context, text string, pin bool) (msgID int, err error) {
+	text = notify.TelegramSupportedHTML(text)
+	return l.submitAndWait(ctx, submission{resp: bot.Response{Text: text, Pin: pin, Send: true, ParseMode: tbapi.ModeHTML, Preview: false}})
+}
+
+// SubmitHTMLReply message to telegram's group with HTML mode as a reply to replyTo message
+func (l *TelegramListener) SubmitHTMLReply(ctx context.Context, text string, replyTo int) error {
+	text = notify.TelegramSupportedHTML(text)
+	return l.submit(ctx, submission{resp: bot.Response{Text: text, Send: true, ParseMode: tbapi.ModeHTML, ReplyTo: replyTo}})
+}
+
+// SubmitHTMLQuote message to telegram's group with HTML mode as an expandable blockquote replying to replyTo message
+func (l *TelegramListener) SubmitHTMLQuote(ctx context.Context, text string, replyTo int) error {
+	// expandable attribute is not allowed by sanitizer, so the text is sanitized before wrapping
+	text = "<blockquote expandable>" + notify.TelegramSupportedHTML(text) + "</blockquote>"
+	return l.submit(ctx, submission{resp: bot.Response{Text: text, Send: true, ParseMode: tbapi.ModeHTML, ReplyTo: replyTo}})
+}
+
+// SubmitTopic posts the topic to telegram's group by editing the current topic message,
+// or as a new message if there is no current one. Waits for the message to be sent and returns its id.
+func (l *TelegramListener) SubmitTopic(ctx context.Context, text string) (msgID int, err error) {
+	return l.submitAndWait(ctx, submission{resp: bot.Response{Text: text, Send: true, Preview: true}, topic: true})
+}
+
+// submitAndWait submits message and waits for the listener to post it, returns id of posted message
+func (l *TelegramListener) submitAndWait(ctx context.Context, sub submission) (msgID int, err error) {
+	sub.result = make(chan int, 1)
+	if err = l.submit(ctx, sub); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, fmt.Errorf("submit operation canceled: %w", ctx.Err())
+	case msgID = <-sub.result:
+	}
+	if msgID == 0 {
+		return 0, fmt.Errorf("message %q not posted", sub.resp.Text)
+	}
+	return msgID, nil
+}
+
+func (l *TelegramListener) submit(ctx context.Context, sub submission) error {
+	l.msgs.once.Do(func() { l.msgs.ch = make(chan submission, 100) })
 
 	select {
 	case <-ctx.Done():
 		return fmt.Errorf("submit operation canceled: %w", ctx.Err())
-	case l.msgs.ch <- bot.Response{Text: text, Pin: pin, Send: true, ParseMode: tbapi.ModeHTML, Preview: false}:
+	case l.msgs.ch <- sub:
 	}
 	return nil
 }
@@ -367,6 +475,16 @@ func (l *TelegramListener) getChatID(group string) (int64, error) {
 	return chat.ID, nil
 }
 
+// deleteMessage deletes message from the chat and drops it from the chat log.
+// The message dropped from the log even if telegram request failed, the bot decided it shouldn't be there.
+func (l *TelegramListener) deleteMessage(msgID int) error {
+	l.MsgLogger.Delete(msgID)
+	if _, err := l.TbAPI.Request(tbapi.DeleteMessageConfig{ChatID: l.chatID, MessageID: msgID}); err != nil {
+		return fmt.Errorf("failed to delete message %d: %w", msgID, err)
+	}
+	return nil
+}
+
 func (l *TelegramListener) saveBotMessage(msg *tbapi.Message, fromChat int64) {
 	if fromChat != l.chatID {
 		return
@@ -471,6 +589,7 @@ func (l *TelegramListener) transform(msg *tbapi.Message) *bot.Message {
 
 	// fill in the message's reply-to message
 	if msg.ReplyToMessage != nil {
+		message.ReplyTo.ID = msg.ReplyToMessage.MessageID
 		message.ReplyTo.Text = msg.ReplyToMessage.Text
 		message.ReplyTo.Sent = msg.ReplyToMessage.Time()
 		if msg.ReplyToMessage.From != nil {
@@ -35,6 +35,7 @@ func TestTelegramListener_DoNoBots(t *testing.T) {
 	updMsg := tbapi.Update{
 		Message: &tbapi.Message{
 			ReplyToMessage: &tbapi.Message{
+				MessageID: 77,
 				SenderChat: &tbapi.Chat{
 					ID:        4321,
 					UserName:  "another_user",
@@ -62,6 +63,7 @@ func TestTelegramListener_DoNoBots(t *testing.T) {
 	assert.Equal(t, 1, len(mockLogger.SaveCalls()))
 	assert.Equal(t, "text 123", mockLogger.SaveCalls()[0].Msg.Text)
 	assert.Equal(t, "user", mockLogger.SaveCalls()[0].Msg.From.Username)
+	assert.Equal(t, 77, mockLogger.SaveCalls()[0].Msg.ReplyTo.ID)
 }
 
 func TestTelegramListener_DoWithBots(t *testing.T) {
@@ -124,7 +126,7 @@ func TestTelegramListener_DoWithRtjc(t *testing.T) {
 		},
 		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) {
 			if c.(tbapi.MessageConfig).Text == "rtjc message" {
-				return tbapi.Message{Text: c.(tbapi.MessageConfig).Text, From: &tbapi.User{UserName: "user"}}, nil
+				return tbapi.Message{MessageID: 42, Text: c.(tbapi.MessageConfig).Text, From: &tbapi.User{UserName: "user"}}, nil
 			}
 			return tbapi.Message{}, nil
 		},
@@ -149,7 +151,9 @@ func TestTelegramListener_DoWithRtjc(t *testing.T) {
 	mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
 
 	time.AfterFunc(time.Millisecond*50, func() {
-		assert.NoError(t, l.Submit(ctx, "rtjc message", true))
+		msgID, err := l.Submit(ctx, "rtjc message", true)
+		assert.NoError(t, err)
+		assert.Equal(t, 42, msgID)
 	})
 
 	err := l.Do(ctx)
@@ -161,8 +165,105 @@ func TestTelegramListener_DoWithRtjc(t *testing.T) {
 	assert.Equal(t, int64(123), mockAPI.RequestCalls()[0].C.(tbapi.PinChatMessageConfig).ChatID)
 }
 
-func TestTelegramListener_DoWithAutoBan(t *testing.T) {
+func TestTelegramListener_SubmitHTMLAndWaitFailed(t *testing.T) {
 	mockLogger := &msgLoggerMock{SaveFunc: func(msg *bot.Message) {}}
+	mockAPI := &tbAPIMock{
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.Chat, error) {
+			return tbapi.Chat{ID: 123}, nil
+		},
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) {
+			return tbapi.Message{}, fmt.Errorf("send failed")
+		},
+	}
+	l := TelegramListener{MsgLogger: mockLogger, TbAPI: mockAPI, Bots: &bot.InterfaceMock{}, Group: "gr"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	updChan := make(chan tbapi.Update, 1)
+	mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
+
+	done := make(chan struct{})
+	time.AfterFunc(time.Millisecond*50, func() {
+		defer close(done)
+		msgID, err := l.SubmitHTMLAndWait(ctx, "<b>scheduled</b>", false)
+		assert.Error(t, err)
+		assert.Zero(t, msgID)
+	})
+
+	err := l.Do(ctx)
+	assert.Contains(t, err.Error(), "context deadline exceeded")
+	<-done
+	require.Equal(t, 1, len(mockAPI.SendCalls()))
+	assert.Equal(t, tbapi.ModeHTML, mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).ParseMode)
+	assert.Empty(t, mockLogger.SaveCalls())
+}
+
+func TestTelegramListener_DoWithRtjcTopics(t *testing.T) {
+	mockLogger := &msgLoggerMock{SaveFunc: func(msg *bot.Message) {}}
+	mockAPI := &tbAPIMock{
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.Chat, error) {
+			return tbapi.Chat{ID: 123}, nil
+		},
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) {
+			switch m := c.(type) {
+			case tbapi.MessageConfig:
+				return tbapi.Message{MessageID: 42, Text: m.Text, Chat: &tbapi.Chat{ID: 123}}, nil
+			case tbapi.EditMessageTextConfig:
+				return tbapi.Message{MessageID: m.MessageID, Text: m.Text, Chat: &tbapi.Chat{ID: 123}}, nil
+			}
+			return tbapi.Message{}, fmt.Errorf("unexpected %T", c)
+		},
+	}
+
+	l := TelegramListener{
+		MsgLogger: mockLogger,
+		TbAPI:     mockAPI,
+		Bots:      &bot.InterfaceMock{},
+		Group:     "gr",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	updChan := make(chan tbapi.Update, 1)
+	mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
+
+	time.AfterFunc(time.Millisecond*50, func() {
+		msgID, err := l.SubmitTopic(ctx, "⚠️ topic 1")
+		assert.NoError(t, err)
+		assert.Equal(t, 42, msgID)
+		msgID, err = l.SubmitTopic(ctx, "⚠️ topic 2\n")
+		assert.NoError(t, err)
+		assert.Equal(t, 42, msgID)
+		assert.NoError(t, l.SubmitHTMLReply(ctx, "summary", msgID))
+		assert.NoError(t, l.SubmitHTMLQuote(ctx, "<b>summary</b> <div>quote</div>", msgID))
+	})
+
+	err := l.Do(ctx)
+	assert.Contains(t, err.Error(), "context deadline exceeded")
+	require.Equal(t, 4, len(mockAPI.SendCalls()))
+	assert.Equal(t, "⚠️ topic 1", mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).Text)
+	edit := mockAPI.SendCalls()[1].C.(tbapi.EditMessageTextConfig)
+	assert.Equal(t, 42, edit.MessageID)
+	assert.Equal(t, "⚠️ topic 2\n\nПредыдущие темы:\n• ⚠️ topic 1", edit.Text)
+	assert.Equal(t, 42, mockAPI.SendCalls()[2].C.(tbapi.MessageConfig).ReplyToMessageID)
+	quote := mockAPI.SendCalls()[3].C.(tbapi.MessageConfig)
+	assert.Equal(t, 42, quote.ReplyToMessageID)
+	assert.Equal(t, "<blockquote expandable><b>summary</b> quote</blockquote>", quote.Text)
+	assert.Equal(t, tbapi.ModeHTML, quote.ParseMode)
+
+	require.Equal(t, 4, len(mockLogger.SaveCalls()))
+	assert.Equal(t, "⚠️ topic 1", mockLogger.SaveCalls()[0].Msg.Text)
+	assert.Equal(t, "⚠️ topic 2", mockLogger.SaveCalls()[1].Msg.Text)
+	assert.Equal(t, 42, mockLogger.SaveCalls()[0].Msg.ID)
+	assert.Less(t, mockLogger.SaveCalls()[1].Msg.ID, 0, "edited topic logged under its own id")
+	assert.WithinDuration(t, time.Now(), mockLogger.SaveCalls()[1].Msg.Sent, time.Minute)
+	assert.NotEqual(t, mockLogger.SaveCalls()[0].Msg.Sent, mockLogger.SaveCalls()[1].Msg.Sent)
+	assert.Equal(t, "summary", mockLogger.SaveCalls()[2].Msg.Text)
+}
+
+func TestTelegramListener_DoWithAutoBan(t *testing.T) {
+	mockLogger := &msgLoggerMock{SaveFunc: func(msg *bot.Message) {}, DeleteFunc: func(msgID int) {}}
 	firstReq := true
 	firstSend := true
 	mockAPI := &tbAPIMock{
@@ -266,6 +367,7 @@ func TestTelegramListener_DoWithAutoBan(t *testing.T) {
 		assert.EqualError(t, err, "telegram update chan closed")
 
 		assert.Equal(t, 1, len(mockAPI.SendCalls()))
+		assert.Equal(t, 5, len(mockLogger.DeleteCalls()), "channel messages dropped from the chat log")
 	})
 }
 
@@ -413,7 +515,7 @@ func TestTelegramListener_DoWithAllActivityBan(t *testing.T) {
 }
 
 func TestTelegramListener_DoWithBotBan(t *testing.T) {
-	mockLogger := &msgLoggerMock{SaveFunc: func(msg *bot.Message) {}}
+	mockLogger := &msgLoggerMock{SaveFunc: func(msg *bot.Message) {}, DeleteFunc: func(msgID int) {}}
 	mockAPI := &tbAPIMock{
 		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.Chat, error) {
 			return tbapi.Chat{ID: 123}, nil
@@ -675,7 +777,7 @@ func TestTelegramListener_DoNotSaveMessagesFromOtherChats(t *testing.T) {
 }
 
 func TestTelegramListener_DoDeleteMessages(t *testing.T) {
-	mockLogger := &msgLoggerMock{SaveFunc: func(msg *bot.Message) {}}
+	mockLogger := &msgLoggerMock{SaveFunc: func(msg *bot.Message) {}, DeleteFunc: func(msgID int) {}}
 	mockAPI := &tbAPIMock{
 		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.Chat, error) {
 			return tbapi.Chat{ID: 123}, nil
@@ -729,6 +831,9 @@ func TestTelegramListener_DoDeleteMessages(t *testing.T) {
 	require.Equal(t, 1, len(mockAPI.RequestCalls()))
 	assert.Equal(t, 321, mockAPI.RequestCalls()[0].C.(tbapi.DeleteMessageConfig).MessageID)
 	assert.Equal(t, int64(123), mockAPI.RequestCalls()[0].C.(tbapi.DeleteMessageConfig).ChatID)
+	// deleted message dropped from the chat log
+	require.Equal(t, 1, len(mockLogger.DeleteCalls()))
+	assert.Equal(t, 321, mockLogger.DeleteCalls()[0].MsgID)
 }
 
 func TestTelegram_transformTextMessage(t *testing.T) {
@@ -0,0 +1,59 @@
+package events
+
+import (
+	"strings"
+)
+
+// maxTopicMessageLen is telegram's limit for the message text
+const maxTopicMessageLen = 4096
+
+// topicMessage keeps the current-topic message posted by the listener and all topics announced in it.
+// Used by the listener's loop only, not thread safe.
+type topicMessage struct {
+	msgID  int
+	topics []string // all topics in order of arrival, the last one is current
+}
+
+func (t *topicMessage) start(msgID int, topic string) {
+	t.msgID = msgID
+	t.topics = []string{topic}
+}
+
+func (t *topicMessage) add(topic string) {
+	t.topics = append(t.topics, topic)
+}
+
+// editID makes an id for the last topic added by editing the message. It is negative,
+// so it never collides with telegram's message ids, and unique for each topic of the message.
+func (t *topicMessage) editID() int {
+	return -(t.msgID<<16 | (len(t.topics)-1)&0xffff)
+}
+
+func (t *topicMessage) reset() {
+	t.msgID = 0
+	t.topics = nil
+}
+
+// render makes message text with the current topic on top and past topics below, newest first.
+// The oldest topics are dropped if the text doesn't fit into telegram's message limit.
+func (t *topicMessage) render() string {
+	if len(t.topics) == 0 {
+		return ""
+	}
+	current := t.topics[len(t.topics)-1]
+	past := make([]string, 0, len(t.topics)-1)
+	for i := len(t.topics) - 2; i >= 0; i-- {
+		past = append(past, t.topics[i])
+	}
+
+	for {
+		if len(past) == 0 {
+			return current
+		}
+		res := current + "\n\nПредыдущие темы:\n• " + strings.Join(past, "\n• ")
+		if len([]rune(res)) <= maxTopicMessageLen {
+			return res
+		}
+		past = past[:len(past)-1]
+	}
+}
@@ -0,0 +1,53 @@
+package events
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicMessage_render(t *testing.T) {
+	tm := topicMessage{}
+	assert.Equal(t, "", tm.render())
+
+	tm.start(1, "topic 1")
+	assert.Equal(t, "topic 1", tm.render())
+
+	tm.add("topic 2")
+	tm.add("topic 3")
+	assert.Equal(t, "topic 3\n\nПредыдущие темы:\n• topic 2\n• topic 1", tm.render())
+
+	tm.reset()
+	assert.Equal(t, 0, tm.msgID)
+	assert.Equal(t, "", tm.render())
+}
+
+func TestTopicMessage_renderTooLong(t *testing.T) {
+	tm := topicMessage{}
+	tm.start(1, strings.Repeat("a", 2500))
+	tm.add(strings.Repeat("b", 1900))
+	tm.add("current")
+
+	res := tm.render()
+	assert.LessOrEqual(t, len([]rune(res)), maxTopicMessageLen)
+	assert.True(t, strings.HasPrefix(res, "current\n\nПредыдущие темы:\n• bbb"))
+	assert.NotContains(t, res, "aaa")
+}
+
+func TestTopicMessage_editID(t *testing.T) {
+	tm := topicMessage{}
+	tm.start(42, "t1")
+	tm.add("t2")
+	id2 := tm.editID()
+	tm.add("t3")
+	id3 := tm.editID()
+	assert.Less(t, id2, 0)
+	assert.Less(t, id3, 0)
+	assert.NotEqual(t, id2, id3)
+
+	other := topicMessage{}
+	other.start(43, "t1")
+	other.add("t2")
+	assert.NotEqual(t, id2, other.editID())
+}
@@ -3,12 +3,17 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-pkgz/lgr"
@@ -22,6 +27,7 @@ import (
 	"golang.org/x/time/rate"
 
 	"github.com/radio-t/super-bot/app/bot"
+	"github.com/radio-t/super-bot/app/bot/llm"
 	"github.com/radio-t/super-bot/app/bot/openai"
 	"github.com/radio-t/super-bot/app/events"
 	"github.com/radio-t/super-bot/app/reporter"
@@ -30,24 +36,38 @@ import (
 
 var opts struct {
 	Telegram struct {
-		Token   string        `long:"token" env:"TOKEN" description:"telegram bot token" required:"true"`
-		Group   string        `long:"group" env:"GROUP" description:"group name/id"      required:"true"`
+		Token   string        `long:"token" env:"TOKEN" description:"telegram bot token, required to run the bot"`
+		Group   string        `long:"group" env:"GROUP" description:"group name/id, required to run the bot"`
 		Timeout time.Duration `long:"timeout" env:"TIMEOUT" description:"http client timeout for getting files from Telegram" default:"30s"`
 	} `group:"telegram" namespace:"telegram" env-namespace:"TELEGRAM"`
 
 	RtjcPort             int              `short:"p" long:"port" env:"RTJC_PORT" default:"18001" description:"rtjc port room"`
 	LogsPath             string           `short:"l" long:"logs" env:"TELEGRAM_LOGS" default:"logs" description:"path to logs"`
 	MessageLogDelay      time.Duration    `long:"msg-log-delay" env:"MSG_LOG_DELAY" default:"1s" description:"delay for message log"`
+	MessageLogNoProbe    bool             `long:"msg-log-no-probe" env:"MSG_LOG_NO_PROBE" description:"don't check t.me for deleted messages, drop only messages deleted by bot"`
+	MessageLogNoFile     bool             `long:"msg-log-no-file" env:"MSG_LOG_NO_FILE" description:"don't write daily log files, history database only"`
+	HistoryDB            string           `long:"history-db" env:"HISTORY_DB" description:"path to chat history database, disabled if empty"`
+	ChatTimezone         string           `long:"chat-tz" env:"CHAT_TZ" default:"Europe/Moscow" description:"timezone of times in chat commands and rtjc scheduled posts"`
 	SuperUsers           events.SuperUser `long:"super" description:"super-users"`
 	MashapeToken         string           `long:"mashape" env:"MASHAPE_TOKEN" description:"mashape token"`
 	SysData              string           `long:"sys-data" env:"SYS_DATA" default:"data" description:"location of sys data"`
 	NewsArticles         int              `long:"max-articles" env:"MAX_ARTICLES" default:"5" description:"max number of news articles"`
 	IdleDuration         time.Duration    `long:"idle" env:"IDLE" default:"30s" description:"idle duration"`
-	ExportNum            int              `long:"export-num" description:"show number for export"`
 	ExportPath           string           `long:"export-path" default:"logs" description:"path to export directory"`
-	ExportDay            int              `long:"export-day" description:"day in yyyymmdd"`
+	ExportBaseURL        string           `long:"export-base-url" description:"public url of export path for links in the feed, relative links if empty"`
+	ExportPadBefore      time.Duration    `long:"export-pad-before" description:"export messages before the broadcast start"`
+	ExportPadAfter       time.Duration    `long:"export-pad-after" description:"export messages after the broadcast end"`
 	TemplateFile         string           `long:"export-template" default:"logs.html" description:"path to template file"`
+	ExportFormat         string           `long:"export-format" default:"html" choice:"html" choice:"markdown" choice:"json" choice:"text" description:"export format"`
+	ExportThumbSize      int              `long:"export-thumb-size" default:"320" description:"max width and height of image thumbnails in export, 0 to disable"`
+	ExportTimezone       string           `long:"export-tz" default:"Europe/Moscow" description:"timezone of message times in export"`
+	ExportTimeFormat     string           `long:"export-time-format" default:"15:04:05" description:"go layout of message times in export"`
 	ExportBroadcastUsers events.SuperUser `long:"broadcast" description:"broadcast-users"`
+	RedactUsers          []string         `long:"redact-user" description:"anonymize user in export, username or id"`
+	RedactPatterns       []string         `long:"redact-pattern" description:"regexp of personal data to mask in export"`
+	RedactMessages       []int            `long:"redact-msg" description:"message id to exclude from export"`
+	ExportSummary        bool             `long:"export-summary" description:"add chat summary made by OpenAI to export, cached per show"`
+	ScheduleFile         string           `long:"schedule-file" env:"SCHEDULE_FILE" default:"logs/scheduled.json" description:"file to keep scheduled posts"`
 
 	SpamFilter struct {
 		Enabled   bool          `long:"enabled" env:"ENABLED" description:"enable spam filter"`
@@ -60,8 +80,11 @@ var opts struct {
 	} `group:"spam-filter" namespace:"spam-filter" env-namespace:"SPAM_FILTER"`
 
 	OpenAI struct {
-		Model             string `long:"model" env:"MODEL" default:"gpt-4o-mini" description:"OpenAI model"`
-		AuthToken         string `long:"token" env:"AUTH_TOKEN" description:"OpenAI auth token"`
+		Provider          string `long:"provider" env:"PROVIDER" default:"openai" choice:"openai" choice:"anthropic" description:"LLM provider, openai for OpenAI API and OpenAI-compatible servers"`
+		BaseURL           string `long:"base-url" env:"BASE_URL" description:"API of the provider, i.e. http://localhost:11434/v1 for ollama, default API if empty"`
+		Model             string `long:"model" env:"MODEL" description:"model, gpt-4o-mini for openai and claude-3-5-haiku-latest for anthropic if empty"`
+		Tokenizer         string `long:"tokenizer" env:"TOKENIZER" choice:"gpt3" choice:"none" description:"tokenizer of the model, gpt3 for openai and none (limit by symbols) for anthropic if empty"`
+		AuthToken         string `long:"token" env:"AUTH_TOKEN" description:"OpenAI or Anthropic auth token"`
 		MaxTokensResponse int    `long:"max-tokens" env:"MAX_TOKENS" default:"1000" description:"OpenAI max_tokens in response"`
 		MaxTokensRequest  int    `long:"max-tokens-request" env:"MAX_TOKENS_REQUEST" default:"3000" description:"OpenAI max tokens in request"`
 		MaxSymbolsRequest int    `long:"max-symbols-request" env:"MAX_SYMBOLS_REQUEST" default:"12000" description:"OpenAI max symbols in request for fallback logic"`
@@ -79,22 +102,82 @@ var opts struct {
 	UreadabilityToken    string `long:"ur-token" env:"UREADABILITY_TOKEN" default:"undefined" description:"uReadability token"`
 	SummarizerThreadsNum int    `long:"summarizer-threads" env:"SUMMARIZER_THREADS" default:"5" description:"Number of threads in summarizer"`
 
+	HistorySearch struct {
+		Enabled    bool   `long:"enabled" env:"ENABLED" description:"enable history! search in chat logs"`
+		MaxResults int    `long:"max-results" env:"MAX_RESULTS" default:"5" description:"max number of found messages"`
+		MaxDays    int    `long:"max-days" env:"MAX_DAYS" default:"365" description:"search logs of the last days only, 0 for all logs"`
+		ShowAnchor string `long:"show-anchor" env:"SHOW_ANCHOR" description:"known show number and its broadcast start for show:N search, i.e. 900:2024-03-09T20:00:00Z"`
+	} `group:"history-search" namespace:"history-search" env-namespace:"HISTORY_SEARCH"`
+
+	Sinks struct {
+		Buffer         int           `long:"buffer" env:"BUFFER" default:"1000" description:"queue size of each message sink"`
+		Webhook        string        `long:"webhook" env:"WEBHOOK" description:"url to post chat messages to, disabled if empty"`
+		WebhookTimeout time.Duration `long:"webhook-timeout" env:"WEBHOOK_TIMEOUT" default:"5s" description:"webhook request timeout"`
+		Metrics        string        `long:"metrics" env:"METRICS" description:"address to serve message counters on /metrics, i.e. :8080, disabled if empty"`
+	} `group:"sinks" namespace:"sinks" env-namespace:"SINKS"`
+
+	Retention struct {
+		CompressAfter int    `long:"compress-after" env:"COMPRESS_AFTER" default:"7" description:"gzip daily logs older than days, at least 2, 0 to disable"`
+		DeleteAfter   int    `long:"delete-after" env:"DELETE_AFTER" default:"0" description:"delete or archive daily logs older than days, 0 to keep forever"`
+		ArchivePath   string `long:"archive-path" env:"ARCHIVE_PATH" description:"move expired logs here instead of deleting"`
+	} `group:"retention" namespace:"retention" env-namespace:"RETENTION"`
+
+	ExportS3 struct {
+		Endpoint  string `long:"endpoint" env:"ENDPOINT" description:"s3-compatible endpoint for exported images, i.e. https://s3.amazonaws.com, local export-path if empty"`
+		Region    string `long:"region" env:"REGION" default:"us-east-1" description:"s3 region"`
+		Bucket    string `long:"bucket" env:"BUCKET" description:"s3 bucket"`
+		Prefix    string `long:"prefix" env:"PREFIX" description:"key prefix, show number added to it"`
+		AccessKey string `long:"access-key" env:"ACCESS_KEY" description:"s3 access key"`
+		SecretKey string `long:"secret-key" env:"SECRET_KEY" description:"s3 secret key"`
+		PublicURL string `long:"public-url" env:"PUBLIC_URL" description:"base url of public links to images, endpoint/bucket if empty"`
+		ACL       string `long:"acl" env:"ACL" description:"canned acl of uploaded images, i.e. public-read"`
+	} `group:"export-s3" namespace:"export-s3" env-namespace:"EXPORT_S3"`
+
+	AutoExport struct {
+		Enabled bool          `long:"enabled" env:"ENABLED" description:"export show chat when the broadcast finishes"`
+		Delay   time.Duration `long:"delay" env:"DELAY" default:"10m" description:"wait after the broadcast finished before export"`
+	} `group:"auto-export" namespace:"auto-export" env-namespace:"AUTO_EXPORT"`
+
 	RtjcParams struct {
-		SwgSize   int   `long:"swg-size" env:"SWG_SIZE" default:"10" description:"Rtjc sized waiting group size"`
-		RateSec   int64 `long:"rate-sec" env:"RATE_SEC" default:"8" description:"Rtjc submit rate limit seconds between submits"`
-		RateBurst int   `long:"rate-burst" env:"RATE_BURST" default:"5" description:"Rtjc submit rate limit burst"`
+		SwgSize    int   `long:"swg-size" env:"SWG_SIZE" default:"10" description:"Rtjc sized waiting group size"`
+		RateSec    int64 `long:"rate-sec" env:"RATE_SEC" default:"8" description:"Rtjc submit rate limit seconds between submits"`
+		RateBurst  int   `long:"rate-burst" env:"RATE_BURST" default:"5" description:"Rtjc submit rate limit burst"`
+		EditTopics bool  `long:"edit-topics" env:"EDIT_TOPICS" description:"edit current topic message on topic change instead of posting a new one"`
+		Collapse   bool  `long:"collapse-summaries" env:"COLLAPSE_SUMMARIES" description:"post summaries as one expandable quote"`
+
+		DedupWindow time.Duration `long:"dedup-window" env:"DEDUP_WINDOW" default:"30m" description:"window to skip duplicated rtjc submissions, 0 to disable"`
+		DedupFile   string        `long:"dedup-file" env:"DEDUP_FILE" default:"logs/rtjc-dedup.json" description:"file to keep rtjc dedup state between restarts"`
 	} `group:"rtjc" namespace:"rtjc" env-namespace:"RTJC"`
 
 	Dbg bool `long:"dbg" env:"DEBUG" description:"debug mode"`
+
+	Run     struct{}      `command:"run" description:"run the bot, default command"`
+	Export  exportCommand `command:"export" description:"export chat of the show or range of shows"`
+	Reindex struct{}      `command:"reindex" description:"regenerate index page and feed of exported shows"`
+}
+
+// exportCommand options, days of the range shows are found by the show anchor
+type exportCommand struct {
+	Shows       string `long:"shows" required:"true" description:"show number or range of shows, i.e. 900 or 900-905"`
+	Day         int    `long:"day" description:"day in yyyymmdd of the single show, current day if empty"`
+	From        string `long:"from" description:"start of the single show range in RFC3339, broadcast markers ignored"`
+	To          string `long:"to" description:"end of the single show range in RFC3339"`
+	ShowAnchor  string `long:"show-anchor" description:"known show number and its broadcast start for range of shows, history-search show anchor if empty"`
+	BotUsername string `long:"bot-username" description:"bot username, telegram is not used with offline or dry-run if set"`
+	DryRun      bool   `long:"dry-run" description:"print chat statistics of the shows without export"`
+	Offline     bool   `long:"offline" description:"don't download images from telegram, link images stored by previous exports"`
 }
 
 var revision = "local"
 
 func main() {
-	ctx := context.TODO()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
 	fmt.Printf("radio-t bot, %s\n", revision)
-	if _, err := flags.Parse(&opts); err != nil {
+	p := flags.NewParser(&opts, flags.Default)
+	p.SubcommandsOptional = true // run the bot without command
+	if _, err := p.Parse(); err != nil {
 		log.Printf("[ERROR] failed to parse flags: %v", err)
 		os.Exit(1)
 	}
@@ -102,10 +185,21 @@ func main() {
 	setupLog(opts.Dbg)
 	log.Printf("[INFO] super users: %v", opts.SuperUsers)
 	log.Printf("[DEBUG] opts: %+v", opts)
-	if opts.ExportNum > 0 {
-		export()
+	if p.Active != nil && p.Active.Name == "export" {
+		if err := export(opts.Export); err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
 		return
 	}
+	if p.Active != nil && p.Active.Name == "reindex" {
+		if err := reindex(); err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		return
+	}
+	if opts.Telegram.Token == "" || opts.Telegram.Group == "" {
+		log.Fatalf("[ERROR] telegram token and group are required to run the bot")
+	}
 
 	tbAPI, err := tbapi.NewBotAPI(opts.Telegram.Token)
 	if err != nil {
@@ -114,28 +208,51 @@ func main() {
 	tbAPI.Debug = opts.Dbg
 
 	httpClient := &http.Client{Timeout: 5 * time.Second}
-	// 5 seconds is not enough for OpenAI requests
-	httpClientOpenAI := makeOpenAIHttpClient()
-	openAIBot := openai.NewOpenAI(openai.Params{
-		Model:                   opts.OpenAI.Model,
-		AuthToken:               opts.OpenAI.AuthToken,
-		MaxTokensResponse:       opts.OpenAI.MaxTokensResponse,
-		MaxTokensRequest:        opts.OpenAI.MaxTokensRequest,
-		MaxSymbolsRequest:       opts.OpenAI.MaxSymbolsRequest,
-		Prompt:                  opts.OpenAI.Prompt,
-		HistorySize:             opts.OpenAI.HistorySize,
-		HistoryReplyProbability: opts.OpenAI.HistoryReplyProbability,
-		EnableAutoResponse:      opts.OpenAI.EnableAutoResponse,
-	}, httpClientOpenAI, opts.SuperUsers)
+	openAIBot := makeOpenAI()
+
+	scheduler, err := events.NewScheduler(opts.ScheduleFile, 10*time.Second)
+	if err != nil {
+		log.Fatalf("[ERROR] can't make scheduler, %v", err)
+	}
+	chatLocation, err := time.LoadLocation(opts.ChatTimezone)
+	if err != nil {
+		log.Printf("[WARN] can't load chat timezone %q for scheduled posts and history search, using UTC: %v", opts.ChatTimezone, err)
+		chatLocation = time.UTC
+	}
+
+	broadcastParams := bot.BroadcastParams{
+		URL:          "https://stream.radio-t.com",
+		PingInterval: 10 * time.Second,
+		DelayToOff:   time.Minute,
+		Client:       http.Client{Timeout: 5 * time.Second},
+	}
+	var history *reporter.History
+	if opts.HistoryDB != "" {
+		if history, err = reporter.NewHistory(opts.HistoryDB); err != nil {
+			log.Fatalf("[ERROR] can't open history database, %v", err)
+		}
+	}
+	exportParams, err := exporterParams(tbAPI.Self.UserName, openAIBot)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	exporter := &showExporter{botAPI: tbAPI, params: exportParams, history: history}
+	var chatExport *bot.ChatExport
+	if opts.AutoExport.Enabled {
+		chatExport = bot.NewChatExport(bot.ChatExportParams{
+			Exporter: exporter,
+			Sender:   tbAPI,
+			Client:   httpClient,
+			SiteAPI:  "https://radio-t.com/site-api",
+			Delay:    opts.AutoExport.Delay,
+			Location: chatLocation,
+		}, opts.SuperUsers)
+		broadcastParams.OnFinished = chatExport.BroadcastFinished
+	}
 
+	broadcastStatus := bot.NewBroadcastStatus(ctx, broadcastParams)
 	multiBot := bot.MultiBot{
-		bot.NewBroadcastStatus(
-			ctx,
-			bot.BroadcastParams{
-				URL:          "https://stream.radio-t.com",
-				PingInterval: 10 * time.Second,
-				DelayToOff:   time.Minute,
-				Client:       http.Client{Timeout: 5 * time.Second}}),
+		broadcastStatus,
 		bot.NewNews(httpClient, "https://news.radio-t.com/api", opts.NewsArticles),
 		bot.NewAnecdote(httpClient),
 		bot.NewStackOverflow(),
@@ -146,9 +263,17 @@ func main() {
 		bot.NewBanhammer(tbAPI, opts.SuperUsers, 5000),
 		bot.NewWhen(),
 		bot.NewDefaultSayNoMore(opts.SuperUsers),
+		bot.NewSchedule(scheduler, opts.SuperUsers, chatLocation),
 		openAIBot,
 	}
 
+	if chatExport != nil {
+		multiBot = append(multiBot, chatExport)
+	}
+	if !opts.MessageLogNoFile { // links are collected from the daily logs
+		multiBot = append(multiBot, bot.NewChatLinks(reporter.NewExporter(nil, nil, exportParams), broadcastStatus, chatLocation))
+	}
+
 	if opts.SpamFilter.Enabled {
 		log.Printf("[INFO] spam filter enabled, dry=%v", opts.SpamFilter.Dry)
 		httpCasClient := &http.Client{Timeout: opts.SpamFilter.TimeOut}
@@ -187,6 +312,22 @@ func main() {
 		log.Printf("[ERROR] failed to load whats the time bot, %v", err)
 	}
 
+	if opts.HistorySearch.Enabled {
+		anchor, err := parseShowAnchor(opts.HistorySearch.ShowAnchor)
+		if err != nil {
+			log.Fatalf("[ERROR] can't parse show anchor, %v", err)
+		}
+		logIndex := reporter.NewLogIndex(opts.LogsPath, anchor, opts.HistorySearch.MaxDays)
+		go func() {
+			if err := logIndex.Build(); err != nil {
+				log.Printf("[WARN] can't build log index, %v", err)
+			}
+		}()
+		multiBot = append(multiBot, bot.NewChatHistory(logIndex, opts.Telegram.Group, chatLocation, opts.HistorySearch.MaxResults))
+	}
+	// set before the listener started, export is triggered by the chat or the broadcast status only
+	exporter.params.BotCommands = multiBot.ReactOn()
+
 	allActivityTerm := events.Terminator{
 		BanDuration:   time.Minute * 5,
 		BanPenalty:    10,
@@ -208,12 +349,46 @@ func main() {
 		Exclude:       opts.SuperUsers,
 	}
 
+	probeChat := opts.Telegram.Group // public chat name enables t.me check of deleted messages
+	if opts.MessageLogNoProbe {
+		probeChat = ""
+	}
+	var loggerOpts []reporter.Option
+	if history != nil {
+		loggerOpts = append(loggerOpts, reporter.WithHistory(history))
+	}
+	if opts.MessageLogNoFile {
+		if history == nil {
+			log.Fatalf("[ERROR] msg-log-no-file requires history-db, nothing to write the log to")
+		}
+		loggerOpts = append(loggerOpts, reporter.WithoutFileLog())
+	}
+	msgLogger := reporter.NewLogger(opts.LogsPath, opts.MessageLogDelay, probeChat, loggerOpts...)
+	if !opts.MessageLogNoFile {
+		go reporter.NewRetention(reporter.RetentionParams{
+			LogsPath:      opts.LogsPath,
+			CompressAfter: opts.Retention.CompressAfter,
+			DeleteAfter:   opts.Retention.DeleteAfter,
+			ArchivePath:   opts.Retention.ArchivePath,
+		}).Run(ctx, time.Hour)
+	}
+
+	sinks := reporter.NewSinks(opts.Sinks.Buffer)
+	sinks.AddDirect("log", msgLogger) // reporter never blocks and shouldn't drop messages
+	if opts.Sinks.Webhook != "" {
+		sinks.Add("webhook", reporter.NewWebhook(opts.Sinks.Webhook, &http.Client{Timeout: opts.Sinks.WebhookTimeout}))
+	}
+	if opts.Sinks.Metrics != "" {
+		metrics := reporter.NewMetrics()
+		sinks.Add("metrics", metrics)
+		go serveMetrics(ctx, opts.Sinks.Metrics, metrics)
+	}
 	tgListener := events.TelegramListener{
 		TbAPI:                  tbAPI,
 		AllActivityTerm:        allActivityTerm,
 		BotsActivityTerm:       botsActivityTerm,
 		OverallBotActivityTerm: botsAllUsersActivityTerm,
-		MsgLogger:              reporter.NewLogger(opts.LogsPath, opts.MessageLogDelay, opts.Telegram.Group),
+		MsgLogger:              sinks,
 		Bots:                   multiBot,
 		Group:                  opts.Telegram.Group,
 		Debug:                  opts.Dbg,
@@ -240,60 +415,370 @@ func main() {
 		opts.Dbg,
 	)
 
+	scheduler.Submitter = &tgListener
+	go scheduler.Run(ctx)
+
+	var rtjcDedup *events.Dedup
+	if opts.RtjcParams.DedupWindow > 0 {
+		if rtjcDedup, err = events.NewDedup(opts.RtjcParams.DedupFile, opts.RtjcParams.DedupWindow); err != nil {
+			log.Fatalf("[ERROR] can't make rtjc dedup, %v", err)
+		}
+	}
+
 	rtjc := events.Rtjc{
 		Port:            opts.RtjcPort,
 		Submitter:       &tgListener,
 		Summarizer:      summarizer,
+		EditTopics:      opts.RtjcParams.EditTopics,
+		Dedup:           rtjcDedup,
+		Scheduler:       scheduler,
+		Location:        chatLocation,
 		Swg:             syncs.NewSizedGroup(opts.RtjcParams.SwgSize),
 		SubmitRateBurst: opts.RtjcParams.RateBurst,
 		SubmitRateLimit: rate.Limit(1 / float64(opts.RtjcParams.RateSec)),
+
+		CollapseSummaries: opts.RtjcParams.Collapse,
 	}
 	go rtjc.Listen(ctx)
 
-	if err := tgListener.Do(ctx); err != nil {
+	err = tgListener.Do(ctx)
+	// flush all accepted messages to the log and other sinks before exit
+	if closeErr := sinks.Close(); closeErr != nil {
+		log.Printf("[WARN] failed to close message sinks, %v", closeErr)
+	}
+	if history != nil {
+		if closeErr := history.Close(); closeErr != nil {
+			log.Printf("[WARN] %v", closeErr)
+		}
+	}
+	if err != nil && ctx.Err() == nil {
 		log.Fatalf("[ERROR] telegram listener failed, %v", err)
 	}
+	log.Printf("[INFO] terminated")
+}
+
+// export exports shows one by one, failed shows don't stop the export of others
+func export(cmd exportCommand) error {
+	shows, err := parseShows(cmd.Shows)
+	if err != nil {
+		return err
+	}
+	window := reporter.ExportWindow{Day: cmd.Day, PadBefore: opts.ExportPadBefore, PadAfter: opts.ExportPadAfter}
+	if len(shows) > 1 && (cmd.Day != 0 || cmd.From != "" || cmd.To != "") {
+		return errors.New("day and time range can be set for a single show only")
+	}
+	if cmd.From != "" || cmd.To != "" {
+		if window.From, err = time.Parse(time.RFC3339, cmd.From); err != nil {
+			return fmt.Errorf("invalid from: %w", err)
+		}
+		if window.To, err = time.Parse(time.RFC3339, cmd.To); err != nil {
+			return fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	var anchor reporter.ShowAnchor
+	if len(shows) > 1 {
+		anchorStr := cmd.ShowAnchor
+		if anchorStr == "" {
+			anchorStr = opts.HistorySearch.ShowAnchor
+		}
+		if anchor, err = parseShowAnchor(anchorStr); err != nil {
+			return fmt.Errorf("can't parse show anchor: %w", err)
+		}
+		if anchor.Num == 0 {
+			return errors.New("show anchor is required to export range of shows")
+		}
+	}
+
+	botUsername := cmd.BotUsername
+	var botAPI *tbapi.BotAPI
+	if !cmd.Offline && !cmd.DryRun {
+		if botAPI, err = tbapi.NewBotAPI(opts.Telegram.Token); err != nil {
+			return fmt.Errorf("telegram bot creation failed: %w", err)
+		}
+		if botUsername == "" {
+			botUsername = botAPI.Self.UserName
+		}
+	}
+	if botUsername == "" {
+		log.Printf("[WARN] bot-username not set, bot messages are exported as regular ones")
+	}
+	var llmClient *openai.OpenAI
+	if opts.ExportSummary {
+		llmClient = makeOpenAI()
+	}
+	params, err := exporterParams(botUsername, llmClient)
+	if err != nil {
+		return err
+	}
+	params.Offline = cmd.Offline // BotCommands not set without the bots, only bot_command entities counted in stats
+	if cmd.Offline {
+		params.Titles = nil
+	}
+	log.Printf("[INFO] export of %v, destination=%s, format=%s, template=%s, offline=%v, dry-run=%v",
+		shows, opts.ExportPath, opts.ExportFormat, opts.TemplateFile, cmd.Offline, cmd.DryRun)
+
+	exporter := &showExporter{botAPI: botAPI, params: params}
+	var failed []int
+	for _, num := range shows {
+		w := window
+		if len(shows) > 1 {
+			if w.Day, err = strconv.Atoi(anchor.ShowStart(num).In(time.Local).Format("20060102")); err != nil {
+				return fmt.Errorf("invalid day of #%d: %w", num, err)
+			}
+		}
+		if cmd.DryRun {
+			err = printStats(num, reporter.NewExporter(nil, nil, params), w)
+		} else {
+			_, err = exporter.export(num, w)
+		}
+		if err != nil {
+			log.Printf("[WARN] #%d: %v", num, err)
+			failed = append(failed, num)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to export shows %v", failed)
+	}
+	return nil
+}
+
+// printStats prints chat statistics of the show to stdout
+func printStats(num int, exporter *reporter.Exporter, window reporter.ExportWindow) error {
+	stats, err := exporter.Stats(window)
+	if err != nil {
+		return fmt.Errorf("stats failed: %w", err)
+	}
+	fmt.Printf("#%d\n", num)
+	if err = stats.WriteText(os.Stdout); err != nil {
+		return fmt.Errorf("can't write stats: %w", err)
+	}
+	return nil
+}
+
+// reindex regenerates index page and feed from meta files of exported shows
+func reindex() error {
+	params, err := exporterParams("", nil)
+	if err != nil {
+		return err
+	}
+	if err = reporter.NewExporter(nil, nil, params).UpdateIndex(); err != nil {
+		return fmt.Errorf("reindex failed: %w", err)
+	}
+	log.Printf("[INFO] index of %s updated", opts.ExportPath)
+	return nil
 }
 
-func export() {
-	log.Printf("[INFO] export mode, destination=%s, template=%s", opts.ExportPath, opts.TemplateFile)
-	botAPI, err := tbapi.NewBotAPI(opts.Telegram.Token)
+// parseShows parses show number or range of shows "from-to"
+func parseShows(s string) ([]int, error) {
+	from, to, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	first, err := strconv.Atoi(from)
 	if err != nil {
-		log.Fatalf("[ERROR] telegram bot creation failed: %v", err)
+		return nil, fmt.Errorf("invalid show number in %q: %w", s, err)
 	}
-	botUser, err := botAPI.GetMe()
+	last := first
+	if isRange {
+		if last, err = strconv.Atoi(to); err != nil {
+			return nil, fmt.Errorf("invalid show number in %q: %w", s, err)
+		}
+	}
+	if first <= 0 || last < first {
+		return nil, fmt.Errorf("invalid range of shows %q", s)
+	}
+	res := make([]int, 0, last-first+1)
+	for num := first; num <= last; num++ {
+		res = append(res, num)
+	}
+	return res, nil
+}
+
+// exporterParams makes export params from options, llmClient used for chat summary if enabled, can be nil
+func exporterParams(botUsername string, llmClient *openai.OpenAI) (reporter.ExporterParams, error) {
+	location, err := time.LoadLocation(opts.ExportTimezone)
 	if err != nil {
-		log.Fatalf("[ERROR] failed to get bot username: %v", err)
+		return reporter.ExporterParams{}, fmt.Errorf("can't load export timezone %q: %w", opts.ExportTimezone, err)
+	}
+	var redaction *reporter.Redaction
+	if len(opts.RedactUsers) > 0 || len(opts.RedactPatterns) > 0 || len(opts.RedactMessages) > 0 {
+		if redaction, err = reporter.NewRedaction(opts.RedactUsers, opts.RedactPatterns, opts.RedactMessages); err != nil {
+			return reporter.ExporterParams{}, fmt.Errorf("can't make export redaction: %w", err)
+		}
+	}
+	// pointer keeps the token out of the logged params
+	titles := &openai.UKeeperClient{Client: &http.Client{Timeout: 5 * time.Second}, API: opts.UreadabilityAPI,
+		Token: opts.UreadabilityToken}
+	broadcastUsers := opts.ExportBroadcastUsers
+	if botUsername != "" { // unknown in offline export without bot-username
+		broadcastUsers = append([]string{botUsername}, broadcastUsers...)
 	}
+	var summarizer reporter.ChatSummarizer // not set from nil client, interface with nil pointer is not nil
+	if opts.ExportSummary && llmClient != nil {
+		summarizer = llmClient
+	}
+	return reporter.ExporterParams{
+		InputRoot:      opts.LogsPath,
+		OutputRoot:     opts.ExportPath,
+		BaseURL:        opts.ExportBaseURL,
+		TemplateFile:   opts.TemplateFile,
+		Location:       location,
+		TimeFormat:     opts.ExportTimeFormat,
+		Format:         opts.ExportFormat,
+		ThumbSize:      opts.ExportThumbSize,
+		Redaction:      redaction,
+		Titles:         titles,
+		Summarizer:     summarizer,
+		BotUsername:    botUsername,
+		SuperUsers:     opts.SuperUsers,
+		BroadcastUsers: broadcastUsers,
+	}, nil
+}
 
-	fileRecipient := reporter.NewTelegramFileRecipient(botAPI, opts.Telegram.Timeout)
+// showExporter exports show chat with images from telegram to the local export path
+type showExporter struct {
+	botAPI  *tbapi.BotAPI
+	params  reporter.ExporterParams
+	history *reporter.History // optional, show number assigned to messages of the exported window
+}
 
-	exportNum := strconv.Itoa(opts.ExportNum)
-	s, err := storage.NewLocal(
-		opts.ExportPath+"/"+exportNum,
-		exportNum,
-	)
+// ExportShow exports the show broadcast between started and finished with the padding, used by the bot
+// when the broadcast finishes. Without finished the broadcast is found by markers in the log of the started day.
+func (s *showExporter) ExportShow(showNum int, started, finished time.Time) (string, error) {
+	if !finished.IsZero() {
+		window := reporter.ExportWindow{From: started, To: finished, PadBefore: opts.ExportPadBefore, PadAfter: opts.ExportPadAfter}
+		if s.history != nil {
+			count, err := s.history.AssignShow(showNum, window.From.Add(-window.PadBefore), window.To.Add(window.PadAfter))
+			if err != nil {
+				log.Printf("[WARN] can't assign show #%d in history, %v", showNum, err)
+			} else {
+				log.Printf("[INFO] show #%d assigned to %d messages in history", showNum, count)
+			}
+		}
+		return s.export(showNum, window)
+	}
+	dayNum, err := strconv.Atoi(started.Format("20060102"))
 	if err != nil {
-		log.Fatalf("[ERROR] storage creation failed: %v", err)
-	}
-
-	params := reporter.ExporterParams{
-		InputRoot:    opts.LogsPath,
-		OutputRoot:   opts.ExportPath,
-		TemplateFile: opts.TemplateFile,
-		BotUsername:  botUser.UserName,
-		SuperUsers:   opts.SuperUsers,
-		BroadcastUsers: events.SuperUser(
-			append(
-				[]string{botUser.UserName},
-				opts.ExportBroadcastUsers...,
-			),
-		),
-	}
-	err = reporter.NewExporter(fileRecipient, s, params).Export(opts.ExportNum, opts.ExportDay)
+		return "", fmt.Errorf("invalid day %v: %w", started, err)
+	}
+	return s.export(showNum, reporter.ExportWindow{Day: dayNum, PadBefore: opts.ExportPadBefore, PadAfter: opts.ExportPadAfter})
+}
+
+func (s *showExporter) export(showNum int, window reporter.ExportWindow) (string, error) {
+	store, err := makeExportStorage(showNum)
+	if err != nil {
+		return "", fmt.Errorf("storage creation failed: %w", err)
+	}
+	var fileRecipient reporter.FileRecipient // images linked from media cache offline
+	if !s.params.Offline {
+		fileRecipient = reporter.NewTelegramFileRecipient(s.botAPI, opts.Telegram.Timeout)
+	}
+	res, err := reporter.NewExporter(fileRecipient, store, s.params).Export(showNum, window)
+	if err != nil {
+		return "", fmt.Errorf("export failed: %w", err)
+	}
+	return res, nil
+}
+
+// makeExportStorage makes storage of the show images, s3 if endpoint set or local directory in export path
+func makeExportStorage(showNum int) (reporter.Storage, error) {
+	exportNum := strconv.Itoa(showNum)
+	if opts.ExportS3.Endpoint == "" {
+		return storage.NewLocal(opts.ExportPath+"/"+exportNum, exportNum)
+	}
+	return storage.NewS3(storage.S3Params{
+		Endpoint:  opts.ExportS3.Endpoint,
+		Region:    opts.ExportS3.Region,
+		Bucket:    opts.ExportS3.Bucket,
+		Prefix:    path.Join(opts.ExportS3.Prefix, exportNum),
+		AccessKey: opts.ExportS3.AccessKey,
+		SecretKey: opts.ExportS3.SecretKey,
+		PublicURL: opts.ExportS3.PublicURL,
+		ACL:       opts.ExportS3.ACL,
+		Client:    &http.Client{Timeout: opts.Telegram.Timeout},
+	})
+}
+
+// serveMetrics serves message counters on /metrics until context canceled
+func serveMetrics(ctx context.Context, addr string, metrics http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		if err := srv.Close(); err != nil {
+			log.Printf("[WARN] failed to close metrics server, %v", err)
+		}
+	}()
+	log.Printf("[INFO] metrics server on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("[WARN] metrics server failed, %v", err)
+	}
+}
+
+// parseShowAnchor parses "num:RFC3339 time" of a known show broadcast, empty string disables search by show
+func parseShowAnchor(s string) (reporter.ShowAnchor, error) {
+	if s == "" {
+		return reporter.ShowAnchor{}, nil
+	}
+	num, start, found := strings.Cut(s, ":")
+	if !found {
+		return reporter.ShowAnchor{}, fmt.Errorf("invalid show anchor %q, expected num:time", s)
+	}
+	res := reporter.ShowAnchor{}
+	var err error
+	if res.Num, err = strconv.Atoi(num); err != nil {
+		return reporter.ShowAnchor{}, fmt.Errorf("invalid show number in %q: %w", s, err)
+	}
+	if res.Start, err = time.Parse(time.RFC3339, start); err != nil {
+		return reporter.ShowAnchor{}, fmt.Errorf("invalid show time in %q: %w", s, err)
+	}
+	return res, nil
+}
+
+// makeOpenAI makes OpenAI client for the bot and export summary
+func makeOpenAI() *openai.OpenAI {
+	provider, tokenizer := makeLLM()
+	return openai.NewOpenAI(openai.Params{
+		Tokenizer:               tokenizer,
+		MaxTokensResponse:       opts.OpenAI.MaxTokensResponse,
+		MaxTokensRequest:        opts.OpenAI.MaxTokensRequest,
+		MaxSymbolsRequest:       opts.OpenAI.MaxSymbolsRequest,
+		Prompt:                  opts.OpenAI.Prompt,
+		HistorySize:             opts.OpenAI.HistorySize,
+		HistoryReplyProbability: opts.OpenAI.HistoryReplyProbability,
+		EnableAutoResponse:      opts.OpenAI.EnableAutoResponse,
+	}, provider, opts.SuperUsers)
+}
+
+// makeLLM makes LLM provider with the model and tokenizer, defaults are set per provider
+func makeLLM() (llm.Provider, llm.Tokenizer) {
+	// 5 seconds is not enough for OpenAI requests
+	httpClient := makeOpenAIHttpClient()
+	model, tokenizerName := opts.OpenAI.Model, opts.OpenAI.Tokenizer
+	var provider llm.Provider
+	switch opts.OpenAI.Provider {
+	case "anthropic":
+		if model == "" {
+			model = "claude-3-5-haiku-latest"
+		}
+		if tokenizerName == "" {
+			tokenizerName = "none"
+		}
+		provider = llm.NewAnthropic(llm.AnthropicParams{AuthToken: opts.OpenAI.AuthToken, BaseURL: opts.OpenAI.BaseURL,
+			Model: model, HTTPClient: httpClient})
+	default:
+		if model == "" {
+			model = "gpt-4o-mini"
+		}
+		if tokenizerName == "" {
+			tokenizerName = "gpt3"
+		}
+		provider = llm.NewOpenAI(llm.OpenAIParams{AuthToken: opts.OpenAI.AuthToken, BaseURL: opts.OpenAI.BaseURL,
+			Model: model, HTTPClient: httpClient})
+	}
+	tokenizer, err := llm.NewTokenizer(tokenizerName)
 	if err != nil {
-		log.Fatalf("[ERROR] export failed: %v", err)
+		log.Printf("[WARN] %v, requests limited by symbols", err)
 	}
+	return provider, tokenizer
 }
 
 // makeOpenAIHttpClient creates http client with retry middleware
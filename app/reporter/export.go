@@ -4,36 +4,52 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"html/template"
-	"io"
 	"log"
 	"net/url"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf16"
 
 	"github.com/radio-t/super-bot/app/bot"
 )
 
-// Exporter performs conversion from log file to html
+// Exporter performs conversion from log file to html or other export format
 type Exporter struct {
 	ExporterParams
-	location      *time.Location
 	fileRecipient FileRecipient
 	storage       Storage
 
-	fileIDToURL map[string]string
+	media map[string]*Media // file id -> stored file
+
+	titlesMu sync.Mutex
+	titles   map[string]string // link -> title, empty if not resolved
 }
 
 // ExporterParams for locations
 type ExporterParams struct {
 	OutputRoot     string
+	BaseURL        string // public url of the output root for feed links, relative links if empty
 	InputRoot      string
-	TemplateFile   string
+	TemplateFile   string         // html template, used by html format only
+	Location       *time.Location // timezone of message times, UTC if nil
+	TimeFormat     string         // layout of message times, 15:04:05 if empty
+	ThumbSize      int            // max width and height of image thumbnails, no thumbnails if zero
+	Format         string         // html, markdown, json or text, html if empty
+	Redaction      *Redaction     // personal data hidden in export, nothing hidden if nil
+	Offline        bool           // link images stored by previous exports instead of downloading them
+	Titles         TitleGetter    // titles of shared links, links without titles if nil
+	Summarizer     ChatSummarizer // summary of the chat, only cached summaries exported if nil
 	BotUsername    string
+	BotCommands    []string // keys the bots react on, counted in stats if answered by the bot
 	SuperUsers     SuperUser
 	BroadcastUsers SuperUser // users who can send "bot.MsgBroadcastStarted" and "bot.MsgBroadcastStarted" messages.
 	// it may be just bot, or bot + some or all SuperUsers.
@@ -51,43 +67,61 @@ type Storage interface {
 	FileExists(fileName string) (bool, error)
 	CreateFile(fileName string, body []byte) (string, error)
 	BuildLink(fileName string) string
-	BuildPath(fileName string) string
 }
 
-// NewExporter from params, initializes time.Location
+// NewExporter from params, sets default location and time format
 func NewExporter(fileRecipient FileRecipient, storage Storage, params ExporterParams) *Exporter {
 	log.Printf("[INFO] exporter with %v", params)
-	result := Exporter{
+	if params.Location == nil {
+		params.Location = time.UTC
+	}
+	if params.TimeFormat == "" {
+		params.TimeFormat = "15:04:05"
+	}
+	return &Exporter{
 		ExporterParams: params,
 		fileRecipient:  fileRecipient,
 		storage:        storage,
-		fileIDToURL:    map[string]string{},
+		media:          map[string]*Media{},
+		titles:         map[string]string{},
 	}
+}
 
-	location, err := time.LoadLocation("Europe/Moscow")
-	if err != nil {
-		log.Fatalf("[ERROR] can't load location, error %v", err)
-	}
-	result.location = location
-	return &result
+// ExportWindow selects messages to export. With zero From and To the broadcast is found by "started" and "finished"
+// markers in the Day log and the next day log, so a show crossing midnight is exported completely.
+// Padding extends the window before the start and after the end, i.e. to keep the aftershow chat.
+type ExportWindow struct {
+	Day       int       // yyyymmdd, current day if zero
+	From      time.Time // explicit range, markers ignored
+	To        time.Time
+	PadBefore time.Duration
+	PadAfter  time.Duration
 }
 
-// Export to html with showNum
-func (e *Exporter) Export(showNum, yyyymmdd int) error {
-	from := fmt.Sprintf("%s/%s.log", e.InputRoot, time.Now().Format("20060102")) // current day by default
-	if yyyymmdd != 0 {
-		from = fmt.Sprintf("%s/%d.log", e.InputRoot, yyyymmdd)
+// Export show chat with showNum, returns the exported file
+func (e *Exporter) Export(showNum int, window ExportWindow) (string, error) {
+	renderer, err := NewRenderer(e.Format, e.TemplateFile, e.Location, e.TimeFormat)
+	if err != nil {
+		return "", err
 	}
-	to := fmt.Sprintf("%s/radio-t-%d.html", e.OutputRoot, showNum)
+	to := fmt.Sprintf("%s/radio-t-%d.%s", e.OutputRoot, showNum, renderer.Ext())
 
-	messages, err := readMessages(from, e.BroadcastUsers)
+	messages, start, err := e.readWindow(window)
 	if err != nil {
-		return fmt.Errorf("failed to read messages from %s: %w", from, err)
+		return "", err
+	}
+	if e.Offline {
+		if e.media, err = e.readMediaCache(); err != nil {
+			return "", err
+		}
 	}
+	messages = e.Redaction.Apply(messages)
+	stats := calcStats(messages, e.BotUsername, e.BotCommands, e.Location)
+	messages = withoutReactions(messages)
 
 	fh, err := os.OpenFile(to, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666) // nolint
 	if err != nil {
-		return fmt.Errorf("failed to open destination file %s: %w", to, err)
+		return "", fmt.Errorf("failed to open destination file %s: %w", to, err)
 	}
 
 	defer func() {
@@ -96,143 +130,278 @@ func (e *Exporter) Export(showNum, yyyymmdd int) error {
 		}
 	}()
 
-	h, err := e.toHTML(messages, showNum)
-	if err != nil {
-		return fmt.Errorf("can't export #%d: %w", showNum, err)
+	show := e.prepare(messages, showNum, start)
+	show.Stats = stats
+	show.Links = e.links(messages)
+	show.Summary = e.chatSummary(showNum, messages)
+	if !e.Offline {
+		if err = e.updateMediaCache(); err != nil {
+			log.Printf("[WARN] media cache not updated, %v", err)
+		}
+	}
+	var buf bytes.Buffer
+	if err = renderer.Render(&buf, show); err != nil {
+		return "", fmt.Errorf("can't export #%d: %w", showNum, err)
 	}
 
-	if _, err = fh.WriteString(h); err != nil {
-		return fmt.Errorf("failed to write HTML to file %s: %w", to, err)
+	if _, err = fh.Write(buf.Bytes()); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", to, err)
 	}
 
 	log.Printf("[INFO] exported %d lines to %s", len(messages), to)
-	return nil
-}
-
-func (e *Exporter) toHTML(messages []bot.Message, num int) (string, error) {
-
-	type Record struct {
-		Time   string
-		Msg    bot.Message
-		IsHost bool
-		IsBot  bool
+	if err = e.writeMeta(show, to); err != nil {
+		log.Printf("[WARN] index not updated, %v", err)
+		return to, nil
 	}
-
-	type Data struct {
-		Num     int
-		Records []Record
+	if err = e.UpdateIndex(); err != nil {
+		log.Printf("[WARN] failed to update index, %v", err)
 	}
+	return to, nil
+}
 
-	data := Data{Num: num}
-	for _, msg := range messages {
-
-		if msg.Image != nil {
-			if err := e.maybeDownloadFile(msg.Image.FileID); err != nil {
-				log.Printf("[WARN] failed to download, %v", err)
+// readWindow reads messages of the export window from the daily logs, returns them with the broadcast start,
+// which is the start of explicit range or "started" marker time, zero if the marker not found
+func (e *Exporter) readWindow(w ExportWindow) ([]bot.Message, time.Time, error) {
+	if !w.From.IsZero() || !w.To.IsZero() {
+		if w.From.IsZero() || !w.To.After(w.From) {
+			return nil, time.Time{}, fmt.Errorf("invalid export range %s - %s", w.From.Format(time.RFC3339), w.To.Format(time.RFC3339))
+		}
+		from, to := w.From.Add(-w.PadBefore), w.To.Add(w.PadAfter)
+		var messages []bot.Message
+		found := false
+		// daily logs named by the local time of the bot
+		for day := dayStart(from.In(time.Local)); day.Before(to); day = day.AddDate(0, 0, 1) {
+			path := e.dayLog(day)
+			msgs, err := readMessages(path)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, time.Time{}, fmt.Errorf("failed to read messages from %s: %w", path, err)
+			}
+			found = true
+			for _, msg := range msgs {
+				if !msg.Sent.Before(from) && msg.Sent.Before(to) && !e.isMarker(msg) {
+					messages = append(messages, msg)
+				}
 			}
 		}
-
-		data.Records = append(
-			data.Records,
-			Record{
-				Time:   msg.Sent.In(e.location).Format("15:04:05"),
-				Msg:    msg,
-				IsHost: e.SuperUsers.IsSuper(msg.From.Username),
-				IsBot:  msg.From.Username == e.BotUsername,
-			},
-		)
+		if !found {
+			return nil, time.Time{}, fmt.Errorf("no logs for %s - %s in %s", from.Format(time.RFC3339), to.Format(time.RFC3339), e.InputRoot)
+		}
+		return messages, w.From, nil
 	}
 
-	funcMap := template.FuncMap{
-		"fileURL": func(fileID string) string {
-			if url, found := e.fileIDToURL[fileID]; found {
-				return url
-			}
-			return ""
-		},
-		"timestampHuman": e.timestampHuman,
-		"format":         format,
+	day := dayStart(time.Now()) // current day by default
+	if w.Day != 0 {
+		var err error
+		if day, err = time.ParseInLocation("20060102", strconv.Itoa(w.Day), time.Local); err != nil {
+			return nil, time.Time{}, fmt.Errorf("invalid export day %d: %w", w.Day, err)
+		}
 	}
-	name := e.TemplateFile[strings.LastIndex(e.TemplateFile, "/")+1:]
-	t, err := template.New(name).Funcs(funcMap).ParseFiles(e.TemplateFile)
+	path := e.dayLog(day)
+	messages, err := readMessages(path)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
+		return nil, time.Time{}, fmt.Errorf("failed to read messages from %s: %w", path, err)
 	}
+	dayLen := len(messages)
 
-	var h bytes.Buffer
-	if err := t.ExecuteTemplate(&h, name, data); err != nil {
-		log.Printf("[WARN] failed to execute template: %v", err)
-		return "", nil
+	// the show can cross midnight, the next day log is optional
+	next := e.dayLog(day.AddDate(0, 0, 1))
+	nextMessages, err := readMessages(next)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, time.Time{}, fmt.Errorf("failed to read messages from %s: %w", next, err)
 	}
-	return h.String(), nil
+	messages = append(messages, nextMessages...)
+	res, start := e.broadcast(messages, dayLen, w.PadBefore, w.PadAfter)
+	return res, start, nil
 }
 
-func (e *Exporter) timestampHuman(t time.Time) string {
-	return t.In(e.location).Format("15:04:05")
-}
+// broadcast returns chat messages between the first "started" and the last "finished" markers,
+// extended by padding around the markers. Without the "started" marker messages exported from the beginning,
+// without the "finished" one till the end of the first dayLen messages. Markers are never exported.
+// Messages after dayLen are the next day log, searched for the "finished" marker only till its first
+// "started" marker, which belongs to another broadcast.
+// Returns time of the "started" marker, zero if not found.
+func (e *Exporter) broadcast(messages []bot.Message, dayLen int, padBefore, padAfter time.Duration) (res []bot.Message, start time.Time) {
+	started, finished := -1, -1
+	for i, msg := range messages {
+		if !e.isMarker(msg) {
+			continue
+		}
+		if i >= dayLen && strings.Contains(msg.Text, bot.MsgBroadcastStarted) {
+			break
+		}
+		if started < 0 && strings.Contains(msg.Text, bot.MsgBroadcastStarted) {
+			started = i
+		}
+		if strings.Contains(msg.Text, bot.MsgBroadcastFinished) {
+			finished = i
+		}
+	}
 
-func (e *Exporter) maybeDownloadFile(fileID string) error {
-	if fileID == "" {
-		return nil
+	lo, hi := 0, dayLen
+	if started < 0 {
+		log.Print(`[WARN] "BroadcastStarted" message not found, exporting messages from the beginning`)
+	} else {
+		lo, start = started, messages[started].Sent
+		for padBefore > 0 && lo > 0 && !messages[lo-1].Sent.Before(messages[started].Sent.Add(-padBefore)) {
+			lo--
+		}
+	}
+	if finished < 0 || finished < started {
+		if len(messages) > 0 {
+			log.Print(`[WARN] "BroadcastFinished" message not found, exporting messages till the end`)
+		}
+	} else {
+		hi = finished + 1
+		for padAfter > 0 && hi < len(messages) && !messages[hi].Sent.After(messages[finished].Sent.Add(padAfter)) {
+			hi++
+		}
 	}
 
-	if _, found := e.fileIDToURL[fileID]; found {
-		// already downloaded
-		return nil
+	res = []bot.Message{}
+	for _, msg := range messages[lo:hi] {
+		if !e.isMarker(msg) {
+			res = append(res, msg)
+		}
 	}
+	return res, start
+}
 
-	fileExists, err := e.storage.FileExists(fileID)
-	if err != nil {
-		return fmt.Errorf("failed to check if file %s exists: %w", fileID, err)
+// isMarker checks if the message is broadcast started or finished marker posted by broadcast users
+func (e *Exporter) isMarker(msg bot.Message) bool {
+	if e.BroadcastUsers == nil || !e.BroadcastUsers.IsSuper(msg.From.Username) {
+		return false
 	}
+	return strings.Contains(msg.Text, bot.MsgBroadcastStarted) || strings.Contains(msg.Text, bot.MsgBroadcastFinished)
+}
+
+func (e *Exporter) dayLog(day time.Time) string {
+	return fmt.Sprintf("%s/%s.log", e.InputRoot, day.Format("20060102"))
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
 
-	if fileExists {
-		e.fileIDToURL[fileID] = e.storage.BuildLink(fileID)
-		return nil
+// prepare makes export records, downloads images of messages. Offsets from the broadcast start set if start is known.
+func (e *Exporter) prepare(messages []bot.Message, num int, start time.Time) ShowExport {
+	show := ShowExport{Num: num, Start: start, Records: make([]ExportRecord, 0, len(messages))}
+	for _, msg := range messages {
+		rec := ExportRecord{
+			Time:   msg.Sent.In(e.Location).Format(e.TimeFormat),
+			Msg:    msg,
+			IsHost: e.SuperUsers.IsSuper(msg.From.Username),
+			IsBot:  isBot(msg.From, e.BotUsername),
+		}
+		if !start.IsZero() {
+			rec.Offset = formatOffset(msg.Sent.Sub(start))
+		}
+		if msg.ID != 0 {
+			rec.Anchor = fmt.Sprintf("msg-%d", msg.ID)
+		}
+		if msg.Image != nil {
+			media, err := e.storeMedia(msg.Image)
+			if err != nil {
+				log.Printf("[WARN] failed to download, %v", err)
+			}
+			rec.Media = media
+		}
+		show.Records = append(show.Records, rec)
 	}
+	show.Records = threadReplies(show.Records, e.BotUsername)
+	return show
+}
 
-	log.Printf("[DEBUG] downloading file %s", fileID)
-	body, err := e.fileRecipient.GetFile(fileID)
-	if err != nil {
-		return fmt.Errorf("failed to get file body for %s: %w", fileID, err)
+// isBot checks if the user is the bot. Bot username can be unknown in offline export, no one is the bot then.
+func isBot(user bot.User, botUsername string) bool {
+	return botUsername != "" && user.Username == botUsername
+}
+
+// formatOffset formats offset from the broadcast start as +01:23:45, negative for messages before the start
+func formatOffset(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign, d = "-", -d
 	}
-	defer body.Close()
+	d = d.Truncate(time.Second)
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
 
-	bodyBytes, err := io.ReadAll(body)
-	if err != nil {
-		return fmt.Errorf("failed to read file body for %s: %w", fileID, err)
+// maxReplySnippetLen is the max number of runes of the quoted message in reply
+const maxReplySnippetLen = 100
+
+// threadReplies adds quotes of replied messages, linked to the replied message if it is exported.
+// Bot replies and replies to the bot moved right after the replied message as q&a pairs.
+func threadReplies(records []ExportRecord, botUsername string) []ExportRecord {
+	byKey := map[string]int{}
+	for i, rec := range records {
+		byKey[messageKey(rec.Msg.ID, rec.Msg.From, rec.Msg.Sent)] = i
+		byKey[messageKey(0, rec.Msg.From, rec.Msg.Sent)] = i
 	}
-	log.Printf("[DEBUG] downloaded file %s", fileID)
 
-	fileURL, err := e.storage.CreateFile(fileID, bodyBytes)
-	if err != nil {
-		return fmt.Errorf("failed to create file %s: %w", fileID, err)
+	answers := map[int][]int{} // replied record -> q&a answers
+	isAnswer := make([]bool, len(records))
+	for i := range records {
+		replyTo := records[i].Msg.ReplyTo
+		if replyTo.Text == "" && replyTo.Sent.IsZero() {
+			continue
+		}
+		author := replyTo.From.DisplayName
+		if strings.TrimSpace(author) == "" {
+			author = replyTo.From.Username
+		}
+		text := []rune(strings.Join(strings.Fields(replyTo.Text), " "))
+		if len(text) > maxReplySnippetLen {
+			text = append(text[:maxReplySnippetLen], '…')
+		}
+		reply := &ExportReply{Author: author, Text: string(text)}
+
+		parent, found := byKey[messageKey(replyTo.ID, replyTo.From, replyTo.Sent)]
+		if found && parent < i {
+			reply.ID, reply.Anchor = records[parent].Msg.ID, records[parent].Anchor
+			if records[i].IsBot || isBot(replyTo.From, botUsername) {
+				answers[parent] = append(answers[parent], i)
+				isAnswer[i] = true
+			}
+		}
+		records[i].Reply = reply
+	}
+	if len(answers) == 0 {
+		return records
 	}
 
-	e.fileIDToURL[fileID] = fileURL
-	// hacky: need to pass fileURL to template
-	// using this map in template FuncMap later
-	return nil
+	res := make([]ExportRecord, 0, len(records))
+	var add func(i int)
+	add = func(i int) {
+		res = append(res, records[i])
+		for _, a := range answers[i] {
+			records[a].IsAnswer = true
+			add(a)
+		}
+	}
+	for i := range records {
+		if !isAnswer[i] {
+			add(i)
+		}
+	}
+	return res
 }
 
-func readMessages(path string, broadcastUsers SuperUser) ([]bot.Message, error) {
-	file, err := os.Open(path) // nolint
+// readMessages reads log file, compressed path.gz used if the log file doesn't exist
+func readMessages(path string) ([]bot.Message, error) {
+	file, err := openLog(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open log file %s: %w", path, err)
 	}
 	defer func() {
 		if err := file.Close(); err != nil {
-			log.Printf("[WARN] can't close %s", file.Name())
+			log.Printf("[WARN] can't close %s", path)
 		}
 	}()
 
 	messages := []bot.Message{}
-	var (
-		currentIndex           uint
-		broadcastStartedIndex  uint
-		broadcastFinishedIndex uint
-	)
-
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		msg := bot.Message{}
@@ -241,47 +410,26 @@ func readMessages(path string, broadcastUsers SuperUser) ([]bot.Message, error)
 			log.Printf("[ERROR] failed to unmarshal %s, error=%v", line, err)
 			continue
 		}
-
-		if broadcastUsers != nil && broadcastUsers.IsSuper(msg.From.Username) {
-			// if received message from bot/user who can send "broadcast" messages
-			if strings.Contains(msg.Text, bot.MsgBroadcastStarted) {
-				if broadcastStartedIndex == 0 {
-					// record first occurrence of MsgBroadcastFinished
-					broadcastStartedIndex = currentIndex
-				}
-				continue
-			}
-
-			if strings.Contains(msg.Text, bot.MsgBroadcastFinished) {
-				// record last occurrence of MsgBroadcastFinished
-				broadcastFinishedIndex = currentIndex
-				continue
-			}
-		}
-
-		if filter(msg) {
-			continue
-		}
 		messages = append(messages, msg)
-		currentIndex++
 	}
 
-	if broadcastStartedIndex == 0 {
-		log.Print(`[WARN] "BroadcastStarted" message not found, exporting messages from the beginning`)
-	}
-	if broadcastFinishedIndex == 0 && len(messages) > 0 {
-		log.Print(`[WARN] "BroadcastFinished" message not found, exporting messages till the end`)
-		broadcastFinishedIndex = uint(len(messages))
-	}
-
-	messages = messages[broadcastStartedIndex:broadcastFinishedIndex]
-
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("failed to scan log file: %w", err)
 	}
 	return messages, nil
 }
 
+// withoutReactions drops +1 and -1 messages, they are counted in stats only
+func withoutReactions(messages []bot.Message) []bot.Message {
+	res := make([]bot.Message, 0, len(messages))
+	for _, msg := range messages {
+		if !filter(msg) {
+			res = append(res, msg)
+		}
+	}
+	return res
+}
+
 func filter(msg bot.Message) bool {
 	contains := func(s []string, e string) bool {
 		e = strings.TrimSpace(strings.ToLower(e))
@@ -295,50 +443,119 @@ func filter(msg bot.Message) bool {
 	return contains([]string{"+1", "-1", ":+1:", ":-1:"}, msg.Text)
 }
 
-func format(text string, entities *[]bot.Entity) (out template.HTML) {
+// htmlMarkup formats messages for html export
+var htmlMarkup = markup{escape: html.EscapeString, decorate: getDecoration}
+
+func format(text string, entities *[]bot.Entity) template.HTML {
+	return template.HTML(strings.ReplaceAll(htmlMarkup.format(text, entities), "\n", "<br>")) // nolint
+}
+
+// markup formats text with entities for an export format
+type markup struct {
+	escape   func(string) string                      // escapes plain text and entity bodies
+	decorate func(bot.Entity, []rune) (op, cl string) // markup around entity body, empty for ignored entity
+	verbatim map[string]bool                          // entity types with unescaped body, i.e. code
+}
+
+// span is a decorated entity, start and end in utf-16 code units as telegram counts offsets
+type span struct {
+	entity     bot.Entity
+	start, end int
+	op, cl     string
+}
+
+// format applies entities to the text. Entities can be nested and overlapping, tags of overlapping entities
+// closed and reopened to keep the markup well-formed. Broken entities, i.e. out of the text, are skipped.
+func (m markup) format(text string, entities *[]bot.Entity) (out string) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("[ERROR] failed to format %q, %#v", text, entities)
 		}
 	}()
 
-	out = template.HTML(strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")) // nolint
+	out = m.escape(text)
 	if entities == nil {
 		return out
 	}
 
-	runes := []rune(text)
-	result := ""
-	pos := 0
-
-	for _, entity := range *entities {
-		if entity.Offset < pos {
-			// current code does not support nested entities
+	units := utf16.Encode([]rune(text))
+	spans := make([]span, 0, len(*entities))
+	bounds := make([]int, 0, 2*len(*entities))
+	for _, e := range *entities {
+		start, end := e.Offset, e.Offset+e.Length
+		if e.Length <= 0 || start < 0 || end > len(units) {
 			continue
 		}
-
-		before, after := getDecoration(
-			entity,
-			runes[entity.Offset:entity.Offset+entity.Length],
-		)
-
-		if before == "" && after == "" {
+		op, cl := m.decorate(e, utf16.Decode(units[start:end]))
+		if op == "" && cl == "" {
 			continue
 		}
-
-		result += html.EscapeString(string(runes[pos:entity.Offset])) +
-			before +
-			html.EscapeString(string(runes[entity.Offset:entity.Offset+entity.Length])) +
-			after
-
-		pos = entity.Offset + entity.Length
+		spans = append(spans, span{entity: e, start: start, end: end, op: op, cl: cl})
+		bounds = append(bounds, start, end)
+	}
+	if len(spans) == 0 {
+		return out
 	}
+	// outer entities opened first
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+	sort.Ints(bounds)
+
+	var b strings.Builder
+	var open []span // innermost last
+	next, pos := 0, 0
+	for i, bound := range bounds {
+		if i > 0 && bound == bounds[i-1] {
+			continue
+		}
+		b.WriteString(m.text(units[pos:bound], open))
+		pos = bound
+
+		// close spans ending here, inner spans closed before and reopened after
+		closeFrom := -1
+		for k, sp := range open {
+			if sp.end == bound {
+				closeFrom = k
+				break
+			}
+		}
+		if closeFrom >= 0 {
+			var reopen []span
+			for k := len(open) - 1; k >= closeFrom; k-- {
+				b.WriteString(open[k].cl)
+				if open[k].end != bound {
+					reopen = append([]span{open[k]}, reopen...)
+				}
+			}
+			open = open[:closeFrom]
+			for _, sp := range reopen {
+				b.WriteString(sp.op)
+				open = append(open, sp)
+			}
+		}
 
-	if len(runes) > pos {
-		result += html.EscapeString(string(runes[pos:]))
+		for ; next < len(spans) && spans[next].start == bound; next++ {
+			b.WriteString(spans[next].op)
+			open = append(open, spans[next])
+		}
 	}
+	b.WriteString(m.text(units[pos:], open))
+	return b.String()
+}
 
-	return template.HTML(strings.ReplaceAll(result, "\n", "<br>")) // nolint
+// text escapes plain part of the text, unless inside verbatim entity
+func (m markup) text(units []uint16, open []span) string {
+	s := string(utf16.Decode(units))
+	for _, sp := range open {
+		if m.verbatim[sp.entity.Type] {
+			return s
+		}
+	}
+	return m.escape(s)
 }
 
 // getDecoration returns a pair of HTML tags (decorations) for Telegram Entity
@@ -389,8 +606,21 @@ func getDecoration(entity bot.Entity, body []rune) (op, cl string) {
 	case "phone_number":
 		return fmt.Sprintf("<a href=\"tel:%s\">", cleanPhoneNumber(string(body))), "</a>"
 
-	// intentionally ignored:
 	case "text_mention": // for users without usernames
+		if entity.User != nil {
+			return fmt.Sprintf("<a class=\"mention\" href=\"tg://user?id=%d\">", entity.User.ID), "</a>"
+		}
+
+	case "spoiler":
+		return "<span class=\"spoiler\">", "</span>"
+
+	case "blockquote", "expandable_blockquote":
+		return "<blockquote>", "</blockquote>"
+
+	case "custom_emoji": // body is the fallback emoji, custom emoji id is not passed by telegram api client
+		return "<span class=\"custom-emoji\">", "</span>"
+
+	// intentionally ignored:
 	case "bot_command": // "/start@jobs_bot"
 	case "hashtag": // "#hashtag"
 	case "cashtag": // "$USD"
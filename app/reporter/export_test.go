@@ -7,7 +7,9 @@ import (
 	"html/template"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 var testFile = "test.log"
@@ -58,7 +61,7 @@ func TestExporter_Export(t *testing.T) {
 			err := createFile(from, msgs)
 			assert.NoError(t, err)
 			defer os.Remove(from)
-			err = e.Export(tt.showNum, tt.yyyymmdd)
+			_, err = e.Export(tt.showNum, ExportWindow{Day: tt.yyyymmdd})
 			assert.NoError(t, err)
 			assert.FileExists(t, tt.output)
 		})
@@ -85,6 +88,25 @@ func Test_filter(t *testing.T) {
 	}
 }
 
+func Test_isBot(t *testing.T) {
+	tbl := []struct {
+		user        bot.User
+		botUsername string
+		res         bool
+	}{
+		{bot.User{Username: "rtbot"}, "rtbot", true},
+		{bot.User{Username: "user"}, "rtbot", false},
+		{bot.User{DisplayName: "no username"}, "rtbot", false},
+		{bot.User{DisplayName: "no username"}, "", false},
+		{bot.User{Username: "rtbot"}, "", false},
+	}
+	for i, tt := range tbl {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tt.res, isBot(tt.user, tt.botUsername))
+		})
+	}
+}
+
 func Test_readMessages(t *testing.T) {
 	tbl := []struct {
 		createFile bool
@@ -103,7 +125,7 @@ func Test_readMessages(t *testing.T) {
 				defer os.Remove(testFile)
 				assert.NoError(t, err)
 			}
-			msgs, err := readMessages(testFile, nil)
+			msgs, err := readMessages(testFile)
 			if tt.fail {
 				assert.Error(t, err)
 			}
@@ -112,7 +134,18 @@ func Test_readMessages(t *testing.T) {
 	}
 }
 
-func Test_readMessagesCheckBroadcastMessages(t *testing.T) {
+func Test_readMessagesCompressed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "20240302.log")
+	require.NoError(t, createFile(path, msgs))
+	require.NoError(t, compressLog(path))
+
+	res, err := readMessages(path)
+	require.NoError(t, err)
+	assert.Equal(t, msgs, res)
+}
+
+func TestExporter_broadcast(t *testing.T) {
 	tbl := []struct {
 		broadcastUsers SuperUserMock
 		in             []bot.Message
@@ -252,13 +285,91 @@ func Test_readMessagesCheckBroadcastMessages(t *testing.T) {
 
 	for i, tt := range tbl {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
-			err := createFile(testFile, tt.in)
-			defer os.Remove(testFile)
-			assert.NoError(t, err)
+			e := Exporter{ExporterParams: ExporterParams{BroadcastUsers: tt.broadcastUsers}}
+			res, _ := e.broadcast(tt.in, len(tt.in), 0, 0)
+			assert.Equal(t, tt.out, res)
+		})
+	}
+	t.Run("next day", func(t *testing.T) {
+		e := Exporter{ExporterParams: ExporterParams{BroadcastUsers: SuperUserMock{"radio-t-bot": true}}}
+		day := []bot.Message{
+			{Text: bot.MsgBroadcastStarted, From: bot.User{Username: "radio-t-bot"}},
+			{Text: "message-1", From: bot.User{Username: "user-1"}},
+		}
+		next := []bot.Message{
+			{Text: "message-2", From: bot.User{Username: "user-2"}},
+			{Text: bot.MsgBroadcastFinished, From: bot.User{Username: "radio-t-bot"}},
+			{Text: "message-3", From: bot.User{Username: "user-3"}},
+			{Text: bot.MsgBroadcastStarted, From: bot.User{Username: "radio-t-bot"}},
+			{Text: "message-4", From: bot.User{Username: "user-4"}},
+			{Text: bot.MsgBroadcastFinished, From: bot.User{Username: "radio-t-bot"}},
+		}
+		res, _ := e.broadcast(append(day, next...), len(day), 0, 0)
+		assert.Equal(t, []bot.Message{
+			{Text: "message-1", From: bot.User{Username: "user-1"}},
+			{Text: "message-2", From: bot.User{Username: "user-2"}},
+		}, res, "finished before the next broadcast started")
+
+		res, _ = e.broadcast(append(day, next[2:]...), len(day), 0, 0)
+		assert.Equal(t, []bot.Message{{Text: "message-1", From: bot.User{Username: "user-1"}}}, res,
+			"finish of the next broadcast ignored, exported till the end of the show day")
+	})
+}
 
-			msgs, err := readMessages(testFile, tt.broadcastUsers)
-			assert.NoError(t, err)
-			assert.Equal(t, tt.out, msgs)
+func TestExporter_readWindow(t *testing.T) {
+	dir := t.TempDir()
+	e := Exporter{ExporterParams: ExporterParams{InputRoot: dir, BroadcastUsers: SuperUserMock{"radio-t-bot": true}}}
+	ts := time.Date(2024, 3, 2, 23, 0, 0, 0, time.Local)
+	msg := func(text string, d time.Duration) bot.Message {
+		return bot.Message{Text: text, Sent: ts.Add(d), From: bot.User{Username: "user"}}
+	}
+	marker := func(text string, d time.Duration) bot.Message {
+		return bot.Message{Text: text, Sent: ts.Add(d), From: bot.User{Username: "radio-t-bot"}}
+	}
+	require.NoError(t, createFile(filepath.Join(dir, "20240302.log"), []bot.Message{
+		msg("before", -2*time.Hour), msg("prelude", -10*time.Minute), marker(bot.MsgBroadcastStarted, 0),
+		msg("show-1", 30*time.Minute),
+	}))
+	require.NoError(t, createFile(filepath.Join(dir, "20240303.log"), []bot.Message{
+		msg("show-2", 90*time.Minute), marker(bot.MsgBroadcastFinished, 2*time.Hour),
+		msg("aftershow", 2*time.Hour+20*time.Minute), msg("later", 5*time.Hour),
+	}))
+	texts := func(msgs []bot.Message) (res []string) {
+		for _, m := range msgs {
+			res = append(res, m.Text)
+		}
+		return res
+	}
+
+	tbl := []struct {
+		name   string
+		window ExportWindow
+		res    []string
+		start  time.Time
+		fail   bool
+	}{
+		{"markers across midnight", ExportWindow{Day: 20240302}, []string{"show-1", "show-2"}, ts, false},
+		{"markers with padding", ExportWindow{Day: 20240302, PadBefore: 15 * time.Minute, PadAfter: 30 * time.Minute},
+			[]string{"prelude", "show-1", "show-2", "aftershow"}, ts, false},
+		{"finished marker only", ExportWindow{Day: 20240303}, []string{"show-2"}, time.Time{}, false},
+		{"range", ExportWindow{From: ts.Add(time.Minute), To: ts.Add(3 * time.Hour)},
+			[]string{"show-1", "show-2", "aftershow"}, ts.Add(time.Minute), false},
+		{"range with padding", ExportWindow{From: ts, To: ts.Add(2 * time.Hour), PadBefore: time.Hour, PadAfter: 4 * time.Hour},
+			[]string{"prelude", "show-1", "show-2", "aftershow", "later"}, ts, false},
+		{"no day log", ExportWindow{Day: 20240301}, nil, time.Time{}, true},
+		{"no logs in range", ExportWindow{From: ts.AddDate(0, 1, 0), To: ts.AddDate(0, 1, 1)}, nil, time.Time{}, true},
+		{"invalid range", ExportWindow{From: ts, To: ts.Add(-time.Hour)}, nil, time.Time{}, true},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			res, start, err := e.readWindow(tt.window)
+			if tt.fail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.res, texts(res))
+			assert.True(t, tt.start.Equal(start), "start %v, expected %v", start, tt.start)
 		})
 	}
 }
@@ -286,7 +397,7 @@ func Test_downloadFilesNeverCalledForTextMessages(t *testing.T) {
 	assert.NoError(t, err)
 	defer os.Remove(e.InputRoot + "/20200111.log")
 
-	err = e.Export(684, 20200111)
+	_, err = e.Export(684, ExportWindow{Day: 20200111})
 	assert.NoError(t, err)
 
 	fileRecipient.AssertExpectations(t)
@@ -311,8 +422,9 @@ func Test_downloadFilesImage(t *testing.T) {
 	fileRecipient.On("GetFile", "FILE_ID").Return(buffer("IMAGE"), nil).Once()
 
 	storage := new(storageMock)
-	storage.On("FileExists", "FILE_ID").Return(false, nil).Once()
-	storage.On("CreateFile", "FILE_ID", []byte("IMAGE")).Return("684/FILE_ID", nil).Once()
+	name := "6b3cf57c7b136ef3ebfab4e4a24a0fd4241f4fb43e6bedebb624375dcd3d1332.bin" // sha256 of content, unknown type
+	storage.On("FileExists", name).Return(false, nil).Once()
+	storage.On("CreateFile", name, []byte("IMAGE")).Return("684/"+name, nil).Once()
 
 	e, err := setup(fileRecipient, storage)
 	assert.NoError(t, err)
@@ -322,7 +434,7 @@ func Test_downloadFilesImage(t *testing.T) {
 	assert.NoError(t, err)
 	defer os.Remove(e.InputRoot + "/20200111.log")
 
-	err = e.Export(684, 20200111)
+	_, err = e.Export(684, ExportWindow{Day: 20200111})
 	assert.NoError(t, err)
 
 	fileRecipient.AssertExpectations(t)
@@ -367,7 +479,7 @@ func TestExporter_format(t *testing.T) {
 		{
 			"Firstname Surname получает бан на 2m3s",
 			&[]bot.Entity{{Type: "text_mention", Offset: 0, Length: 17, User: &bot.User{ID: 900000000, Username: "", DisplayName: "Firstname Surname"}}},
-			"Firstname Surname получает бан на 2m3s",
+			"<a class=\"mention\" href=\"tg://user?id=900000000\">Firstname Surname</a> получает бан на 2m3s",
 		},
 		{
 			"Меня url заинтересовал... do.co",
@@ -427,13 +539,48 @@ func TestExporter_format(t *testing.T) {
 		{
 			"must show say.data",
 			&[]bot.Entity{{Type: "bold", Offset: 0, Length: 18}, {Type: "url", Offset: 10, Length: 8}},
-			"<strong>must show say.data</strong>",
+			"<strong>must show <a href=\"https://say.data\">say.data</a></strong>",
 		},
 		{
 			"must show say.data",
 			&[]bot.Entity{{Type: "bold", Offset: 200, Length: 18}}, // to cause panic
 			"must show say.data",
 		},
+		{
+			"👍🏻 bold after emoji",
+			&[]bot.Entity{{Type: "custom_emoji", Offset: 0, Length: 4}, {Type: "bold", Offset: 5, Length: 4}},
+			"<span class=\"custom-emoji\">👍🏻</span> <strong>bold</strong> after emoji",
+		},
+		{
+			"italic code inside",
+			&[]bot.Entity{{Type: "italic", Offset: 0, Length: 18}, {Type: "code", Offset: 7, Length: 4}},
+			"<em>italic <code>code</code> inside</em>",
+		},
+		{
+			"bold and italic overlap",
+			&[]bot.Entity{{Type: "italic", Offset: 5, Length: 18}, {Type: "bold", Offset: 0, Length: 15}},
+			"<strong>bold <em>and italic</em></strong><em> overlap</em>",
+		},
+		{
+			"same range",
+			&[]bot.Entity{{Type: "bold", Offset: 0, Length: 10}, {Type: "italic", Offset: 0, Length: 10}},
+			"<strong><em>same range</em></strong>",
+		},
+		{
+			"spoiler: <b>",
+			&[]bot.Entity{{Type: "spoiler", Offset: 9, Length: 3}},
+			"spoiler: <span class=\"spoiler\">&lt;b&gt;</span>",
+		},
+		{
+			"quote\nlines",
+			&[]bot.Entity{{Type: "blockquote", Offset: 0, Length: 11}},
+			"<blockquote>quote<br>lines</blockquote>",
+		},
+		{
+			"broken",
+			&[]bot.Entity{{Type: "bold", Offset: -1, Length: 3}, {Type: "bold", Offset: 2, Length: 0}},
+			"broken",
+		},
 	}
 
 	for i, tt := range tbl {
@@ -455,11 +602,6 @@ func setup(fileRecipient FileRecipient, storage Storage) (*Exporter, error) {
 		return nil, err
 	}
 
-	location, err := time.LoadLocation("Europe/Moscow")
-	if err != nil {
-		return nil, err
-	}
-
 	f, err := os.Create(testExportParams.TemplateFile)
 	if err != nil {
 		return nil, err
@@ -468,12 +610,14 @@ func setup(fileRecipient FileRecipient, storage Storage) (*Exporter, error) {
 		return nil, err
 	}
 
+	params := testExportParams
+	params.Location, params.TimeFormat = time.UTC, "15:04:05"
 	e := &Exporter{
-		ExporterParams: testExportParams,
-		location:       location,
+		ExporterParams: params,
 		fileRecipient:  fileRecipient,
 		storage:        storage,
-		fileIDToURL:    map[string]string{},
+		media:          map[string]*Media{},
+		titles:         map[string]string{},
 	}
 
 	return e, nil
@@ -542,11 +686,6 @@ func (sm *storageMock) BuildLink(fileName string) string {
 	return args.String(0)
 }
 
-func (sm *storageMock) BuildPath(fileName string) string {
-	args := sm.Called(fileName)
-	return args.String(0)
-}
-
 // closingBuffer used in mocks to represent resp.Body, implements io.ReadCloser
 type closingBuffer struct {
 	*bytes.Buffer
@@ -560,3 +699,91 @@ func (cb *closingBuffer) Close() error {
 func buffer(content string) io.ReadCloser {
 	return &closingBuffer{bytes.NewBufferString(content)}
 }
+
+func Test_threadReplies(t *testing.T) {
+	ts := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	user := bot.User{ID: 1, Username: "user", DisplayName: "Some User"}
+	rtBot := bot.User{ID: 2, Username: "rtbot", DisplayName: "Radio-T Bot"}
+	msg := func(id int, from bot.User, d time.Duration, text string) ExportRecord {
+		m := bot.Message{ID: id, From: from, Sent: ts.Add(d), Text: text}
+		return ExportRecord{Msg: m, Anchor: fmt.Sprintf("msg-%d", id), IsBot: from.Username == "rtbot"}
+	}
+	reply := func(rec ExportRecord, to ExportRecord, withID bool) ExportRecord {
+		rec.Msg.ReplyTo.From, rec.Msg.ReplyTo.Sent, rec.Msg.ReplyTo.Text = to.Msg.From, to.Msg.Sent, to.Msg.Text
+		if withID {
+			rec.Msg.ReplyTo.ID = to.Msg.ID
+		}
+		return rec
+	}
+
+	question := msg(1, user, 0, "когда?")
+	other := msg(2, bot.User{Username: "other"}, time.Second, "привет")
+	answer := reply(msg(3, rtBot, 2*time.Second, "через час"), question, true)
+	replyOld := reply(msg(4, bot.User{Username: "other"}, 3*time.Second, "ответ"), other, false) // logged without reply id
+	replyMissing := msg(5, user, 4*time.Second, "а это?")
+	replyMissing.Msg.ReplyTo.Text = strings.Repeat("длинный ", 20)
+	replyMissing.Msg.ReplyTo.Sent = ts.Add(-time.Hour)
+	replyMissing.Msg.ReplyTo.From = bot.User{Username: "gone"}
+
+	res := threadReplies([]ExportRecord{question, other, answer, replyOld, replyMissing}, "rtbot")
+	ids := []int{}
+	for _, r := range res {
+		ids = append(ids, r.Msg.ID)
+	}
+	assert.Equal(t, []int{1, 3, 2, 4, 5}, ids, "bot answer moved after the question")
+
+	assert.Nil(t, res[0].Reply)
+	assert.Equal(t, &ExportReply{ID: 1, Anchor: "msg-1", Author: "Some User", Text: "когда?"}, res[1].Reply)
+	assert.True(t, res[1].IsAnswer)
+	assert.Equal(t, &ExportReply{ID: 2, Anchor: "msg-2", Author: "other", Text: "привет"}, res[3].Reply)
+	assert.False(t, res[3].IsAnswer)
+	assert.Equal(t, "gone", res[4].Reply.Author)
+	assert.Empty(t, res[4].Reply.Anchor)
+	assert.Len(t, []rune(res[4].Reply.Text), maxReplySnippetLen+1)
+}
+
+func TestExporter_ExportTemplate(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	question := bot.Message{ID: 1, Sent: ts, Text: "когда?", From: bot.User{Username: "user", DisplayName: "Some User"}}
+	answer := bot.Message{ID: 2, Sent: ts.Add(time.Second), Text: "через час", From: bot.User{Username: "rtbot"}}
+	answer.ReplyTo.ID, answer.ReplyTo.From, answer.ReplyTo.Sent, answer.ReplyTo.Text = 1, question.From, question.Sent, question.Text
+	started := bot.Message{Sent: ts.Add(-(time.Hour + 23*time.Minute + 45*time.Second)), Text: bot.MsgBroadcastStarted,
+		From: bot.User{Username: "rtbot"}}
+	link := bot.Message{ID: 3, Sent: ts.Add(2 * time.Second), Text: "https://radio-t.com/?utm_source=tg",
+		From: question.From, Entities: &[]bot.Entity{{Type: "url", Offset: 0, Length: 34}}}
+	require.NoError(t, createFile(filepath.Join(dir, "20240302.log"), []bot.Message{started, question, answer, link}))
+
+	e := NewExporter(nil, nil, ExporterParams{InputRoot: dir, OutputRoot: dir, TemplateFile: "../../data/logs.html",
+		BotUsername: "rtbot", SuperUsers: SuperUserMock{}, BroadcastUsers: SuperUserMock{"rtbot": true},
+		Location: time.FixedZone("MSK", 3*3600), TimeFormat: "15:04"})
+	res, err := e.Export(900, ExportWindow{Day: 20240302})
+	require.NoError(t, err)
+	assert.Equal(t, dir+"/radio-t-900.html", res)
+	data, err := os.ReadFile(filepath.Join(dir, "radio-t-900.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<tr class="bot answer" id="msg-2">`)
+	assert.Contains(t, string(data), `<a class="reply" href="#msg-1">Some User</a>: когда?`)
+	assert.Contains(t, string(data), `Статистика чата: 3 сообщений`)
+	assert.Contains(t, string(data), `<span class="stats__bar" style="height: 3px" title="23:00: 3"></span>`)
+	assert.Contains(t, string(data), `<li>user – 2</li>`)
+	assert.Contains(t, string(data), `<div class="stats__block"><b>radio-t.com</b>
+                <ul><li><a href="https://radio-t.com">https://radio-t.com</a> – user</li></ul></div>`)
+	assert.Contains(t, string(data), `<td class="success" align="left">23:00 <span class="offset">&#43;01:23:45</span></td>`)
+	assert.Contains(t, string(data), `<span class="offset">&#43;01:23:46</span>`)
+}
+
+func Test_formatOffset(t *testing.T) {
+	tbl := []struct {
+		d   time.Duration
+		out string
+	}{
+		{0, "+00:00:00"},
+		{time.Hour + 23*time.Minute + 45*time.Second + 900*time.Millisecond, "+01:23:45"},
+		{-5 * time.Minute, "-00:05:00"},
+		{27 * time.Hour, "+27:00:00"},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.out, formatOffset(tt.d), tt.d.String())
+	}
+}
@@ -0,0 +1,354 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// bolt buckets, message versions keyed by message id + version and indexes with keys pointing
+// to the message id, resolved to the last version
+var (
+	bktMessages = []byte("messages") // id + version
+	bktByTime   = []byte("by_time")  // time + id
+	bktByUser   = []byte("by_user")  // lowercase username + 0 + time + id
+	bktByShow   = []byte("by_show")  // show number + time + id
+)
+
+// History keeps chat messages in the embedded bolt database with indexes on time, user and show number.
+// Changed message saved as a new version of the telegram message id, the last version is indexed and queried,
+// saving the same message again is ignored.
+type History struct {
+	db *bolt.DB
+}
+
+// HistoryQuery defines history search. All non-empty fields should match, zero From and To are open range.
+type HistoryQuery struct {
+	From  time.Time // inclusive
+	To    time.Time // exclusive
+	User  string    // username, case-insensitive
+	Text  string    // substring of message text, case-insensitive
+	Show  int       // show number assigned with AssignShow
+	Limit int       // max number of messages, 0 for unlimited
+}
+
+// HistoryRecord is a message stored in the history
+type HistoryRecord struct {
+	Message bot.Message `json:"msg"`
+	Show    int         `json:"show,omitempty"`
+	Version int         `json:"version"` // starts from 1, increased on each change of the message
+}
+
+// NewHistory opens or creates history database
+func NewHistory(path string) (*History, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to make dir for %s: %w", path, err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bkt := range [][]byte{bktMessages, bktByTime, bktByUser, bktByShow} {
+			if _, e := tx.CreateBucketIfNotExists(bkt); e != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", bkt, e)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	log.Printf("[INFO] history opened, path=%s", path)
+	return &History{db: db}, nil
+}
+
+// Add saves messages in one transaction. Changed message of already stored id saved as its new version
+// with the same show number, unchanged one ignored.
+func (h *History) Add(msgs []bot.Message) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		for _, msg := range msgs {
+			rec := HistoryRecord{Message: msg, Version: 1}
+			if old, err := h.get(tx, msg.ID); err == nil {
+				if sameMessage(old.Message, msg) {
+					continue
+				}
+				rec.Show, rec.Version = old.Show, old.Version+1
+				if err := h.unindex(tx, old); err != nil {
+					return err
+				}
+			}
+			if err := h.put(tx, rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Versions returns all versions of the message ordered from the first one, empty if not found
+func (h *History) Versions(msgID int) (res []HistoryRecord, err error) {
+	err = h.db.View(func(tx *bolt.Tx) error {
+		res, err = h.versions(tx, msgID)
+		return err
+	})
+	return res, err
+}
+
+// Delete removes messages with all versions by ids, unknown ids ignored
+func (h *History) Delete(msgIDs ...int) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range msgIDs {
+			rec, err := h.get(tx, id)
+			if err != nil {
+				continue
+			}
+			if err := h.unindex(tx, rec); err != nil {
+				return err
+			}
+			if err := h.deleteVersions(tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AssignShow sets show number for all messages in [from, to) time range, returns the number of updated messages.
+// Range is checked by the last version of the message, all versions updated.
+func (h *History) AssignShow(show int, from, to time.Time) (count int, err error) {
+	err = h.db.Update(func(tx *bolt.Tx) error {
+		recs, err := h.scan(tx, bktByTime, nil, from, to)
+		if err != nil {
+			return err
+		}
+		for _, rec := range recs {
+			if err := h.unindex(tx, rec); err != nil {
+				return err
+			}
+			versions, err := h.versions(tx, rec.Message.ID)
+			if err != nil {
+				return err
+			}
+			for _, v := range versions {
+				v.Show = show
+				if v.Version == rec.Version {
+					err = h.put(tx, v)
+				} else {
+					err = h.store(tx, v)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		count = len(recs)
+		return nil
+	})
+	return count, err
+}
+
+// Query returns messages matching the query ordered by time
+func (h *History) Query(q HistoryQuery) (res []HistoryRecord, err error) {
+	// pick the most selective index, other conditions are checked on the loaded records
+	bkt, prefix := bktByTime, []byte(nil)
+	switch {
+	case q.Show > 0:
+		bkt, prefix = bktByShow, showPrefix(q.Show)
+	case q.User != "":
+		bkt, prefix = bktByUser, userPrefix(q.User)
+	}
+
+	err = h.db.View(func(tx *bolt.Tx) error {
+		recs, err := h.scan(tx, bkt, prefix, q.From, q.To)
+		if err != nil {
+			return err
+		}
+		text := strings.ToLower(q.Text)
+		for _, rec := range recs {
+			if q.User != "" && !strings.EqualFold(rec.Message.From.Username, q.User) {
+				continue
+			}
+			if q.Show > 0 && rec.Show != q.Show {
+				continue
+			}
+			if text != "" && !strings.Contains(strings.ToLower(rec.Message.Text), text) {
+				continue
+			}
+			res = append(res, rec)
+			if q.Limit > 0 && len(res) >= q.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// Close closes the database
+func (h *History) Close() error {
+	if err := h.db.Close(); err != nil {
+		return fmt.Errorf("failed to close history: %w", err)
+	}
+	return nil
+}
+
+// scan loads records from the index with given prefix and time range, ordered by time
+func (h *History) scan(tx *bolt.Tx, bkt, prefix []byte, from, to time.Time) ([]HistoryRecord, error) {
+	res := []HistoryRecord{}
+	start := prefix
+	if !from.IsZero() {
+		start = append(append([]byte{}, prefix...), timeKey(from)...)
+	}
+	c := tx.Bucket(bkt).Cursor()
+	for k, v := c.Seek(start); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if !to.IsZero() && bytes.Compare(k[len(prefix):len(prefix)+8], timeKey(to)) >= 0 {
+			break
+		}
+		rec, err := h.get(tx, int(binary.BigEndian.Uint64(v)))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rec)
+	}
+	return res, nil
+}
+
+// get returns the last version of the message
+func (h *History) get(tx *bolt.Tx, msgID int) (HistoryRecord, error) {
+	rec := HistoryRecord{}
+	prefix := idKey(msgID)
+	c := tx.Bucket(bktMessages).Cursor()
+	// seek after the last possible version of the message and step back
+	k, data := c.Seek(binary.BigEndian.AppendUint32(prefix, math.MaxUint32))
+	if k == nil {
+		k, data = c.Last()
+	} else {
+		k, data = c.Prev()
+	}
+	if k == nil || !bytes.HasPrefix(k, prefix) {
+		return rec, errors.New("not found")
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, fmt.Errorf("failed to unmarshal history record %d: %w", msgID, err)
+	}
+	return rec, nil
+}
+
+func (h *History) versions(tx *bolt.Tx, msgID int) ([]HistoryRecord, error) {
+	res := []HistoryRecord{}
+	prefix := idKey(msgID)
+	c := tx.Bucket(bktMessages).Cursor()
+	for k, data := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, data = c.Next() {
+		rec := HistoryRecord{}
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal history record %d: %w", msgID, err)
+		}
+		res = append(res, rec)
+	}
+	return res, nil
+}
+
+// put saves the last version of the message and indexes it
+func (h *History) put(tx *bolt.Tx, rec HistoryRecord) error {
+	if err := h.store(tx, rec); err != nil {
+		return err
+	}
+	id := idKey(rec.Message.ID)
+	for bkt, key := range indexKeys(rec) {
+		if err := tx.Bucket([]byte(bkt)).Put(key, id); err != nil {
+			return fmt.Errorf("failed to put %s index for %d: %w", bkt, rec.Message.ID, err)
+		}
+	}
+	return nil
+}
+
+// store saves the record as its version without indexing
+func (h *History) store(tx *bolt.Tx, rec HistoryRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history record %d: %w", rec.Message.ID, err)
+	}
+	if err := tx.Bucket(bktMessages).Put(versionKey(rec.Message.ID, rec.Version), data); err != nil {
+		return fmt.Errorf("failed to put history record %d: %w", rec.Message.ID, err)
+	}
+	return nil
+}
+
+func (h *History) deleteVersions(tx *bolt.Tx, msgID int) error {
+	prefix := idKey(msgID)
+	c := tx.Bucket(bktMessages).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+		if err := c.Delete(); err != nil {
+			return fmt.Errorf("failed to delete history record %d: %w", msgID, err)
+		}
+	}
+	return nil
+}
+
+// unindex removes index keys of the record, the record itself is kept
+func (h *History) unindex(tx *bolt.Tx, rec HistoryRecord) error {
+	for bkt, key := range indexKeys(rec) {
+		if err := tx.Bucket([]byte(bkt)).Delete(key); err != nil {
+			return fmt.Errorf("failed to delete %s index for %d: %w", bkt, rec.Message.ID, err)
+		}
+	}
+	return nil
+}
+
+// indexKeys makes keys of all indexes for the record, keyed by bucket name
+func indexKeys(rec HistoryRecord) map[string][]byte {
+	suffix := append(timeKey(rec.Message.Sent), idKey(rec.Message.ID)...)
+	res := map[string][]byte{string(bktByTime): suffix}
+	if rec.Message.From.Username != "" {
+		res[string(bktByUser)] = append(userPrefix(rec.Message.From.Username), suffix...)
+	}
+	if rec.Show > 0 {
+		res[string(bktByShow)] = append(showPrefix(rec.Show), suffix...)
+	}
+	return res
+}
+
+func idKey(msgID int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(msgID)) // nolint:gosec // telegram ids are positive
+}
+
+func versionKey(msgID, version int) []byte {
+	return binary.BigEndian.AppendUint32(idKey(msgID), uint32(version)) // nolint:gosec // versions are positive
+}
+
+// sameMessage checks if the message is not changed, i.e. saved again after restart
+func sameMessage(a, b bot.Message) bool {
+	da, errA := json.Marshal(a)
+	db, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(da, db)
+}
+
+// timeKey encodes time to sortable 8 bytes, times before unix epoch stored as zero
+func timeKey(t time.Time) []byte {
+	if t.Before(time.Unix(0, 0)) {
+		return make([]byte, 8)
+	}
+	return binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano())) // nolint:gosec // checked for negative
+}
+
+func userPrefix(username string) []byte {
+	return append([]byte(strings.ToLower(username)), 0)
+}
+
+func showPrefix(show int) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(show)) // nolint:gosec // show numbers are positive
+}
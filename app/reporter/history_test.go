@@ -0,0 +1,105 @@
+package reporter
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+func TestHistory(t *testing.T) {
+	h, err := NewHistory(filepath.Join(t.TempDir(), "history.db"))
+	require.NoError(t, err)
+	defer h.Close()
+
+	ts := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	msgs := []bot.Message{
+		{ID: 1, Sent: ts, Text: "Всем привет", From: bot.User{Username: "umputun"}},
+		{ID: 2, Sent: ts.Add(time.Minute), Text: "привет, как слышно?", From: bot.User{Username: "Bobuk"}},
+		{ID: 3, Sent: ts.Add(2 * time.Minute), Text: "отлично слышно", From: bot.User{Username: "user1"}},
+		{ID: 4, Sent: ts.Add(3 * time.Hour), Text: "после эфира", From: bot.User{Username: "umputun"}},
+	}
+	require.NoError(t, h.Add(msgs))
+
+	count, err := h.AssignShow(890, ts, ts.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	tbl := []struct {
+		name string
+		q    HistoryQuery
+		ids  []int
+	}{
+		{"all", HistoryQuery{}, []int{1, 2, 3, 4}},
+		{"range", HistoryQuery{From: ts.Add(time.Minute), To: ts.Add(time.Hour)}, []int{2, 3}},
+		{"open to", HistoryQuery{From: ts.Add(2 * time.Minute)}, []int{3, 4}},
+		{"user", HistoryQuery{User: "UMPUTUN"}, []int{1, 4}},
+		{"user and range", HistoryQuery{User: "umputun", To: ts.Add(time.Hour)}, []int{1}},
+		{"text", HistoryQuery{Text: "ПРИВЕТ"}, []int{1, 2}},
+		{"show", HistoryQuery{Show: 890}, []int{1, 2, 3}},
+		{"show and user", HistoryQuery{Show: 890, User: "bobuk"}, []int{2}},
+		{"limit", HistoryQuery{Text: "слышно", Limit: 1}, []int{2}},
+		{"nothing", HistoryQuery{User: "nobody"}, nil},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := h.Query(tt.q)
+			require.NoError(t, err)
+			var ids []int
+			for _, r := range res {
+				ids = append(ids, r.Message.ID)
+			}
+			assert.Equal(t, tt.ids, ids)
+		})
+	}
+
+	// changed message saved as a new version with the same show number, changed username re-indexed
+	require.NoError(t, h.Add(msgs[1:2]), "unchanged message ignored")
+	require.NoError(t, h.Add([]bot.Message{{ID: 2, Sent: ts.Add(time.Minute), Text: "edited", From: bot.User{Username: "bobuk2"}}}))
+	res, err := h.Query(HistoryQuery{User: "bobuk2"})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, 890, res[0].Show)
+	assert.Equal(t, "edited", res[0].Message.Text)
+	assert.Equal(t, 2, res[0].Version)
+	res, err = h.Query(HistoryQuery{User: "bobuk"})
+	require.NoError(t, err)
+	assert.Empty(t, res)
+
+	versions, err := h.Versions(2)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, HistoryRecord{Message: msgs[1], Show: 890, Version: 1}, versions[0])
+	assert.Equal(t, "edited", versions[1].Message.Text)
+
+	// show assigned to all versions, older ones not indexed
+	count, err = h.AssignShow(891, ts.Add(time.Minute), ts.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	versions, err = h.Versions(2)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, 891, versions[0].Show)
+	assert.Equal(t, 891, versions[1].Show)
+	res, err = h.Query(HistoryQuery{Show: 891})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, "edited", res[0].Message.Text)
+	res, err = h.Query(HistoryQuery{User: "bobuk"})
+	require.NoError(t, err)
+	assert.Empty(t, res)
+
+	require.NoError(t, h.Delete(1, 2, 100))
+	res, err = h.Query(HistoryQuery{})
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, 3, res[0].Message.ID)
+	assert.Equal(t, 4, res[1].Message.ID)
+	versions, err = h.Versions(2)
+	require.NoError(t, err)
+	assert.Empty(t, versions, "all versions deleted")
+}
@@ -0,0 +1,205 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// indexTop is the number of the most active participants in the index entry
+const indexTop = 5
+
+// ShowMeta is a summary of the exported show, kept next to the export in radio-t-<num>.meta.json
+// to regenerate index page and feed of all exported shows
+type ShowMeta struct {
+	Num          int       `json:"num"`
+	Date         time.Time `json:"date"` // broadcast start or the first message time
+	File         string    `json:"file"` // name of the exported file in the output root
+	Messages     int       `json:"messages"`
+	Participants []string  `json:"participants"` // the most active first
+	Exported     time.Time `json:"exported"`
+}
+
+// writeMeta saves summary of the exported show
+func (e *Exporter) writeMeta(show ShowExport, file string) error {
+	meta := ShowMeta{Num: show.Num, Date: show.Start, File: filepath.Base(file), Messages: show.Stats.Messages,
+		Participants: []string{}, Exported: time.Now()}
+	if meta.Date.IsZero() && len(show.Records) > 0 {
+		meta.Date = show.Records[0].Msg.Sent
+	}
+	for i, p := range show.Stats.Participants {
+		if i >= indexTop {
+			break
+		}
+		meta.Participants = append(meta.Participants, p.Name)
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal meta of #%d: %w", show.Num, err)
+	}
+	to := filepath.Join(e.OutputRoot, fmt.Sprintf("radio-t-%d.meta.json", show.Num))
+	if err = os.WriteFile(to, data, 0o644); err != nil { // nolint
+		return fmt.Errorf("failed to write %s: %w", to, err)
+	}
+	return nil
+}
+
+// UpdateIndex regenerates index.html and index.atom of all exported shows from their meta files
+func (e *Exporter) UpdateIndex() error {
+	metas, err := readMetas(e.OutputRoot)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err = writeIndexHTML(&buf, metas, e.Location); err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(e.OutputRoot, "index.html"), buf.Bytes(), 0o644); err != nil { // nolint
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	buf.Reset()
+	if err = writeAtom(&buf, metas, e.BaseURL); err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(e.OutputRoot, "index.atom"), buf.Bytes(), 0o644); err != nil { // nolint
+		return fmt.Errorf("failed to write feed: %w", err)
+	}
+	return nil
+}
+
+// readMetas reads meta files of all exported shows, the latest show first
+func readMetas(dir string) ([]ShowMeta, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "radio-t-*.meta.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list meta files in %s: %w", dir, err)
+	}
+	res := make([]ShowMeta, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file) // nolint
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		meta := ShowMeta{}
+		if err = json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		res = append(res, meta)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Num > res[j].Num })
+	return res, nil
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<html>
+    <head>
+        <link rel="stylesheet" href="https://maxcdn.bootstrapcdn.com/bootstrap/3.3.6/css/bootstrap.min.css" integrity="sha384-1q8mTJOASx8j1Au+a5WDVnPi2lkFfwwEAa8hDDdjZlpLegxhjVME1fgjWPGmkzs7" crossorigin="anonymous">
+        <link rel="alternate" type="application/atom+xml" title="Логи чата Радио-Т" href="index.atom">
+        <meta charset="UTF-8">
+        <title>Логи чата Радио-Т</title>
+    </head>
+    <body>
+        <table class="table table-striped table-hover table-condensed">
+        <tr><th>Выпуск</th><th>Дата</th><th>Сообщений</th><th>Самые активные слушатели</th></tr>
+        {{- range . }}
+        <tr>
+            <td><a href="{{ .File }}">#{{ .Num }}</a></td>
+            <td>{{ .Date }}</td>
+            <td>{{ .Messages }}</td>
+            <td>{{ .Participants }}</td>
+        </tr>
+        {{- end }}
+        </table>
+    </body>
+</html>
+`))
+
+// writeIndexHTML writes index page with entry per show
+func writeIndexHTML(w io.Writer, metas []ShowMeta, location *time.Location) error {
+	type entry struct {
+		Num          int
+		File         string
+		Date         string
+		Messages     int
+		Participants string
+	}
+	entries := make([]entry, 0, len(metas))
+	for _, m := range metas {
+		entries = append(entries, entry{Num: m.Num, File: m.File, Date: m.Date.In(location).Format("2006-01-02"),
+			Messages: m.Messages, Participants: strings.Join(m.Participants, ", ")})
+	}
+	if err := indexTemplate.Execute(w, entries); err != nil {
+		return fmt.Errorf("failed to execute index template: %w", err)
+	}
+	return nil
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+// writeAtom writes atom feed of exported shows, links are relative if baseURL is empty
+func writeAtom(w io.Writer, metas []ShowMeta, baseURL string) error {
+	link := func(file string) string {
+		if baseURL == "" {
+			return file
+		}
+		return strings.TrimSuffix(baseURL, "/") + "/" + file
+	}
+	feed := atomFeed{Title: "Логи чата Радио-Т", ID: "urn:radio-t:chat-logs", Entries: make([]atomEntry, 0, len(metas)),
+		Links: []atomLink{{Href: link("index.atom"), Rel: "self"}, {Href: link("index.html")}}}
+	var updated time.Time
+	for _, m := range metas {
+		if m.Exported.After(updated) {
+			updated = m.Exported
+		}
+		summary := fmt.Sprintf("%d сообщений", m.Messages)
+		if len(m.Participants) > 0 {
+			summary += ", самые активные: " + strings.Join(m.Participants, ", ")
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   fmt.Sprintf("Лог чата Радио-Т #%d", m.Num),
+			ID:      fmt.Sprintf("urn:radio-t:chat-log:%d", m.Num),
+			Updated: m.Exported.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: link(m.File)},
+			Summary: summary,
+		})
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write feed: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return fmt.Errorf("failed to encode feed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package reporter
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+func TestExporter_Index(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	msg := func(user string, d time.Duration) bot.Message {
+		return bot.Message{Sent: ts.Add(d), Text: "hi", From: bot.User{Username: user, DisplayName: user}}
+	}
+	started := bot.Message{Sent: ts, Text: bot.MsgBroadcastStarted, From: bot.User{Username: "rtbot"}}
+	require.NoError(t, createFile(filepath.Join(dir, "20240302.log"), []bot.Message{
+		started, msg("user1", time.Minute), msg("user2", 2*time.Minute), msg("user1", 3*time.Minute)}))
+	require.NoError(t, createFile(filepath.Join(dir, "20240309.log"), []bot.Message{msg("user3", 7*24*time.Hour)}))
+
+	e := NewExporter(nil, nil, ExporterParams{InputRoot: dir, OutputRoot: dir, Format: "text", BotUsername: "rtbot",
+		SuperUsers: SuperUserMock{}, BroadcastUsers: SuperUserMock{"rtbot": true}, BaseURL: "https://chat.radio-t.com/logs/"})
+	_, err := e.Export(900, ExportWindow{Day: 20240302})
+	require.NoError(t, err)
+	_, err = e.Export(901, ExportWindow{Day: 20240309})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "radio-t-900.meta.json"))
+	require.NoError(t, err)
+	meta := ShowMeta{}
+	require.NoError(t, json.Unmarshal(data, &meta))
+	assert.Equal(t, 900, meta.Num)
+	assert.True(t, ts.Equal(meta.Date), "broadcast start")
+	assert.Equal(t, "radio-t-900.txt", meta.File)
+	assert.Equal(t, 3, meta.Messages)
+	assert.Equal(t, []string{"user1", "user2"}, meta.Participants)
+
+	data, err = os.ReadFile(filepath.Join(dir, "index.html"))
+	require.NoError(t, err)
+	index := string(data)
+	assert.Contains(t, index, `<td><a href="radio-t-900.txt">#900</a></td>
+            <td>2024-03-02</td>
+            <td>3</td>
+            <td>user1, user2</td>`)
+	assert.Contains(t, index, `<td><a href="radio-t-901.txt">#901</a></td>
+            <td>2024-03-09</td>`, "first message time without broadcast start")
+	assert.Less(t, strings.Index(index, "#901"), strings.Index(index, "#900"), "latest show first")
+
+	data, err = os.ReadFile(filepath.Join(dir, "index.atom"))
+	require.NoError(t, err)
+	feed := atomFeed{}
+	require.NoError(t, xml.Unmarshal(data, &feed))
+	require.Len(t, feed.Entries, 2)
+	assert.Equal(t, "Лог чата Радио-Т #901", feed.Entries[0].Title)
+	assert.Equal(t, "urn:radio-t:chat-log:900", feed.Entries[1].ID)
+	assert.Equal(t, "https://chat.radio-t.com/logs/radio-t-900.txt", feed.Entries[1].Link.Href)
+	assert.Equal(t, "3 сообщений, самые активные: user1, user2", feed.Entries[1].Summary)
+	assert.Equal(t, []atomLink{{Href: "https://chat.radio-t.com/logs/index.atom", Rel: "self"},
+		{Href: "https://chat.radio-t.com/logs/index.html"}}, feed.Links)
+	assert.NotEmpty(t, feed.Updated)
+}
@@ -0,0 +1,163 @@
+package reporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// journalRecord is a line of the write-ahead journal. Records with Seq are accepted entries,
+// records with Done mark all entries up to Done (inclusive) as written to the daily log or dropped,
+// records with Deleted mark entries of the deleted message to be dropped.
+type journalRecord struct {
+	Seq     int64           `json:"seq,omitempty"`
+	MsgID   int             `json:"msg_id,omitempty"`
+	At      time.Time       `json:"at,omitempty"`
+	Msg     json.RawMessage `json:"msg,omitempty"`
+	Done    int64           `json:"done,omitempty"`
+	Deleted int             `json:"deleted,omitempty"`
+}
+
+// journal is an append-only file keeping accepted entries until they are written to the daily log.
+// Entries not marked as done are replayed on start, so nothing accepted is lost on crash.
+type journal struct {
+	path string
+
+	mu sync.Mutex
+	fh *os.File
+}
+
+// openJournal reads entries not marked as done and rewrites the journal with these entries only,
+// renumbered from 1 in the original order. Returns the journal and entries to replay.
+func openJournal(path string) (*journal, []msgEntry, error) {
+	pending, err := readJournal(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// compact journal to a temp file and rename it, so the pending entries are never lost
+	tmp := path + ".tmp"
+	fh, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600) // nolint
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create journal %s: %w", tmp, err)
+	}
+	j := &journal{path: path, fh: fh}
+	for i := range pending {
+		pending[i].Seq = int64(i + 1)
+		if err := j.add(pending[i]); err != nil {
+			_ = fh.Close()
+			return nil, nil, err
+		}
+	}
+	if err := fh.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to close journal %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, nil, fmt.Errorf("failed to rename journal %s: %w", tmp, err)
+	}
+
+	if j.fh, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600); err != nil { // nolint
+		return nil, nil, fmt.Errorf("failed to open journal %s: %w", path, err)
+	}
+	return j, pending, nil
+}
+
+func readJournal(path string) ([]msgEntry, error) {
+	fh, err := os.Open(path) // nolint
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open journal %s: %w", path, err)
+	}
+	defer fh.Close() // nolint
+
+	var done int64
+	entries := []msgEntry{}
+	deleted := map[int]bool{}
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		rec := journalRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// the last line could be partially written on crash
+			log.Printf("[WARN] skip broken journal record %q, %v", scanner.Text(), err)
+			continue
+		}
+		if rec.Done > done {
+			done = rec.Done
+		}
+		if rec.Deleted != 0 {
+			deleted[rec.Deleted] = true
+		}
+		if rec.Seq > 0 {
+			entries = append(entries, msgEntry{Seq: rec.Seq, MessageID: rec.MsgID, At: rec.At, Data: string(rec.Msg) + "\n"})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal %s: %w", path, err)
+	}
+
+	pending := entries[:0]
+	for _, e := range entries {
+		if e.Seq > done && !deleted[e.MessageID] {
+			pending = append(pending, e)
+		}
+	}
+	return pending, nil
+}
+
+// add appends accepted entry to the journal
+func (j *journal) add(entry msgEntry) error {
+	return j.write(journalRecord{Seq: entry.Seq, MsgID: entry.MessageID, At: entry.At, Msg: json.RawMessage(entry.Data)})
+}
+
+// done marks all entries up to seq as completed
+func (j *journal) done(seq int64) error {
+	return j.write(journalRecord{Done: seq})
+}
+
+// deleted marks entries of the message as dropped
+func (j *journal) deleted(msgID int) error {
+	return j.write(journalRecord{Deleted: msgID})
+}
+
+func (j *journal) write(rec journalRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal record: %w", err)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.fh.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal %s: %w", j.path, err)
+	}
+	return nil
+}
+
+// truncate removes all records, called when there are no pending entries
+func (j *journal) truncate() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.fh.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate journal %s: %w", j.path, err)
+	}
+	return nil
+}
+
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.fh.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal %s: %w", j.path, err)
+	}
+	if err := j.fh.Close(); err != nil {
+		return fmt.Errorf("failed to close journal %s: %w", j.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package reporter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reporter.journal")
+	at := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+
+	j, pending, err := openJournal(path)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, j.add(msgEntry{Seq: int64(i), MessageID: 100 + i, At: at, Data: `{"id":1}` + "\n"}))
+	}
+	require.NoError(t, j.done(2))
+	require.NoError(t, j.close())
+
+	// emulate partially written record on crash
+	fh, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = fh.WriteString(`{"seq":5,"msg_id":1`)
+	require.NoError(t, err)
+	require.NoError(t, fh.Close())
+
+	j, pending, err = openJournal(path)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, msgEntry{Seq: 1, MessageID: 103, At: at, Data: `{"id":1}` + "\n"}, pending[0])
+	assert.Equal(t, msgEntry{Seq: 2, MessageID: 104, At: at, Data: `{"id":1}` + "\n"}, pending[1])
+	require.NoError(t, j.close())
+
+	// reopen keeps the same pending entries after compaction
+	j, pending, err = openJournal(path)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+	require.NoError(t, j.truncate())
+	require.NoError(t, j.close())
+
+	j, pending, err = openJournal(path)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	require.NoError(t, j.close())
+}
@@ -0,0 +1,172 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-pkgz/syncs"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// linkTitleThreads is the number of concurrent requests for titles of links
+const linkTitleThreads = 4
+
+// TitleGetter gets title and content of the page, i.e. openai.UKeeperClient
+type TitleGetter interface {
+	Get(link string) (title, content string, err error)
+}
+
+// trackingParams are removed from links, so links shared from different places are the same link.
+// Params with utm_ prefix are removed as well.
+var trackingParams = map[string]bool{"fbclid": true, "gclid": true, "yclid": true, "igshid": true, "si": true}
+
+// nonWebScheme matches links like mailto:user@example.com or tel:+123, but not host:port
+var nonWebScheme = regexp.MustCompile(`^(?i)[a-z][a-z0-9+.-]*:[^/0-9]`)
+
+// Links returns links shared in the chat in the time range, grouped by domain, implements bot.LinkCollector
+func (e *Exporter) Links(from, to time.Time) ([]bot.LinkGroup, error) {
+	messages, _, err := e.readWindow(ExportWindow{From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+	return e.links(e.Redaction.Apply(messages)), nil
+}
+
+// links collects links of messages except bot ones, deduplicated by canonical url, with titles.
+// Groups with more links first, links in the order they were shared.
+func (e *Exporter) links(messages []bot.Message) []bot.LinkGroup {
+	byURL := map[string]*bot.SharedLink{}
+	var links []*bot.SharedLink
+	for _, msg := range messages {
+		if filter(msg) || isBot(msg.From, e.BotUsername) {
+			continue
+		}
+		name := participantName(msg.From)
+		for _, link := range messageLinks(msg) {
+			canonical, err := canonicalURL(link)
+			if err != nil {
+				continue
+			}
+			shared, found := byURL[canonical]
+			if !found {
+				shared = &bot.SharedLink{URL: canonical, Sent: msg.Sent}
+				byURL[canonical] = shared
+				links = append(links, shared)
+			}
+			if !contains(shared.SharedBy, name) {
+				shared.SharedBy = append(shared.SharedBy, name)
+			}
+		}
+	}
+	e.resolveTitles(links)
+
+	byDomain := map[string]int{}
+	res := []bot.LinkGroup{}
+	for _, link := range links {
+		domain := linkDomain(link.URL)
+		i, found := byDomain[domain]
+		if !found {
+			i = len(res)
+			byDomain[domain] = i
+			res = append(res, bot.LinkGroup{Domain: domain})
+		}
+		res[i].Links = append(res[i].Links, *link)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if len(res[i].Links) != len(res[j].Links) {
+			return len(res[i].Links) > len(res[j].Links)
+		}
+		return res[i].Domain < res[j].Domain
+	})
+	return res
+}
+
+// resolveTitles gets titles of links, failed links cached with empty title to not ask for them again
+func (e *Exporter) resolveTitles(links []*bot.SharedLink) {
+	if e.Titles == nil {
+		return
+	}
+	swg := syncs.NewSizedGroup(linkTitleThreads)
+	for _, link := range links {
+		e.titlesMu.Lock()
+		title, found := e.titles[link.URL]
+		e.titlesMu.Unlock()
+		if found {
+			link.Title = title
+			continue
+		}
+		swg.Go(func(context.Context) {
+			title, _, err := e.Titles.Get(link.URL)
+			if err != nil {
+				log.Printf("[DEBUG] no title for %s, %v", link.URL, err)
+			}
+			link.Title = strings.Join(strings.Fields(title), " ")
+			e.titlesMu.Lock()
+			e.titles[link.URL] = link.Title
+			e.titlesMu.Unlock()
+		})
+	}
+	swg.Wait()
+}
+
+// canonicalURL normalizes web link: https scheme added if missing, host lowercased without www and default port,
+// tracking params, fragment and trailing slash removed, query sorted
+func canonicalURL(link string) (string, error) {
+	link = strings.TrimSpace(link)
+	if nonWebScheme.MatchString(link) {
+		return "", fmt.Errorf("not a web link %q", link)
+	}
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("invalid link %q: %w", link, err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", fmt.Errorf("not a web link %q", link)
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host = net.JoinHostPort(host, port)
+	}
+	u.Host, u.User, u.Fragment, u.RawFragment = host, nil, "", ""
+
+	query := u.Query()
+	for k := range query {
+		if strings.HasPrefix(strings.ToLower(k), "utm_") || trackingParams[strings.ToLower(k)] {
+			query.Del(k)
+		}
+	}
+	u.RawQuery = query.Encode()
+	u.Path, u.RawPath = strings.TrimSuffix(u.Path, "/"), strings.TrimSuffix(u.RawPath, "/")
+	return u.String(), nil
+}
+
+// linkDomain returns host of the canonical link
+func linkDomain(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func contains(s []string, e string) bool {
+	for _, v := range s {
+		if v == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,363 @@
+package reporter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// logFileRe matches daily log files, i.e. 20240302.log or compressed 20240302.log.gz
+var logFileRe = regexp.MustCompile(`^\d{8}\.log(\.gz)?$`)
+
+// LogIndex is a full-text index over daily json logs. The initial index is made by Build, search is not available
+// until then. Each search indexes only lines appended since the previous one, files rewritten by removal of deleted
+// messages are reindexed completely. Compressed logs are immutable, indexed once, and replace the docs of the original
+// log. Docs of rewritten, removed and too old files are marked deleted and dropped from the index when they are more
+// than a half of all docs.
+type LogIndex struct {
+	logsPath   string
+	showAnchor ShowAnchor
+	maxDays    int              // index logs of the last days only, 0 for all
+	nowFn      func() time.Time // for testing
+	ready      atomic.Bool      // set when the initial index is built
+
+	mu      sync.Mutex
+	files   map[string]*indexedFile
+	docs    []indexDoc
+	deleted int              // number of docs marked deleted
+	terms   map[string][]int // term to docs positions
+}
+
+// ShowAnchor is a known show number and its broadcast start, used to find the broadcast time of other shows.
+// Shows broadcast weekly, so show N starts at Start + (N - Num) weeks.
+type ShowAnchor struct {
+	Num   int
+	Start time.Time
+}
+
+// ShowStart returns the broadcast start of the show num
+func (a ShowAnchor) ShowStart(num int) time.Time {
+	return a.Start.AddDate(0, 0, 7*(num-a.Num))
+}
+
+// show search covers chat from an hour before the broadcast start and the broadcast itself
+const (
+	showLeadTime = time.Hour
+	showDuration = 5 * time.Hour
+)
+
+type indexedFile struct {
+	info   os.FileInfo
+	offset int64
+	docs   []int
+}
+
+type indexDoc struct {
+	msg     bot.Message
+	deleted bool
+}
+
+// NewLogIndex makes index of logs in logsPath for the last maxDays, 0 for all logs.
+// Zero anchor disables search by show number.
+func NewLogIndex(logsPath string, anchor ShowAnchor, maxDays int) *LogIndex {
+	log.Printf("[INFO] log index for %s, show anchor %+v, max days %d", logsPath, anchor, maxDays)
+	return &LogIndex{logsPath: logsPath, showAnchor: anchor, maxDays: maxDays, nowFn: time.Now,
+		files: map[string]*indexedFile{}, terms: map[string][]int{}}
+}
+
+// Build makes the initial index of all logs. Scan of the large logs is slow, so it is called in background on start,
+// search returns bot.ErrHistoryNotReady until it is done.
+func (x *LogIndex) Build() error {
+	defer x.ready.Store(true)
+	st := time.Now()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := x.update(); err != nil {
+		return err
+	}
+	log.Printf("[INFO] log index built in %v, %d messages", time.Since(st), len(x.docs)-x.deleted)
+	return nil
+}
+
+// Search updates the index and returns messages containing all words of the request, best matches first.
+// Words match as prefixes, i.e. "postgres" matches "postgresql".
+func (x *LogIndex) Search(req bot.HistoryRequest) ([]bot.Message, error) {
+	if !x.ready.Load() {
+		return nil, bot.ErrHistoryNotReady
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if req.Show > 0 {
+		if x.showAnchor.Num == 0 {
+			return nil, errors.New("search by show number is not configured")
+		}
+		start := x.showAnchor.ShowStart(req.Show)
+		req.From, req.To = start.Add(-showLeadTime), start.Add(showDuration)
+	}
+
+	if err := x.update(); err != nil {
+		return nil, err
+	}
+
+	words := tokenize(req.Text)
+	if len(words) == 0 && strings.TrimSpace(req.Text) != "" {
+		return nil, nil // nothing searchable in the text
+	}
+	scores := map[int]int{}
+	for i, w := range words {
+		matched := map[int]int{}
+		for term, docs := range x.terms {
+			if !strings.HasPrefix(term, w) {
+				continue
+			}
+			for _, d := range docs {
+				matched[d]++
+			}
+		}
+		if i == 0 {
+			scores = matched
+			continue
+		}
+		for d, s := range scores { // all words should match
+			if m, ok := matched[d]; ok {
+				scores[d] = s + m
+				continue
+			}
+			delete(scores, d)
+		}
+	}
+	if len(words) == 0 { // filters only
+		for d := range x.docs {
+			scores[d] = 0
+		}
+	}
+
+	res := make([]int, 0, len(scores))
+	for d := range scores {
+		doc := x.docs[d]
+		if doc.deleted || !matchFilters(doc.msg, req) {
+			continue
+		}
+		res = append(res, d)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if scores[res[i]] != scores[res[j]] {
+			return scores[res[i]] > scores[res[j]]
+		}
+		return x.docs[res[i]].msg.Sent.After(x.docs[res[j]].msg.Sent)
+	})
+	if req.Limit > 0 && len(res) > req.Limit {
+		res = res[:req.Limit]
+	}
+
+	msgs := make([]bot.Message, 0, len(res))
+	for _, d := range res {
+		msgs = append(msgs, x.docs[d].msg)
+	}
+	return msgs, nil
+}
+
+func matchFilters(msg bot.Message, req bot.HistoryRequest) bool {
+	if req.User != "" && !strings.EqualFold(strings.TrimPrefix(req.User, "@"), msg.From.Username) {
+		return false
+	}
+	if !req.From.IsZero() && msg.Sent.Before(req.From) {
+		return false
+	}
+	if !req.To.IsZero() && !msg.Sent.Before(req.To) {
+		return false
+	}
+	return true
+}
+
+// update indexes new lines of log files within maxDays, caller should hold the lock
+func (x *LogIndex) update() error {
+	entries, err := os.ReadDir(x.logsPath)
+	if err != nil {
+		return fmt.Errorf("failed to read logs dir %s: %w", x.logsPath, err)
+	}
+	now := x.nowFn()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if e.IsDir() || !logFileRe.MatchString(e.Name()) {
+			continue
+		}
+		if day, err := time.ParseInLocation("20060102", e.Name()[:8], now.Location()); err == nil &&
+			x.maxDays > 0 && int(today.Sub(day).Hours()/24) >= x.maxDays {
+			continue // too old, dropped from the index as removed
+		}
+		path := filepath.Join(x.logsPath, e.Name())
+		seen[path] = true
+		if err := x.updateFile(path); err != nil {
+			log.Printf("[WARN] can't index %s, %v", e.Name(), err)
+		}
+	}
+
+	// drop docs of removed files, i.e. compressed, expired or too old logs
+	for path, f := range x.files {
+		if seen[path] {
+			continue
+		}
+		x.drop(f)
+		delete(x.files, path)
+	}
+	if x.deleted > len(x.docs)/2 {
+		x.compact()
+	}
+	return nil
+}
+
+// drop marks docs of the file deleted
+func (x *LogIndex) drop(f *indexedFile) {
+	for _, d := range f.docs {
+		x.docs[d].deleted = true
+	}
+	x.deleted += len(f.docs)
+}
+
+// compact removes deleted docs from the index, renumbering the rest
+func (x *LogIndex) compact() {
+	pos := make([]int, len(x.docs)) // old to new doc position, -1 for deleted
+	docs := make([]indexDoc, 0, len(x.docs)-x.deleted)
+	for i, doc := range x.docs {
+		pos[i] = -1
+		if !doc.deleted {
+			pos[i] = len(docs)
+			docs = append(docs, doc)
+		}
+	}
+	for _, f := range x.files {
+		for i, d := range f.docs {
+			f.docs[i] = pos[d]
+		}
+	}
+	for term, ds := range x.terms {
+		live := ds[:0]
+		for _, d := range ds {
+			if pos[d] >= 0 {
+				live = append(live, pos[d])
+			}
+		}
+		if len(live) == 0 {
+			delete(x.terms, term)
+			continue
+		}
+		x.terms[term] = live
+	}
+	log.Printf("[DEBUG] log index compacted, %d docs removed, %d kept", x.deleted, len(docs))
+	x.docs, x.deleted = docs, 0
+}
+
+func (x *LogIndex) updateFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat: %w", err)
+	}
+
+	f, ok := x.files[path]
+	if ok && (!os.SameFile(f.info, info) || info.Size() < f.offset) {
+		// file rewritten, drop its docs and index again
+		x.drop(f)
+		ok = false
+	}
+	if !ok {
+		f = &indexedFile{}
+		x.files[path] = f
+	}
+	f.info = info
+	if info.Size() == f.offset {
+		return nil
+	}
+
+	fh, err := os.Open(path) // nolint
+	if err != nil {
+		return fmt.Errorf("failed to open: %w", err)
+	}
+	defer fh.Close() // nolint
+
+	var rd *bufio.Reader
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(fh)
+		if err != nil {
+			return fmt.Errorf("failed to read gzip: %w", err)
+		}
+		defer gz.Close() // nolint
+		rd = bufio.NewReader(gz)
+		f.offset = info.Size() // compressed log indexed at once
+	} else {
+		if _, err = fh.Seek(f.offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek: %w", err)
+		}
+		rd = bufio.NewReader(fh)
+	}
+
+	count := 0
+	for {
+		line, err := rd.ReadBytes('\n')
+		if err != nil {
+			break // EOF or partially written line, will be indexed on the next update
+		}
+		if !strings.HasSuffix(path, ".gz") {
+			f.offset += int64(len(line))
+		}
+		msg := bot.Message{}
+		if err := json.Unmarshal(bytes.TrimSpace(line), &msg); err != nil {
+			continue
+		}
+		x.add(f, msg)
+		count++
+	}
+	log.Printf("[DEBUG] indexed %d messages from %s", count, path)
+	return nil
+}
+
+func (x *LogIndex) add(f *indexedFile, msg bot.Message) {
+	d := len(x.docs)
+	x.docs = append(x.docs, indexDoc{msg: msg})
+	f.docs = append(f.docs, d)
+
+	text := msg.Text
+	if msg.Image != nil {
+		text += " " + msg.Image.Caption
+	}
+	if msg.Entities != nil {
+		for _, e := range *msg.Entities {
+			text += " " + e.URL
+		}
+	}
+	for _, term := range tokenize(text) {
+		x.terms[term] = append(x.terms[term], d)
+	}
+}
+
+// tokenize splits text to lowercase words, one-letter words skipped
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	res := words[:0]
+	for _, w := range words {
+		if len([]rune(w)) > 1 {
+			res = append(res, w)
+		}
+	}
+	return res
+}
@@ -0,0 +1,193 @@
+package reporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+func TestLogIndex_Search(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	writeLog := func(name string, msgs ...bot.Message) {
+		fh, err := os.OpenFile(filepath.Join(dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		defer fh.Close()
+		for _, m := range msgs {
+			data, err := json.Marshal(m)
+			require.NoError(t, err)
+			_, err = fh.Write(append(data, '\n'))
+			require.NoError(t, err)
+		}
+	}
+	ids := func(msgs []bot.Message) (res []int) {
+		for _, m := range msgs {
+			res = append(res, m.ID)
+		}
+		return res
+	}
+
+	writeLog("20240302.log",
+		bot.Message{ID: 1, Sent: ts, Text: "статья про PostgreSQL https://example.com/pg", From: bot.User{Username: "user1"}},
+		bot.Message{ID: 2, Sent: ts.Add(time.Minute), Text: "postgres postgres лучше всех", From: bot.User{Username: "user2"}},
+		bot.Message{ID: 3, Sent: ts.Add(2 * time.Minute), Text: "а mysql?", From: bot.User{Username: "user1"}},
+	)
+	writeLog("20240309.log",
+		bot.Message{ID: 10, Sent: ts.AddDate(0, 0, 7), Text: "снова про postgres", From: bot.User{Username: "user3"}},
+		bot.Message{ID: 11, Sent: ts.AddDate(0, 0, 7), Text: "ссылка", From: bot.User{Username: "user1"},
+			Entities: &[]bot.Entity{{Type: "text_link", URL: "https://example.com/sqlite"}}},
+	)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "reporter.journal"), []byte(`{"seq":1}`), 0o600))
+
+	idx := NewLogIndex(dir, ShowAnchor{Num: 900, Start: ts}, 0)
+	_, err := idx.Search(bot.HistoryRequest{Text: "postgres"})
+	require.ErrorIs(t, err, bot.ErrHistoryNotReady)
+	require.NoError(t, idx.Build())
+	tbl := []struct {
+		name string
+		req  bot.HistoryRequest
+		ids  []int
+	}{
+		{"prefix, best first", bot.HistoryRequest{Text: "Postgres"}, []int{2, 10, 1}},
+		{"all words", bot.HistoryRequest{Text: "про postgres"}, []int{10, 1}},
+		{"limit", bot.HistoryRequest{Text: "postgres", Limit: 1}, []int{2}},
+		{"user", bot.HistoryRequest{Text: "postgres", User: "@USER1"}, []int{1}},
+		{"user only", bot.HistoryRequest{User: "user1"}, []int{11, 3, 1}},
+		{"range", bot.HistoryRequest{Text: "postgres", From: ts.AddDate(0, 0, 1)}, []int{10}},
+		{"show", bot.HistoryRequest{Text: "postgres", Show: 901}, []int{10}},
+		{"entity url", bot.HistoryRequest{Text: "sqlite"}, []int{11}},
+		{"url in text", bot.HistoryRequest{Text: "example"}, []int{11, 1}},
+		{"one letter only", bot.HistoryRequest{Text: "а"}, nil},
+		{"nothing", bot.HistoryRequest{Text: "oracle"}, nil},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := idx.Search(tt.req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.ids, ids(res))
+		})
+	}
+
+	// appended lines indexed incrementally, partial line waits for the next search
+	writeLog("20240309.log", bot.Message{ID: 12, Sent: ts.AddDate(0, 0, 7).Add(time.Minute), Text: "postgres forever"})
+	fh, err := os.OpenFile(filepath.Join(dir, "20240309.log"), os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = fh.WriteString(`{"ID":13,"Text":"postgres partial`)
+	require.NoError(t, err)
+	require.NoError(t, fh.Close())
+	res, err := idx.Search(bot.HistoryRequest{Text: "forever"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{12}, ids(res))
+	assert.Len(t, idx.docs, 6, "only the new complete line indexed")
+
+	// rewritten file reindexed
+	n, err := removeFromLog(filepath.Join(dir, "20240302.log"), map[int]bool{2: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	res, err = idx.Search(bot.HistoryRequest{Text: "postgres", To: ts.AddDate(0, 0, 1)})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, ids(res))
+
+	// compressed log replaces the original one
+	require.NoError(t, compressLog(filepath.Join(dir, "20240302.log")))
+	res, err = idx.Search(bot.HistoryRequest{Text: "postgres"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{12, 10, 1}, ids(res))
+	idx2 := NewLogIndex(dir, ShowAnchor{}, 0)
+	require.NoError(t, idx2.Build())
+	res, err = idx2.Search(bot.HistoryRequest{Text: "mysql"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3}, ids(res), "compressed log indexed from scratch")
+
+	_, err = idx2.Search(bot.HistoryRequest{Text: "postgres", Show: 901})
+	assert.Error(t, err, "show search requires anchor")
+}
+
+func TestLogIndex_Compact(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	writeLog := func(name string, msgs ...bot.Message) {
+		var data []byte
+		for _, m := range msgs {
+			line, err := json.Marshal(m)
+			require.NoError(t, err)
+			data = append(append(data, line...), '\n')
+		}
+		// replaced as removeFromLog does
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".tmp"), data, 0o600))
+		require.NoError(t, os.Rename(filepath.Join(dir, name+".tmp"), filepath.Join(dir, name)))
+	}
+	writeLog("20240302.log", bot.Message{ID: 1, Sent: ts, Text: "старый лог про go"},
+		bot.Message{ID: 2, Sent: ts, Text: "старый лог про rust"})
+	writeLog("20240309.log", bot.Message{ID: 10, Sent: ts.AddDate(0, 0, 7), Text: "новый лог про go"})
+
+	idx := NewLogIndex(dir, ShowAnchor{}, 0)
+	require.NoError(t, idx.Build())
+	res, err := idx.Search(bot.HistoryRequest{Text: "go"})
+	require.NoError(t, err)
+	assert.Len(t, res, 2)
+	assert.Len(t, idx.docs, 3)
+
+	// rewritten file, deleted docs are not more than a half
+	writeLog("20240309.log", bot.Message{ID: 11, Sent: ts.AddDate(0, 0, 7), Text: "новый лог про go"})
+	_, err = idx.Search(bot.HistoryRequest{Text: "go"})
+	require.NoError(t, err)
+	assert.Len(t, idx.docs, 4)
+	assert.Equal(t, 1, idx.deleted)
+
+	// removed file makes most of docs deleted
+	require.NoError(t, os.Remove(filepath.Join(dir, "20240302.log")))
+	res, err = idx.Search(bot.HistoryRequest{Text: "go"})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, 11, res[0].ID)
+	assert.Len(t, idx.docs, 1, "deleted docs removed")
+	assert.Equal(t, 0, idx.deleted)
+	assert.Equal(t, []int{0}, idx.terms["go"])
+	assert.NotContains(t, idx.terms, "rust")
+
+	// indexed again after compaction
+	writeLog("20240309.log", bot.Message{ID: 11, Sent: ts.AddDate(0, 0, 7), Text: "новый лог про go"},
+		bot.Message{ID: 12, Sent: ts.AddDate(0, 0, 7).Add(time.Minute), Text: "и еще про go"})
+	res, err = idx.Search(bot.HistoryRequest{Text: "go"})
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, 12, res[0].ID)
+}
+
+func TestLogIndex_MaxDays(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2024, 3, 9, 20, 0, 0, 0, time.UTC)
+	for name, msg := range map[string]bot.Message{
+		"20240302.log": {ID: 1, Sent: ts.AddDate(0, 0, -7), Text: "старый postgres"},
+		"20240308.log": {ID: 2, Sent: ts.AddDate(0, 0, -1), Text: "вчерашний postgres"},
+		"20240309.log": {ID: 3, Sent: ts, Text: "сегодняшний postgres"},
+	} {
+		line, err := json.Marshal(msg)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), append(line, '\n'), 0o600))
+	}
+
+	idx := NewLogIndex(dir, ShowAnchor{}, 7)
+	idx.nowFn = func() time.Time { return ts }
+	require.NoError(t, idx.Build())
+	res, err := idx.Search(bot.HistoryRequest{Text: "postgres"})
+	require.NoError(t, err)
+	require.Len(t, res, 2, "log older than max days not indexed")
+	assert.Equal(t, 3, res[0].ID)
+	assert.Equal(t, 2, res[1].ID)
+	assert.Len(t, idx.docs, 2)
+
+	idx.nowFn = func() time.Time { return ts.AddDate(0, 0, 6) }
+	res, err = idx.Search(bot.HistoryRequest{Text: "postgres"})
+	require.NoError(t, err)
+	require.Len(t, res, 1, "log dropped from the index when got too old")
+	assert.Equal(t, 3, res[0].ID)
+}
@@ -0,0 +1,219 @@
+package reporter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register gif decoder for image dimensions
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// Media is a stored telegram file, named by hash of the content, so the same file sent twice is stored once
+type Media struct {
+	URL         string `json:"url"`
+	MIME        string `json:"mime"`
+	Width       int    `json:"width"` // of the image, from telegram if the image can't be decoded
+	Height      int    `json:"height"`
+	ThumbURL    string `json:"thumb_url"` // thumbnail for html log, the file itself if it is small or can't be resized
+	ThumbWidth  int    `json:"thumb_width"`
+	ThumbHeight int    `json:"thumb_height"`
+}
+
+// mediaCacheFile keeps media stored by exports in the output root, by telegram file id.
+// Offline export links images to it instead of downloading them.
+const mediaCacheFile = "media.json"
+
+// mediaExt maps detected mime type of the file to its extension
+var mediaExt = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+	"video/mp4":  "mp4",
+	"video/webm": "webm",
+}
+
+// storeMedia downloads telegram image and stores it with the thumbnail, files already in the storage are not written
+func (e *Exporter) storeMedia(img *bot.Image) (*Media, error) {
+	if img == nil || img.FileID == "" {
+		return nil, nil
+	}
+	if m, found := e.media[img.FileID]; found {
+		return m, nil
+	}
+	if e.Offline {
+		return nil, fmt.Errorf("file %s is not in media cache", img.FileID)
+	}
+
+	log.Printf("[DEBUG] downloading file %s", img.FileID)
+	body, err := e.fileRecipient.GetFile(img.FileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file body for %s: %w", img.FileID, err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file body for %s: %w", img.FileID, err)
+	}
+	log.Printf("[DEBUG] downloaded file %s", img.FileID)
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	m := &Media{MIME: http.DetectContentType(data), Width: img.Width, Height: img.Height}
+	ext, ok := mediaExt[m.MIME]
+	if !ok {
+		ext = "bin"
+	}
+	if m.URL, err = e.storeFile(hash+"."+ext, data); err != nil {
+		return nil, err
+	}
+	if cfg, _, decErr := image.DecodeConfig(bytes.NewReader(data)); decErr == nil {
+		m.Width, m.Height = cfg.Width, cfg.Height
+	}
+
+	m.ThumbURL, m.ThumbWidth, m.ThumbHeight = m.URL, m.Width, m.Height
+	if w, h, resize := thumbSize(m.Width, m.Height, e.ThumbSize); resize && (ext == "jpg" || ext == "png") {
+		// animated gifs and formats without decoder are shown as is
+		thumbURL, thumbErr := e.storeThumb(fmt.Sprintf("%s-%d.%s", hash, e.ThumbSize, ext), data, w, h)
+		if thumbErr != nil {
+			log.Printf("[WARN] can't make thumbnail of %s, %v", img.FileID, thumbErr)
+		} else {
+			m.ThumbURL, m.ThumbWidth, m.ThumbHeight = thumbURL, w, h
+		}
+	}
+
+	e.media[img.FileID] = m
+	return m, nil
+}
+
+// readMediaCache reads media stored by previous exports, empty if there is no cache yet
+func (e *Exporter) readMediaCache() (map[string]*Media, error) {
+	res := map[string]*Media{}
+	file := filepath.Join(e.OutputRoot, mediaCacheFile)
+	data, err := os.ReadFile(file) // nolint
+	if errors.Is(err, os.ErrNotExist) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media cache %s: %w", file, err)
+	}
+	if err = json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("failed to parse media cache %s: %w", file, err)
+	}
+	return res, nil
+}
+
+// updateMediaCache adds media stored by the export to the cache
+func (e *Exporter) updateMediaCache() error {
+	if len(e.media) == 0 {
+		return nil
+	}
+	cache, err := e.readMediaCache()
+	if err != nil {
+		return err
+	}
+	for id, m := range e.media {
+		cache[id] = m
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal media cache: %w", err)
+	}
+	file := filepath.Join(e.OutputRoot, mediaCacheFile)
+	if err = os.WriteFile(file, data, 0o644); err != nil { // nolint
+		return fmt.Errorf("failed to write media cache %s: %w", file, err)
+	}
+	return nil
+}
+
+// storeFile creates file in the storage unless it is there already, returns public link
+func (e *Exporter) storeFile(name string, data []byte) (string, error) {
+	exists, err := e.storage.FileExists(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if file %s exists: %w", name, err)
+	}
+	if exists {
+		return e.storage.BuildLink(name), nil
+	}
+	link, err := e.storage.CreateFile(name, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file %s: %w", name, err)
+	}
+	return link, nil
+}
+
+// storeThumb makes thumbnail of jpeg or png image in the same format
+func (e *Exporter) storeThumb(name string, data []byte, width, height int) (string, error) {
+	exists, err := e.storage.FileExists(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if file %s exists: %w", name, err)
+	}
+	if exists {
+		return e.storage.BuildLink(name), nil
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, thumbnail(src, width, height), &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, thumbnail(src, width, height))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	link, err := e.storage.CreateFile(name, buf.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("failed to create file %s: %w", name, err)
+	}
+	return link, nil
+}
+
+// thumbSize fits image into size x size box keeping aspect ratio, resize is false if the image fits already
+func thumbSize(width, height, size int) (w, h int, resize bool) {
+	if size <= 0 || width <= 0 || height <= 0 || (width <= size && height <= size) {
+		return width, height, false
+	}
+	if width >= height {
+		return size, max(1, height*size/width), true
+	}
+	return max(1, width*size/height), size, true
+}
+
+// thumbnail scales image down, each pixel of the result is the average of source pixels it covers
+func thumbnail(src image.Image, width, height int) image.Image {
+	b := src.Bounds()
+	dst := image.NewRGBA64(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/height, b.Min.Y+(y+1)*b.Dy()/height
+		for x := 0; x < width; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/width, b.Min.X+(x+1)*b.Dx()/width
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,171 @@
+package reporter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// memStorage keeps files in memory, counts writes
+type memStorage struct {
+	files  map[string][]byte
+	writes int
+}
+
+func (m *memStorage) FileExists(fileName string) (bool, error) {
+	_, ok := m.files[fileName]
+	return ok, nil
+}
+
+func (m *memStorage) CreateFile(fileName string, body []byte) (string, error) {
+	m.files[fileName] = body
+	m.writes++
+	return m.BuildLink(fileName), nil
+}
+
+func (m *memStorage) BuildLink(fileName string) string { return "900/" + fileName }
+
+type memFiles map[string][]byte
+
+func (m memFiles) GetFile(fileID string) (io.ReadCloser, error) {
+	data, ok := m[fileID]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestExporter_storeMedia(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	for x := 0; x < 100; x++ {
+		for y := 0; y < 50; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var bigPNG, smallJPEG bytes.Buffer
+	require.NoError(t, png.Encode(&bigPNG, img))
+	require.NoError(t, jpeg.Encode(&smallJPEG, img.SubImage(image.Rect(0, 0, 10, 10)), nil))
+
+	files := memFiles{"big": bigPNG.Bytes(), "big-again": bigPNG.Bytes(), "small": smallJPEG.Bytes(), "doc": []byte("data")}
+	store := &memStorage{files: map[string][]byte{}}
+	e := NewExporter(files, store, ExporterParams{ThumbSize: 20})
+
+	m, err := e.storeMedia(&bot.Image{FileID: "big", Width: 1, Height: 1})
+	require.NoError(t, err)
+	hash := sha256Hex(bigPNG.Bytes())
+	assert.Equal(t, &Media{URL: "900/" + hash + ".png", MIME: "image/png", Width: 100, Height: 50,
+		ThumbURL: "900/" + hash + "-20.png", ThumbWidth: 20, ThumbHeight: 10}, m)
+	thumb, format, err := image.Decode(bytes.NewReader(store.files[hash+"-20.png"]))
+	require.NoError(t, err)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, image.Rect(0, 0, 20, 10), thumb.Bounds())
+	assert.Equal(t, 2, store.writes)
+
+	again, err := e.storeMedia(&bot.Image{FileID: "big-again"})
+	require.NoError(t, err)
+	assert.Equal(t, m, again, "same content stored once")
+	assert.Equal(t, 2, store.writes)
+
+	small, err := e.storeMedia(&bot.Image{FileID: "small"})
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", small.MIME)
+	assert.Equal(t, small.URL, small.ThumbURL, "small image is its own thumbnail")
+	assert.Equal(t, [2]int{10, 10}, [2]int{small.ThumbWidth, small.ThumbHeight})
+
+	doc, err := e.storeMedia(&bot.Image{FileID: "doc", Width: 30, Height: 40})
+	require.NoError(t, err)
+	assert.Equal(t, &Media{URL: "900/" + sha256Hex([]byte("data")) + ".bin", MIME: "text/plain; charset=utf-8",
+		Width: 30, Height: 40, ThumbURL: "900/" + sha256Hex([]byte("data")) + ".bin", ThumbWidth: 30, ThumbHeight: 40}, doc,
+		"telegram dimensions kept for files without decoder")
+	assert.Equal(t, 4, store.writes)
+
+	// next export finds files in the storage
+	e = NewExporter(files, store, ExporterParams{ThumbSize: 20})
+	m2, err := e.storeMedia(&bot.Image{FileID: "big"})
+	require.NoError(t, err)
+	assert.Equal(t, m, m2)
+	assert.Equal(t, 4, store.writes)
+}
+
+func TestExporter_ExportOffline(t *testing.T) {
+	dir := t.TempDir()
+	msgs := []bot.Message{
+		{Sent: time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC), From: bot.User{Username: "user"}, Image: &bot.Image{FileID: "img"}},
+		{Sent: time.Date(2024, 3, 2, 20, 1, 0, 0, time.UTC), From: bot.User{Username: "user"}, Image: &bot.Image{FileID: "new"}},
+	}
+	require.NoError(t, createFile(filepath.Join(dir, "20240302.log"), msgs))
+	params := ExporterParams{InputRoot: dir, OutputRoot: dir, Format: "json", SuperUsers: SuperUserMock{},
+		BroadcastUsers: SuperUserMock{}}
+
+	store := &memStorage{files: map[string][]byte{}}
+	_, err := NewExporter(memFiles{"img": []byte("data")}, store, params).Export(900, ExportWindow{Day: 20240302})
+	require.NoError(t, err)
+	assert.Equal(t, 1, store.writes)
+
+	params.Offline = true
+	to, err := NewExporter(nil, store, params).Export(900, ExportWindow{Day: 20240302})
+	require.NoError(t, err)
+	assert.Equal(t, 1, store.writes)
+	data, err := os.ReadFile(to) // nolint
+	require.NoError(t, err)
+	show := struct {
+		Messages []struct {
+			Image struct {
+				URL string `json:"url"`
+			} `json:"image"`
+		} `json:"messages"`
+	}{}
+	require.NoError(t, json.Unmarshal(data, &show))
+	require.Len(t, show.Messages, 2)
+	assert.Equal(t, "900/"+sha256Hex([]byte("data"))+".bin", show.Messages[0].Image.URL, "linked to cached media")
+	assert.Empty(t, show.Messages[1].Image.URL, "not in cache")
+}
+
+func Test_thumbSize(t *testing.T) {
+	tbl := []struct {
+		width, height, size int
+		w, h                int
+		resize              bool
+	}{
+		{100, 50, 20, 20, 10, true},
+		{50, 100, 20, 10, 20, true},
+		{1000, 1, 20, 20, 1, true},
+		{20, 10, 20, 20, 10, false},
+		{100, 50, 0, 100, 50, false},
+		{0, 0, 20, 0, 0, false},
+	}
+	for _, tt := range tbl {
+		w, h, resize := thumbSize(tt.width, tt.height, tt.size)
+		assert.Equal(t, [3]any{tt.w, tt.h, tt.resize}, [3]any{w, h, resize}, "%dx%d in %d", tt.width, tt.height, tt.size)
+	}
+}
+
+func Test_thumbnail(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	img.SetGray(0, 0, color.Gray{Y: 255})
+	img.SetGray(1, 1, color.Gray{Y: 255})
+	res := thumbnail(img, 1, 1)
+	r, g, b, a := res.At(0, 0).RGBA()
+	assert.Equal(t, [4]uint32{0x7fff, 0x7fff, 0x7fff, 0xffff}, [4]uint32{r, g, b, a})
+}
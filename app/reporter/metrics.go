@@ -0,0 +1,65 @@
+package reporter
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// Metrics sink counts messages by type and deletions, served in prometheus text format
+type Metrics struct {
+	mu       sync.Mutex
+	messages map[string]int64 // by message type
+	deleted  int64
+}
+
+// NewMetrics makes metrics sink
+func NewMetrics() *Metrics {
+	return &Metrics{messages: map[string]int64{}}
+}
+
+// Save counts message
+func (m *Metrics) Save(msg *bot.Message) {
+	kind := "text"
+	switch {
+	case msg.Image != nil:
+		kind = "image"
+	case msg.Text == "":
+		kind = "other"
+	}
+	m.mu.Lock()
+	m.messages[kind]++
+	m.mu.Unlock()
+}
+
+// Delete counts deleted message
+func (m *Metrics) Delete(int) {
+	m.mu.Lock()
+	m.deleted++
+	m.mu.Unlock()
+}
+
+// ServeHTTP writes counters in prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	kinds := make([]string, 0, len(m.messages))
+	for k := range m.messages {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+
+	_, _ = fmt.Fprintln(w, "# HELP superbot_messages_total Chat messages seen by the bot.")
+	_, _ = fmt.Fprintln(w, "# TYPE superbot_messages_total counter")
+	for _, k := range kinds {
+		_, _ = fmt.Fprintf(w, "superbot_messages_total{type=%q} %d\n", k, m.messages[k])
+	}
+	_, _ = fmt.Fprintln(w, "# HELP superbot_deleted_messages_total Chat messages deleted by the bot.")
+	_, _ = fmt.Fprintln(w, "# TYPE superbot_deleted_messages_total counter")
+	_, _ = fmt.Fprintf(w, "superbot_deleted_messages_total %d\n", m.deleted)
+}
@@ -0,0 +1,119 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package reporter
+
+import (
+	"github.com/radio-t/super-bot/app/bot"
+	"sync"
+)
+
+// Ensure, that SinkMock does implement Sink.
+// If this is not the case, regenerate this file with moq.
+var _ Sink = &SinkMock{}
+
+// SinkMock is a mock implementation of Sink.
+//
+//	func TestSomethingThatUsesSink(t *testing.T) {
+//
+//		// make and configure a mocked Sink
+//		mockedSink := &SinkMock{
+//			DeleteFunc: func(msgID int)  {
+//				panic("mock out the Delete method")
+//			},
+//			SaveFunc: func(msg *bot.Message)  {
+//				panic("mock out the Save method")
+//			},
+//		}
+//
+//		// use mockedSink in code that requires Sink
+//		// and then make assertions.
+//
+//	}
+type SinkMock struct {
+	// DeleteFunc mocks the Delete method.
+	DeleteFunc func(msgID int)
+
+	// SaveFunc mocks the Save method.
+	SaveFunc func(msg *bot.Message)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Delete holds details about calls to the Delete method.
+		Delete []struct {
+			// MsgID is the msgID argument value.
+			MsgID int
+		}
+		// Save holds details about calls to the Save method.
+		Save []struct {
+			// Msg is the msg argument value.
+			Msg *bot.Message
+		}
+	}
+	lockDelete sync.RWMutex
+	lockSave   sync.RWMutex
+}
+
+// Delete calls DeleteFunc.
+func (mock *SinkMock) Delete(msgID int) {
+	if mock.DeleteFunc == nil {
+		panic("SinkMock.DeleteFunc: method is nil but Sink.Delete was just called")
+	}
+	callInfo := struct {
+		MsgID int
+	}{
+		MsgID: msgID,
+	}
+	mock.lockDelete.Lock()
+	mock.calls.Delete = append(mock.calls.Delete, callInfo)
+	mock.lockDelete.Unlock()
+	mock.DeleteFunc(msgID)
+}
+
+// DeleteCalls gets all the calls that were made to Delete.
+// Check the length with:
+//
+//	len(mockedSink.DeleteCalls())
+func (mock *SinkMock) DeleteCalls() []struct {
+	MsgID int
+} {
+	var calls []struct {
+		MsgID int
+	}
+	mock.lockDelete.RLock()
+	calls = mock.calls.Delete
+	mock.lockDelete.RUnlock()
+	return calls
+}
+
+// Save calls SaveFunc.
+func (mock *SinkMock) Save(msg *bot.Message) {
+	if mock.SaveFunc == nil {
+		panic("SinkMock.SaveFunc: method is nil but Sink.Save was just called")
+	}
+	callInfo := struct {
+		Msg *bot.Message
+	}{
+		Msg: msg,
+	}
+	mock.lockSave.Lock()
+	mock.calls.Save = append(mock.calls.Save, callInfo)
+	mock.lockSave.Unlock()
+	mock.SaveFunc(msg)
+}
+
+// SaveCalls gets all the calls that were made to Save.
+// Check the length with:
+//
+//	len(mockedSink.SaveCalls())
+func (mock *SinkMock) SaveCalls() []struct {
+	Msg *bot.Message
+} {
+	var calls []struct {
+		Msg *bot.Message
+	}
+	mock.lockSave.RLock()
+	calls = mock.calls.Save
+	mock.lockSave.RUnlock()
+	return calls
+}
@@ -0,0 +1,189 @@
+package reporter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// redactMask replaces every utf-16 unit of the masked text, so entity offsets stay valid
+const redactMask = '•'
+
+// Redaction hides personal data in exported messages: anonymizes users, masks texts matching patterns
+// and removes messages by id. Applied to messages before stats and rendering.
+type Redaction struct {
+	users    map[string]bool // lowercase usernames and ids of anonymized users
+	patterns []*regexp.Regexp
+	exclude  map[int]bool
+}
+
+// keepOnMask are entity types kept if the entity text is masked, others are links which could reveal the data
+var keepOnMask = map[string]bool{"bold": true, "italic": true, "underline": true, "strikethrough": true,
+	"spoiler": true, "code": true, "pre": true, "blockquote": true, "expandable_blockquote": true}
+
+// NewRedaction makes redaction for users given by username or id, regexp patterns and message ids to exclude.
+// Mentions of anonymized users are masked as well.
+func NewRedaction(users, patterns []string, exclude []int) (*Redaction, error) {
+	res := &Redaction{users: map[string]bool{}, exclude: map[int]bool{}}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		res.patterns = append(res.patterns, re)
+	}
+	for _, u := range users {
+		u = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(u), "@"))
+		if u == "" {
+			continue
+		}
+		res.users[u] = true
+		if _, err := strconv.ParseInt(u, 10, 64); err != nil {
+			res.patterns = append(res.patterns, regexp.MustCompile(`(?i)@`+regexp.QuoteMeta(u)+`\b`))
+		}
+	}
+	for _, id := range exclude {
+		res.exclude[id] = true
+	}
+	return res, nil
+}
+
+// Apply returns redacted copies of messages, nil redaction keeps messages as is
+func (r *Redaction) Apply(messages []bot.Message) []bot.Message {
+	if r == nil {
+		return messages
+	}
+	pseudonyms := map[string]bot.User{}
+	anonymize := func(u bot.User) bot.User {
+		if !r.isAnonymized(u) {
+			return u
+		}
+		key := strconv.FormatInt(u.ID, 10) + ":" + strings.ToLower(u.Username)
+		if p, ok := pseudonyms[key]; ok {
+			return p
+		}
+		p := bot.User{DisplayName: fmt.Sprintf("аноним %d", len(pseudonyms)+1)}
+		pseudonyms[key] = p
+		return p
+	}
+
+	res := make([]bot.Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.ID != 0 && r.exclude[msg.ID] {
+			continue
+		}
+		msg.From = anonymize(msg.From)
+		msg.Text, msg.Entities = r.mask(msg.Text, msg.Entities)
+		if msg.Image != nil {
+			img := *msg.Image
+			img.Caption, img.Entities = r.mask(img.Caption, img.Entities)
+			msg.Image = &img
+		}
+		if msg.ReplyTo.ID != 0 && r.exclude[msg.ReplyTo.ID] {
+			msg.ReplyTo.Text = ""
+		}
+		msg.ReplyTo.From = anonymize(msg.ReplyTo.From)
+		msg.ReplyTo.Text, _ = r.mask(msg.ReplyTo.Text, nil)
+		res = append(res, msg)
+	}
+	return res
+}
+
+// hiddenLink checks if url of text link matches redaction patterns, i.e. mailto:user@example.com
+func (r *Redaction) hiddenLink(e bot.Entity) bool {
+	if e.Type != "text_link" {
+		return false
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(e.URL) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redaction) isAnonymized(u bot.User) bool {
+	return (u.Username != "" && r.users[strings.ToLower(u.Username)]) || (u.ID != 0 && r.users[strconv.FormatInt(u.ID, 10)])
+}
+
+// mask replaces text matching patterns and text mentions of anonymized users, drops links over the masked text
+// and links to urls matching patterns. Entities are copied if changed.
+func (r *Redaction) mask(text string, entities *[]bot.Entity) (string, *[]bot.Entity) {
+	if text == "" {
+		return text, entities
+	}
+	runes := []rune(text)
+	unitOffsets := make([]int, len(runes)+1) // utf-16 offset of each rune
+	byteToRune := make(map[int]int, len(runes)+1)
+	i := 0
+	for pos, rn := range text {
+		byteToRune[pos] = i
+		unitOffsets[i+1] = unitOffsets[i] + utf16.RuneLen(rn)
+		i++
+	}
+	byteToRune[len(text)] = len(runes)
+
+	masked := make([]bool, len(runes))
+	maskedAny := false
+	markUnits := func(from, to int) { // utf-16 range
+		for i := range runes {
+			if unitOffsets[i] >= from && unitOffsets[i] < to {
+				masked[i], maskedAny = true, true
+			}
+		}
+	}
+	for _, re := range r.patterns {
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			for i := byteToRune[loc[0]]; i < byteToRune[loc[1]]; i++ {
+				masked[i], maskedAny = true, true
+			}
+		}
+	}
+	hiddenLinks := false
+	if entities != nil {
+		for _, e := range *entities {
+			if e.Type == "text_mention" && e.User != nil && r.isAnonymized(*e.User) {
+				markUnits(e.Offset, e.Offset+e.Length)
+			}
+			hiddenLinks = hiddenLinks || r.hiddenLink(e)
+		}
+	}
+	if !maskedAny && !hiddenLinks {
+		return text, entities
+	}
+
+	var b strings.Builder
+	for i, rn := range runes {
+		if !masked[i] {
+			b.WriteRune(rn)
+			continue
+		}
+		for u := unitOffsets[i]; u < unitOffsets[i+1]; u++ {
+			b.WriteRune(redactMask)
+		}
+	}
+	if entities == nil {
+		return b.String(), nil
+	}
+
+	isMasked := func(from, to int) bool {
+		for i := range runes {
+			if masked[i] && unitOffsets[i] < to && unitOffsets[i+1] > from {
+				return true
+			}
+		}
+		return false
+	}
+	res := make([]bot.Entity, 0, len(*entities))
+	for _, e := range *entities {
+		if r.hiddenLink(e) || (!keepOnMask[e.Type] && isMasked(e.Offset, e.Offset+e.Length)) {
+			continue
+		}
+		res = append(res, e)
+	}
+	return b.String(), &res
+}
@@ -0,0 +1,77 @@
+package reporter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+func TestRedaction_Apply(t *testing.T) {
+	r, err := NewRedaction([]string{"@Secret", "42"}, []string{`[\w.]+@[\w.]+\.\w+`, `\+?\d[\d -]{8,}\d`}, []int{3})
+	require.NoError(t, err)
+
+	ts := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	secret := bot.User{ID: 1, Username: "secret", DisplayName: "Secret Person"}
+	byID := bot.User{ID: 42, Username: "other", DisplayName: "Other Person"}
+	user := bot.User{ID: 2, Username: "user", DisplayName: "User"}
+	entities := func(e ...bot.Entity) *[]bot.Entity { return &e }
+
+	excluded := bot.Message{ID: 3, From: user, Sent: ts, Text: "удалите меня"}
+	replyToExcluded := bot.Message{ID: 4, From: user, Sent: ts.Add(time.Minute), Text: "ok"}
+	replyToExcluded.ReplyTo.ID, replyToExcluded.ReplyTo.From, replyToExcluded.ReplyTo.Text = 3, user, "удалите меня"
+	replyToSecret := bot.Message{ID: 5, From: user, Sent: ts.Add(time.Minute), Text: "пиши на a.b@mail.ru"}
+	replyToSecret.ReplyTo.From, replyToSecret.ReplyTo.Text = secret, "мой тел +7 999 123-45-67"
+
+	messages := []bot.Message{
+		{ID: 1, From: secret, Sent: ts, Text: "привет"},
+		{ID: 2, From: user, Sent: ts, Text: "👍 mail a.b@mail.ru, **bold**", Entities: entities(
+			bot.Entity{Type: "email", Offset: 8, Length: 11}, bot.Entity{Type: "bold", Offset: 3, Length: 15},
+			bot.Entity{Type: "bold", Offset: 20, Length: 8})},
+		excluded,
+		replyToExcluded,
+		replyToSecret,
+		{ID: 6, From: byID, Sent: ts, Text: "hi @secret and Secret", Entities: entities(
+			bot.Entity{Type: "mention", Offset: 3, Length: 7},
+			bot.Entity{Type: "text_mention", Offset: 15, Length: 6, User: &secret},
+			bot.Entity{Type: "text_link", Offset: 0, Length: 2, URL: "mailto:x@example.com"})},
+		{ID: 7, From: user, Sent: ts, Image: &bot.Image{FileID: "f", Caption: "call 8-800-555-35-35"}},
+	}
+
+	res := r.Apply(messages)
+	require.Len(t, res, 6)
+
+	assert.Equal(t, bot.User{DisplayName: "аноним 1"}, res[0].From)
+	assert.Equal(t, "привет", res[0].Text)
+
+	assert.Equal(t, "👍 mail "+strings.Repeat("•", 11)+", **bold**", res[1].Text)
+	assert.Equal(t, &[]bot.Entity{{Type: "bold", Offset: 3, Length: 15}, {Type: "bold", Offset: 20, Length: 8}},
+		res[1].Entities, "email entity dropped, formatting kept")
+	assert.Equal(t, "👍 mail a.b@mail.ru, **bold**", messages[1].Text, "source not changed")
+	assert.Len(t, *messages[1].Entities, 3)
+
+	assert.Equal(t, 4, res[2].ID, "excluded message removed")
+	assert.Empty(t, res[2].ReplyTo.Text, "quote of excluded message removed")
+	assert.Equal(t, 3, res[2].ReplyTo.ID)
+
+	assert.Equal(t, "пиши на "+strings.Repeat("•", 11), res[3].Text)
+	assert.Equal(t, bot.User{DisplayName: "аноним 1"}, res[3].ReplyTo.From)
+	assert.Equal(t, "мой тел "+strings.Repeat("•", 16), res[3].ReplyTo.Text)
+
+	assert.Equal(t, bot.User{DisplayName: "аноним 2"}, res[4].From, "anonymized by id")
+	assert.Equal(t, "hi ••••••• and ••••••", res[4].Text)
+	assert.Equal(t, &[]bot.Entity{}, res[4].Entities)
+
+	assert.Equal(t, "call "+strings.Repeat("•", 15), res[5].Image.Caption)
+	assert.Equal(t, "call 8-800-555-35-35", messages[6].Image.Caption)
+
+	var nilRedaction *Redaction
+	assert.Equal(t, messages, nilRedaction.Apply(messages))
+
+	_, err = NewRedaction(nil, []string{"("}, nil)
+	assert.Error(t, err)
+}
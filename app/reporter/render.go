@@ -0,0 +1,423 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// Renderer writes exported show chat in some format
+type Renderer interface {
+	Render(w io.Writer, show ShowExport) error
+	Ext() string // extension of the exported file
+}
+
+// ShowExport is a show chat prepared for rendering, filtered messages with links to downloaded images
+type ShowExport struct {
+	Num     int
+	Start   time.Time // broadcast start, zero if unknown
+	Records []ExportRecord
+	Stats   ShowStats
+	Links   []bot.LinkGroup // links shared in the chat, grouped by domain
+	Summary *ChatSummary    // what the chat talked about, nil if there is no summary
+}
+
+// ExportRecord is a single exported message
+type ExportRecord struct {
+	Time     string // local time of the message in export time format
+	Offset   string // offset from the broadcast start, +01:23:45, empty if the start is unknown
+	Msg      bot.Message
+	IsHost   bool
+	IsBot    bool
+	Media    *Media       // downloaded image, nil if no image or download failed
+	Anchor   string       // html anchor of the message, empty for messages without id
+	Reply    *ExportReply // quote of the replied message, nil if not a reply
+	IsAnswer bool         // reply placed right after the replied message as q&a pair, bot answer or reply to the bot
+}
+
+// ExportReply is a quote of the replied message
+type ExportReply struct {
+	ID     int    // id of the replied message, zero if it isn't exported
+	Anchor string // anchor of the replied message, empty if it isn't exported
+	Author string
+	Text   string // shortened text of the replied message
+}
+
+// NewRenderer makes renderer for the export format, html by default
+func NewRenderer(format, templateFile string, location *time.Location, timeFormat string) (Renderer, error) {
+	switch format {
+	case "", "html":
+		return &htmlRenderer{templateFile: templateFile, location: location, timeFormat: timeFormat}, nil
+	case "markdown", "md":
+		return markdownRenderer{}, nil
+	case "json":
+		return jsonRenderer{}, nil
+	case "text", "txt":
+		return textRenderer{}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// htmlRenderer executes html template, i.e. logs.html
+type htmlRenderer struct {
+	templateFile string
+	location     *time.Location
+	timeFormat   string
+}
+
+func (r *htmlRenderer) Ext() string { return "html" }
+
+func (r *htmlRenderer) Render(w io.Writer, show ShowExport) error {
+	fileIDToURL := map[string]string{}
+	for _, rec := range show.Records {
+		if rec.Msg.Image != nil && rec.Media != nil {
+			fileIDToURL[rec.Msg.Image.FileID] = rec.Media.URL
+		}
+	}
+
+	funcMap := template.FuncMap{
+		"fileURL": func(fileID string) string { return fileIDToURL[fileID] },
+		"timestampHuman": func(t time.Time) string {
+			return t.In(r.location).Format(r.timeFormat)
+		},
+		"format": format,
+		"join":   strings.Join,
+	}
+	name := r.templateFile[strings.LastIndex(r.templateFile, "/")+1:]
+	t, err := template.New(name).Funcs(funcMap).ParseFiles(r.templateFile)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+	if err := t.ExecuteTemplate(w, name, show); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return nil
+}
+
+// markdownRenderer makes a page for the hugo site, with front matter
+type markdownRenderer struct{}
+
+func (markdownRenderer) Ext() string { return "md" }
+
+func (markdownRenderer) Render(w io.Writer, show ShowExport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ntitle: \"Лог Радио-Т #%d\"\n---\n", show.Num)
+	if show.Summary != nil {
+		fmt.Fprintf(&b, "\n## О чём говорили в чате\n\n%s\n", escapeMarkdown(show.Summary.Text))
+		if len(show.Summary.Threads) > 0 {
+			b.WriteString("\n")
+		}
+		for _, t := range show.Summary.Threads {
+			fmt.Fprintf(&b, "- %s\n", escapeMarkdown(t))
+		}
+	}
+	for _, rec := range show.Records {
+		author := escapeMarkdown(rec.Msg.From.DisplayName)
+		if rec.IsHost {
+			author = "**" + author + "**"
+		}
+		fmt.Fprintf(&b, "\n`%s` %s:", rec.Time, author)
+		if rec.Msg.Text != "" {
+			b.WriteString(" " + markdownText(rec.Msg.Text, rec.Msg.Entities))
+		}
+		if rec.Msg.Image != nil && rec.Media != nil {
+			fmt.Fprintf(&b, "  \n[![](%s)](%s)", rec.Media.ThumbURL, rec.Media.URL)
+			if rec.Msg.Image.Caption != "" {
+				fmt.Fprintf(&b, "  \n%s", markdownText(rec.Msg.Image.Caption, rec.Msg.Image.Entities))
+			}
+		}
+		b.WriteString("\n")
+	}
+	if len(show.Links) > 0 {
+		b.WriteString("\n## Ссылки из чата\n")
+		for _, g := range show.Links {
+			fmt.Fprintf(&b, "\n**%s**\n\n", escapeMarkdown(g.Domain))
+			for _, l := range g.Links {
+				title := l.Title
+				if title == "" {
+					title = l.URL
+				}
+				fmt.Fprintf(&b, "- [%s](<%s>) – %s\n", escapeMarkdown(title), l.URL, escapeMarkdown(strings.Join(l.SharedBy, ", ")))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownMarkup formats messages for markdown export
+var markdownMarkup = markup{
+	escape:   escapeMarkdown,
+	decorate: markdownDecoration,
+	verbatim: map[string]bool{"code": true, "pre": true, "url": true, "email": true},
+}
+
+// markdownText formats text with entities, lines kept with hard line breaks
+func markdownText(text string, entities *[]bot.Entity) string {
+	return strings.ReplaceAll(markdownMarkup.format(text, entities), "\n", "  \n")
+}
+
+func markdownDecoration(entity bot.Entity, body []rune) (op, cl string) {
+	switch entity.Type {
+	case "bold":
+		return "**", "**"
+	case "italic":
+		return "_", "_"
+	case "strikethrough":
+		return "~~", "~~"
+	case "code":
+		return "`", "`"
+	case "pre":
+		return "\n```\n", "\n```\n"
+	case "text_link":
+		return "[", fmt.Sprintf("](%s)", entity.URL)
+	case "url":
+		if u, err := url.Parse(string(body)); err == nil && u.Scheme == "" {
+			return fmt.Sprintf("[%s](https://", string(body)), ")" // fix links without scheme
+		}
+		return "<", ">"
+	case "email":
+		return "<", ">"
+	case "mention":
+		return "[", fmt.Sprintf("](https://t.me/%s)", string(body[1:]))
+	case "text_mention":
+		if entity.User != nil {
+			return "[", fmt.Sprintf("](tg://user?id=%d)", entity.User.ID)
+		}
+	case "blockquote", "expandable_blockquote":
+		return "\n> ", "\n" // lazy continuation keeps the following lines in the quote
+	}
+	return "", ""
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "~", `\~`, "|", `\|`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// jsonRenderer makes structured export for the site renderer
+type jsonRenderer struct{}
+
+func (jsonRenderer) Ext() string { return "json" }
+
+type jsonExport struct {
+	Num      int             `json:"num"`
+	Messages []jsonMessage   `json:"messages"`
+	Stats    jsonStats       `json:"stats"`
+	Links    []jsonLinkGroup `json:"links"`
+	Summary  *jsonSummary    `json:"summary,omitempty"`
+}
+
+type jsonSummary struct {
+	Text    string   `json:"text"`
+	Threads []string `json:"threads"`
+}
+
+type jsonLinkGroup struct {
+	Domain string     `json:"domain"`
+	Links  []jsonLink `json:"links"`
+}
+
+type jsonLink struct {
+	URL      string    `json:"url"`
+	Title    string    `json:"title,omitempty"`
+	SharedBy []string  `json:"shared_by"`
+	Sent     time.Time `json:"sent"`
+}
+
+type jsonStats struct {
+	Messages     int              `json:"messages"`
+	Participants []jsonCount      `json:"participants"`
+	Timeline     []jsonCount      `json:"timeline"` // name is the local time of the minute
+	Domains      []jsonCount      `json:"domains"`
+	Reacted      []jsonReactedMsg `json:"reacted"`
+	Commands     []jsonCount      `json:"commands"`
+	Bans         int              `json:"bans"`
+}
+
+type jsonCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type jsonReactedMsg struct {
+	ID   int `json:"id"`
+	Up   int `json:"up"`
+	Down int `json:"down"`
+}
+
+func newJSONStats(s ShowStats) jsonStats {
+	counts := func(src []StatsCount) []jsonCount {
+		res := make([]jsonCount, 0, len(src))
+		for _, c := range src {
+			res = append(res, jsonCount{Name: c.Name, Count: c.Count})
+		}
+		return res
+	}
+	res := jsonStats{Messages: s.Messages, Bans: s.Bans, Participants: counts(s.Participants), Domains: counts(s.Domains),
+		Commands: counts(s.Commands), Timeline: make([]jsonCount, 0, len(s.Timeline)), Reacted: make([]jsonReactedMsg, 0, len(s.Reacted))}
+	for _, p := range s.Timeline {
+		res.Timeline = append(res.Timeline, jsonCount{Name: p.Label, Count: p.Count})
+	}
+	for _, r := range s.Reacted {
+		res.Reacted = append(res.Reacted, jsonReactedMsg{ID: r.Msg.ID, Up: r.Up, Down: r.Down})
+	}
+	return res
+}
+
+type jsonMessage struct {
+	ID       int          `json:"id"`
+	Sent     time.Time    `json:"sent"`
+	Time     string       `json:"time"`
+	Offset   string       `json:"offset,omitempty"`
+	Username string       `json:"username,omitempty"`
+	Name     string       `json:"name"`
+	IsHost   bool         `json:"is_host,omitempty"`
+	IsBot    bool         `json:"is_bot,omitempty"`
+	Text     string       `json:"text,omitempty"`
+	HTML     string       `json:"html,omitempty"`
+	Entities []bot.Entity `json:"entities,omitempty"`
+	Image    *jsonImage   `json:"image,omitempty"`
+	Reply    *jsonReply   `json:"reply,omitempty"`
+	IsAnswer bool         `json:"is_answer,omitempty"`
+}
+
+type jsonReply struct {
+	ID     int    `json:"id,omitempty"` // id of the replied message if it is exported
+	Author string `json:"author"`
+	Text   string `json:"text,omitempty"`
+}
+
+type jsonImage struct {
+	URL         string `json:"url,omitempty"`
+	MIME        string `json:"mime,omitempty"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ThumbURL    string `json:"thumb_url,omitempty"`
+	ThumbWidth  int    `json:"thumb_width,omitempty"`
+	ThumbHeight int    `json:"thumb_height,omitempty"`
+	Caption     string `json:"caption,omitempty"`
+	CaptionHTML string `json:"caption_html,omitempty"`
+}
+
+func (jsonRenderer) Render(w io.Writer, show ShowExport) error {
+	res := jsonExport{Num: show.Num, Messages: make([]jsonMessage, 0, len(show.Records)), Stats: newJSONStats(show.Stats),
+		Links: make([]jsonLinkGroup, 0, len(show.Links))}
+	for _, g := range show.Links {
+		group := jsonLinkGroup{Domain: g.Domain, Links: make([]jsonLink, 0, len(g.Links))}
+		for _, l := range g.Links {
+			group.Links = append(group.Links, jsonLink{URL: l.URL, Title: l.Title, SharedBy: l.SharedBy, Sent: l.Sent})
+		}
+		res.Links = append(res.Links, group)
+	}
+	if show.Summary != nil {
+		res.Summary = &jsonSummary{Text: show.Summary.Text, Threads: append([]string{}, show.Summary.Threads...)}
+	}
+	for _, rec := range show.Records {
+		msg := jsonMessage{
+			ID:       rec.Msg.ID,
+			Sent:     rec.Msg.Sent,
+			Time:     rec.Time,
+			Offset:   rec.Offset,
+			Username: rec.Msg.From.Username,
+			Name:     rec.Msg.From.DisplayName,
+			IsHost:   rec.IsHost,
+			IsBot:    rec.IsBot,
+			Text:     rec.Msg.Text,
+			HTML:     string(format(rec.Msg.Text, rec.Msg.Entities)),
+			IsAnswer: rec.IsAnswer,
+		}
+		if rec.Reply != nil {
+			msg.Reply = &jsonReply{ID: rec.Reply.ID, Author: rec.Reply.Author, Text: rec.Reply.Text}
+		}
+		if rec.Msg.Entities != nil {
+			msg.Entities = *rec.Msg.Entities
+		}
+		if img := rec.Msg.Image; img != nil {
+			msg.Image = &jsonImage{Width: img.Width, Height: img.Height, Caption: img.Caption,
+				CaptionHTML: string(format(img.Caption, img.Entities))}
+			if m := rec.Media; m != nil {
+				msg.Image.URL, msg.Image.MIME, msg.Image.Width, msg.Image.Height = m.URL, m.MIME, m.Width, m.Height
+				msg.Image.ThumbURL, msg.Image.ThumbWidth, msg.Image.ThumbHeight = m.ThumbURL, m.ThumbWidth, m.ThumbHeight
+			}
+		}
+		res.Messages = append(res.Messages, msg)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(res); err != nil {
+		return fmt.Errorf("failed to encode: %w", err)
+	}
+	return nil
+}
+
+// textRenderer makes plain text archive, links of text_link entities added after the link text
+type textRenderer struct{}
+
+func (textRenderer) Ext() string { return "txt" }
+
+// textMarkup formats messages for plain text export
+var textMarkup = markup{
+	escape: func(s string) string { return s },
+	decorate: func(entity bot.Entity, _ []rune) (op, cl string) {
+		if entity.Type == "text_link" {
+			return "", fmt.Sprintf(" (%s)", entity.URL)
+		}
+		return "", ""
+	},
+}
+
+func (textRenderer) Render(w io.Writer, show ShowExport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Лог Радио-Т #%d\n\n", show.Num)
+	if show.Summary != nil {
+		fmt.Fprintf(&b, "О чём говорили в чате\n\n%s\n", show.Summary.Text)
+		for _, t := range show.Summary.Threads {
+			fmt.Fprintf(&b, "    - %s\n", t)
+		}
+		b.WriteString("\n")
+	}
+	for _, rec := range show.Records {
+		text := textMarkup.format(rec.Msg.Text, rec.Msg.Entities)
+		if rec.Msg.Image != nil {
+			image := "[image]"
+			if rec.Media != nil {
+				image = fmt.Sprintf("[image %s]", rec.Media.URL)
+			}
+			text = strings.TrimSpace(strings.Join([]string{text, image,
+				textMarkup.format(rec.Msg.Image.Caption, rec.Msg.Image.Entities)}, " "))
+		}
+		author := rec.Msg.From.DisplayName
+		if rec.Msg.From.Username != "" {
+			author += " (@" + rec.Msg.From.Username + ")"
+		}
+		// continuation lines indented to keep one message per block
+		fmt.Fprintf(&b, "%s %s: %s\n", rec.Time, author, strings.ReplaceAll(text, "\n", "\n    "))
+	}
+	if len(show.Links) > 0 {
+		b.WriteString("\nСсылки из чата\n")
+		for _, g := range show.Links {
+			fmt.Fprintf(&b, "\n%s\n", g.Domain)
+			for _, l := range g.Links {
+				if l.Title != "" {
+					fmt.Fprintf(&b, "    %s – %s (%s)\n", l.Title, l.URL, strings.Join(l.SharedBy, ", "))
+					continue
+				}
+				fmt.Fprintf(&b, "    %s (%s)\n", l.URL, strings.Join(l.SharedBy, ", "))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,139 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+func testShow() ShowExport {
+	sent := time.Date(2020, 2, 8, 20, 1, 2, 0, time.UTC)
+	return ShowExport{Num: 688, Records: []ExportRecord{
+		{Time: "23:01:02", IsHost: true, Msg: bot.Message{ID: 1, Sent: sent, From: bot.User{Username: "umputun", DisplayName: "Umputun"},
+			Text: "see *this* link\nand code", Entities: &[]bot.Entity{
+				{Type: "text_link", Offset: 4, Length: 6, URL: "https://example.com"},
+				{Type: "code", Offset: 20, Length: 4},
+			}}},
+		{Time: "23:01:03", Media: &Media{URL: "688/img", ThumbURL: "688/thumb", MIME: "image/jpeg", Width: 10, Height: 20,
+			ThumbWidth: 5, ThumbHeight: 10}, Msg: bot.Message{ID: 2, Sent: sent.Add(time.Second),
+			From:  bot.User{Username: "user", DisplayName: "Some User"},
+			Image: &bot.Image{FileID: "img", Width: 10, Height: 20, Caption: "cap"}}},
+	}, Links: []bot.LinkGroup{{Domain: "example.com", Links: []bot.SharedLink{
+		{URL: "https://example.com", Title: "Example", SharedBy: []string{"umputun"}, Sent: sent},
+		{URL: "https://example.com/x_y", SharedBy: []string{"umputun", "user"}, Sent: sent},
+	}}}, Summary: &ChatSummary{Text: "обсуждали *ссылки*", Threads: []string{"example.com", "картинки"}, Hash: "h"}}
+}
+
+func TestNewRenderer(t *testing.T) {
+	tbl := []struct {
+		format string
+		ext    string
+		fail   bool
+	}{
+		{"", "html", false},
+		{"html", "html", false},
+		{"markdown", "md", false},
+		{"md", "md", false},
+		{"json", "json", false},
+		{"text", "txt", false},
+		{"pdf", "", true},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.format, func(t *testing.T) {
+			r, err := NewRenderer(tt.format, "logs.html", time.UTC, "15:04:05")
+			if tt.fail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.ext, r.Ext())
+		})
+	}
+}
+
+func TestHTMLRenderer(t *testing.T) {
+	tmpl := filepath.Join(t.TempDir(), "logs.html")
+	require.NoError(t, os.WriteFile(tmpl, []byte(`#{{.Num}}{{range .Records}}|{{.Msg.Sent | timestampHuman}} `+
+		`{{format .Msg.Text .Msg.Entities}}{{if .Msg.Image}}<img src="{{.Msg.Image.FileID | fileURL}}">{{end}}{{end}}`), 0o600))
+	r, err := NewRenderer("html", tmpl, time.FixedZone("MSK", 3*3600), "15:04")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Render(&buf, testShow()))
+	assert.Equal(t, `#688|23:01 see <a href="https://example.com">*this*</a> link<br>and <code>code</code>`+
+		`|23:01 <img src="688/img">`, buf.String())
+}
+
+func TestMarkdownRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, markdownRenderer{}.Render(&buf, testShow()))
+	assert.Equal(t, "---\ntitle: \"Лог Радио-Т #688\"\n---\n"+
+		"\n## О чём говорили в чате\n\nобсуждали \\*ссылки\\*\n\n- example.com\n- картинки\n"+
+		"\n`23:01:02` **Umputun**: see [\\*this\\*](https://example.com) link  \nand `code`\n"+
+		"\n`23:01:03` Some User:  \n[![](688/thumb)](688/img)  \ncap\n"+
+		"\n## Ссылки из чата\n\n**example.com**\n\n- [Example](<https://example.com>) – umputun\n"+
+		"- [https://example.com/x\\_y](<https://example.com/x_y>) – umputun, user\n", buf.String())
+}
+
+func TestJSONRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jsonRenderer{}.Render(&buf, testShow()))
+
+	var res jsonExport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+	assert.Equal(t, 688, res.Num)
+	require.Len(t, res.Messages, 2)
+	assert.Equal(t, "umputun", res.Messages[0].Username)
+	assert.True(t, res.Messages[0].IsHost)
+	assert.Equal(t, `see <a href="https://example.com">*this*</a> link<br>and <code>code</code>`, res.Messages[0].HTML)
+	assert.Len(t, res.Messages[0].Entities, 2)
+	assert.Equal(t, &jsonImage{URL: "688/img", MIME: "image/jpeg", Width: 10, Height: 20, ThumbURL: "688/thumb",
+		ThumbWidth: 5, ThumbHeight: 10, Caption: "cap", CaptionHTML: "cap"}, res.Messages[1].Image)
+	require.Len(t, res.Links, 1)
+	assert.Equal(t, "example.com", res.Links[0].Domain)
+	assert.Equal(t, jsonLink{URL: "https://example.com/x_y", SharedBy: []string{"umputun", "user"},
+		Sent: time.Date(2020, 2, 8, 20, 1, 2, 0, time.UTC)}, res.Links[0].Links[1])
+	assert.Equal(t, &jsonSummary{Text: "обсуждали *ссылки*", Threads: []string{"example.com", "картинки"}}, res.Summary)
+}
+
+func TestTextRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, textRenderer{}.Render(&buf, testShow()))
+	assert.Equal(t, "Лог Радио-Т #688\n\n"+
+		"О чём говорили в чате\n\nобсуждали *ссылки*\n    - example.com\n    - картинки\n\n"+
+		"23:01:02 Umputun (@umputun): see *this* (https://example.com) link\n    and code\n"+
+		"23:01:03 Some User (@user): [image 688/img] cap\n"+
+		"\nСсылки из чата\n\nexample.com\n    Example – https://example.com (umputun)\n"+
+		"    https://example.com/x_y (umputun, user)\n", buf.String())
+}
+
+func TestMarkdownText(t *testing.T) {
+	tbl := []struct {
+		text     string
+		entities *[]bot.Entity
+		out      string
+	}{
+		{"plain_text #1", nil, `plain\_text \#1`},
+		{"bold and url", &[]bot.Entity{{Type: "bold", Offset: 0, Length: 4}, {Type: "url", Offset: 9, Length: 3}}, "**bold** and [url](https://url)"},
+		{"go https://a.b/c_d", &[]bot.Entity{{Type: "url", Offset: 3, Length: 15}}, "go <https://a.b/c_d>"},
+		{"hi @user", &[]bot.Entity{{Type: "mention", Offset: 3, Length: 5}}, `hi [@user](https://t.me/user)`},
+		{"under", &[]bot.Entity{{Type: "underline", Offset: 0, Length: 5}}, "under"},
+		{"**bold link**", &[]bot.Entity{{Type: "text_link", Offset: 0, Length: 13, URL: "https://example.com"},
+			{Type: "bold", Offset: 2, Length: 9}}, `[\*\***bold link**\*\*](https://example.com)`},
+		{"👍 user", &[]bot.Entity{{Type: "text_mention", Offset: 3, Length: 4, User: &bot.User{ID: 42}}}, "👍 [user](tg://user?id=42)"},
+		{"quote\nlines", &[]bot.Entity{{Type: "blockquote", Offset: 0, Length: 11}}, "  \n> quote  \nlines  \n"},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.out, markdownText(tt.text, tt.entities))
+		})
+	}
+}
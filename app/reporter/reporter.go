@@ -1,31 +1,87 @@
 package reporter
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
-	"github.com/radio-t/super-bot/app/bot"
-	"net/http"
 	"github.com/go-pkgz/repeater"
-	"context"
+
+	"github.com/radio-t/super-bot/app/bot"
 )
 
+// flushSize is the number of verified entries forcing flush to the log file
+const flushSize = 100
+
+// maxVerifications limits the number of concurrent message existence checks
+const maxVerifications = 50
+
 type msgEntry struct {
-	MessageID int
-	Data      string
+	Seq       int64     // sequence number of the accepted entry, defines order in the log
+	MessageID int       // telegram message id
+	At        time.Time // time the entry was accepted, defines daily log file
+	Data      string    // json-encoded message with trailing new line
 }
 
-// Reporter collects all messages and saves to plain file
+// Reporter collects all messages and saves to plain file.
+// Accepted messages written to the write-ahead journal first, verified concurrently after saveDelay
+// and written to the daily log in the order of arrival. Close should be called to flush everything on shutdown.
+// Messages deleted by the bot reported with Delete, they are dropped before written or removed from the written log.
 type Reporter struct {
 	logsPath  string
-	messages  chan msgEntry
 	saveDelay time.Duration
 	chatID    string
+	probe     bool     // check t.me for messages deleted not by the bot
+	history   *History // optional history database
+	noFileLog bool     // don't write daily log files
 	httpCl    httpClient
 	repeater  *repeater.Repeater
+	journal   *journal
+	verifySem chan struct{}
+	nowFn     func() time.Time // for testing
+
+	mu       sync.Mutex
+	seq      int64                  // last accepted entry
+	nextSeq  int64                  // next entry to move to the buffer
+	doneSeq  int64                  // last entry marked as done in the journal
+	verified map[int64]verification // verified entries waiting for preceding ones
+	pending  map[int]int            // number of accepted, not yet buffered entries per message id
+	deleted  map[int]bool           // deleted messages with pending entries
+	removals []int                  // deleted messages to remove from already written logs
+	buffer   []msgEntry
+	closed   bool
+
+	inFlight sync.WaitGroup
+	flushCh  chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// Option func type
+type Option func(l *Reporter)
+
+// WithHistory adds history database, verified messages saved to it along with the daily log files
+func WithHistory(h *History) Option {
+	return func(l *Reporter) { l.history = h }
+}
+
+// WithoutFileLog disables daily log files, makes sense with history database only
+func WithoutFileLog() Option {
+	return func(l *Reporter) { l.noFileLog = true }
+}
+
+type verification struct {
+	entry  msgEntry
+	exists bool
 }
 
 //go:generate moq -out mock_reporter.go . httpClient
@@ -34,31 +90,70 @@ type httpClient interface {
 	Get(url string) (*http.Response, error)
 }
 
-// NewLogger makes new reporter bot
-func NewLogger(logs string, delay time.Duration, chatID string) (result *Reporter) {
+// NewLogger makes new reporter bot and replays entries left in the journal by the previous run.
+// Public chat name enables t.me check of deleted messages, empty or numeric chatID disables it.
+func NewLogger(logs string, delay time.Duration, chatID string, opts ...Option) (result *Reporter) {
+	return newLogger(logs, delay, chatID, &http.Client{
+		Timeout: time.Second * 5,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			// don't follow redirects, we need to check status code
+			return http.ErrUseLastResponse
+		},
+	}, opts...)
+}
+
+func newLogger(logs string, delay time.Duration, chatID string, httpCl httpClient, opts ...Option) (result *Reporter) {
 	log.Printf("[INFO] new reporter, path=%s", logs)
 	if err := os.MkdirAll(logs, 0o750); err != nil {
 		log.Printf("[WARN] can't make logs dir %s, %v", logs, err)
 	}
 	result = &Reporter{
 		logsPath:  logs,
-		messages:  make(chan msgEntry, 1000),
 		saveDelay: delay,
-		httpCl: &http.Client{
-			Timeout: time.Second * 5,
-			CheckRedirect: func(*http.Request, []*http.Request) error {
-				// don't follow redirects, we need to check status code
-				return http.ErrUseLastResponse
-			},
-		},
-		repeater: repeater.NewDefault(3, 2*time.Second),
-		chatID:   chatID,
+		httpCl:    httpCl,
+		repeater:  repeater.NewDefault(3, 2*time.Second),
+		chatID:    chatID,
+		verifySem: make(chan struct{}, maxVerifications),
+		nowFn:     time.Now,
+		nextSeq:   1,
+		verified:  map[int64]verification{},
+		pending:   map[int]int{},
+		deleted:   map[int]bool{},
+		flushCh:   make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	if _, err := strconv.ParseInt(chatID, 10, 64); err == nil || chatID == "" {
+		log.Printf("[INFO] t.me check of deleted messages disabled for chat %q", chatID)
+	} else {
+		result.probe = true
+	}
+
+	jr, pending, err := openJournal(filepath.Join(logs, "reporter.journal"))
+	if err != nil {
+		log.Printf("[WARN] can't open reporter journal, entries won't survive restart: %v", err)
+	}
+	result.journal = jr
+	if len(pending) > 0 {
+		log.Printf("[INFO] replay %d entries from reporter journal", len(pending))
+	}
+	for _, entry := range pending {
+		result.seq = entry.Seq
+		result.pending[entry.MessageID]++
+		result.inFlight.Add(1)
+		result.verifyAfter(entry, 0) // delay already passed before the restart
 	}
+
 	go result.activate()
 	return result
 }
 
-// Save to log channel, non-blocking and skip if needed
+// Save accepts message to the log, never blocks on verification and doesn't drop entries
 func (l *Reporter) Save(msg *bot.Message) {
 	if msg.Text == "" && msg.Image == nil {
 		log.Printf("[DEBUG] message not saved to log: no text or image = irrelevant, msg id: %d", msg.ID)
@@ -71,66 +166,303 @@ func (l *Reporter) Save(msg *bot.Message) {
 		return
 	}
 
-	select {
-	case l.messages <- msgEntry{MessageID: msg.ID, Data: string(bdata) + "\n"}:
-	default:
-		log.Printf("[WARN] can't buffer log entry %v", msg)
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		log.Printf("[WARN] reporter closed, can't log entry %v", msg)
+		return
+	}
+	l.seq++
+	entry := msgEntry{Seq: l.seq, MessageID: msg.ID, At: l.nowFn(), Data: string(bdata) + "\n"}
+	if l.journal != nil {
+		if err := l.journal.add(entry); err != nil {
+			log.Printf("[WARN] failed to journal log entry, %v", err)
+		}
+	}
+	l.pending[entry.MessageID]++
+	l.inFlight.Add(1)
+	l.mu.Unlock()
+
+	// don't save right away, wait for antispam checks
+	l.verifyAfter(entry, l.saveDelay)
+}
+
+// Close stops accepting messages, waits for pending verifications and flushes everything to the log
+func (l *Reporter) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	l.inFlight.Wait()
+	close(l.stop)
+	<-l.stopped
+	if l.journal == nil {
+		return nil
+	}
+	return l.journal.close()
+}
+
+// Delete drops entries of the message deleted by the bot. Pending entries are dropped after verification,
+// buffered ones removed right away and already written ones removed from the log on the next flush.
+func (l *Reporter) Delete(msgID int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.journal != nil {
+		if err := l.journal.deleted(msgID); err != nil {
+			log.Printf("[WARN] failed to journal deleted message %d, %v", msgID, err)
+		}
+	}
+
+	if l.pending[msgID] > 0 {
+		l.deleted[msgID] = true
+		return
+	}
+
+	buffer := l.buffer[:0]
+	for _, e := range l.buffer {
+		if e.MessageID != msgID {
+			buffer = append(buffer, e)
+		}
+	}
+	if len(buffer) < len(l.buffer) {
+		l.buffer = buffer
+		log.Printf("[DEBUG] deleted message %d dropped from buffer", msgID)
+		return
+	}
+	l.removals = append(l.removals, msgID)
+}
+
+// verifyAfter checks the message existence after the delay and passes the result to complete
+func (l *Reporter) verifyAfter(entry msgEntry, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		defer l.inFlight.Done()
+		exists := true
+		if l.probe && entry.MessageID > 0 { // non-positive ids are synthetic, not on t.me
+			l.verifySem <- struct{}{}
+			exists = l.messageExists(entry.MessageID)
+			<-l.verifySem
+		}
+		l.complete(verification{entry: entry, exists: exists})
+	})
+}
+
+// complete records verification result and moves all verified entries without gaps to the buffer,
+// so the log keeps the order of arrival regardless of the verification order
+func (l *Reporter) complete(v verification) {
+	l.mu.Lock()
+	l.verified[v.entry.Seq] = v
+	for {
+		v, ok := l.verified[l.nextSeq]
+		if !ok {
+			break
+		}
+		delete(l.verified, l.nextSeq)
+		l.nextSeq++
+
+		id := v.entry.MessageID
+		switch {
+		case l.deleted[id]:
+			log.Printf("[DEBUG] message %d has been deleted by bot, skipping", id)
+		case !v.exists:
+			log.Printf("[DEBUG] message %d has been deleted, skipping", id)
+		default:
+			l.buffer = append(l.buffer, v.entry)
+		}
+		if l.pending[id]--; l.pending[id] <= 0 {
+			delete(l.pending, id)
+			delete(l.deleted, id)
+		}
+	}
+	full := len(l.buffer) >= flushSize
+	l.mu.Unlock()
+
+	if full { // forced flush every 100 records
+		select {
+		case l.flushCh <- struct{}{}:
+		default:
+		}
 	}
 }
 
 func (l *Reporter) activate() {
 	log.Print("[INFO] activate reporter")
-	buffer := make([]string, 0, 100)
 	ticker := time.NewTicker(time.Second * 5)
 	defer ticker.Stop()
 
-	writeBuff := func() error {
-		if len(buffer) == 0 {
-			return nil
+	for {
+		select {
+		case <-l.flushCh:
+			l.flush()
+		case <-ticker.C: // flush on 5 seconds inactivity
+			l.flush()
+		case <-l.stop:
+			l.flush()
+			close(l.stopped)
+			return
 		}
-		// nolint
-		fh, err := os.OpenFile(fmt.Sprintf("%s/%s.log", l.logsPath, time.Now().Format("20060102")),
-			os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0660)
+	}
+}
+
+// flush writes buffered entries to the daily log files and marks them as done in the journal.
+// On failure entries are returned to the buffer and retried on the next flush.
+func (l *Reporter) flush() {
+	l.mu.Lock()
+	buffer, doneSeq, removals := l.buffer, l.nextSeq-1, l.removals
+	l.buffer, l.removals = nil, nil
+	l.mu.Unlock()
+
+	if len(removals) > 0 {
+		l.remove(removals)
+	}
+
+	if err := l.addHistory(buffer); err != nil {
+		log.Printf("[WARN] failed to write reporter buffer to history, %v", err)
+		l.mu.Lock()
+		l.buffer = append(buffer, l.buffer...)
+		l.mu.Unlock()
+		return
+	}
+
+	if n, err := l.write(buffer); err != nil {
+		log.Printf("[WARN] failed to write reporter buffer, %v", err)
+		l.mu.Lock()
+		l.buffer = append(buffer[n:], l.buffer...)
+		l.mu.Unlock()
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.journal == nil || doneSeq <= l.doneSeq {
+		return
+	}
+	l.doneSeq = doneSeq
+	if doneSeq == l.seq && len(l.buffer) == 0 {
+		// nothing pending, the journal can be cleared
+		if err := l.journal.truncate(); err != nil {
+			log.Printf("[WARN] failed to truncate reporter journal, %v", err)
+		}
+		return
+	}
+	if err := l.journal.done(doneSeq); err != nil {
+		log.Printf("[WARN] failed to mark reporter journal, %v", err)
+	}
+}
 
+// remove drops deleted messages from the history and lines of deleted messages from the recent log files,
+// today and yesterday
+func (l *Reporter) remove(msgIDs []int) {
+	if l.history != nil {
+		if err := l.history.Delete(msgIDs...); err != nil {
+			log.Printf("[WARN] failed to remove deleted messages from history, %v", err)
+		}
+	}
+	if l.noFileLog {
+		return
+	}
+	ids := map[int]bool{}
+	for _, id := range msgIDs {
+		ids[id] = true
+	}
+	now := l.nowFn()
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+		fname := filepath.Join(l.logsPath, day.Format("20060102")+".log")
+		n, err := removeFromLog(fname, ids)
 		if err != nil {
-			log.Printf("[WARN] failed to log, %v", err)
-			return fmt.Errorf("failed to open log file: %w", err)
+			log.Printf("[WARN] failed to remove deleted messages from %s, %v", fname, err)
+			continue
 		}
-		defer fh.Close() // nolint
-		for _, rec := range buffer {
-			if _, err = fh.WriteString(rec); err != nil {
-				log.Printf("[WARN] failed to write log, %v", err)
-			}
+		if n > 0 {
+			log.Printf("[INFO] removed %d deleted messages from %s", n, fname)
 		}
+	}
+}
 
-		log.Printf("[DEBUG] wrote %d log entries", len(buffer))
-		buffer = buffer[:0]
-		return nil
+// removeFromLog rewrites log file without messages with given ids, returns the number of removed lines
+func removeFromLog(fname string, ids map[int]bool) (int, error) {
+	data, err := os.ReadFile(fname) // nolint
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read log file: %w", err)
 	}
 
-	for {
-		select {
-		case entry := <-l.messages:
-			// don't save right away, wait for antispam checks
-			time.Sleep(l.saveDelay)
+	removed := 0
+	res := make([]byte, 0, len(data))
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		msg := struct{ ID int }{}
+		if err := json.Unmarshal(line, &msg); err == nil && ids[msg.ID] {
+			removed++
+			continue
+		}
+		res = append(res, line...)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
 
-			if !l.messageExists(entry.MessageID) {
-				log.Printf("[DEBUG] message %d has been deleted, skipping", entry.MessageID)
-				continue
-			}
+	tmp := fname + ".tmp"
+	if err := os.WriteFile(tmp, res, 0o660); err != nil { // nolint
+		return 0, fmt.Errorf("failed to write log file: %w", err)
+	}
+	if err := os.Rename(tmp, fname); err != nil {
+		return 0, fmt.Errorf("failed to rename log file: %w", err)
+	}
+	return removed, nil
+}
 
-			buffer = append(buffer, entry.Data)
-			if len(buffer) >= 100 { // forced flush every 100 records
-				if err := writeBuff(); err != nil {
-					log.Printf("[WARN] failed to write reporter buffer, %v", err)
-				}
+// addHistory saves entries to the history database, saving the same entries again is harmless
+func (l *Reporter) addHistory(entries []msgEntry) error {
+	if l.history == nil || len(entries) == 0 {
+		return nil
+	}
+	msgs := make([]bot.Message, 0, len(entries))
+	for _, e := range entries {
+		msg := bot.Message{}
+		if err := json.Unmarshal([]byte(e.Data), &msg); err != nil {
+			log.Printf("[WARN] can't decode log entry %d for history, %v", e.MessageID, err)
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	return l.history.Add(msgs)
+}
+
+// write appends entries to the log files, file per day of the entry. Returns the number of written entries.
+func (l *Reporter) write(entries []msgEntry) (int, error) {
+	if l.noFileLog || len(entries) == 0 {
+		return len(entries), nil
+	}
+	var fh *os.File
+	defer func() {
+		if fh != nil {
+			_ = fh.Close()
+		}
+	}()
+	for i, rec := range entries {
+		fname := filepath.Join(l.logsPath, rec.At.Format("20060102")+".log")
+		if fh == nil || fh.Name() != fname {
+			if fh != nil {
+				_ = fh.Close()
 			}
-		case <-ticker.C: // flush on 5 seconds inactivity
-			if err := writeBuff(); err != nil {
-				log.Printf("[WARN] failed to write reporter buffer, %v", err)
+			var err error
+			fh, err = os.OpenFile(fname, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o660) // nolint
+			if err != nil {
+				fh = nil
+				return i, fmt.Errorf("failed to open log file: %w", err)
 			}
 		}
+		if _, err := fh.WriteString(rec.Data); err != nil {
+			return i, fmt.Errorf("failed to write log entry %d of %d: %w", i, len(entries), err)
+		}
 	}
+	log.Printf("[DEBUG] wrote %d log entries", len(entries))
+	return len(entries), nil
 }
 
 // messageExists checks if message wasn't deleted by a user, to prevent
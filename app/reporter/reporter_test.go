@@ -13,6 +13,8 @@ import (
 	"io"
 	"bytes"
 	"path"
+	"strings"
+	"sync/atomic"
 )
 
 var msg = bot.Message{ID: 101, Text: "1st"}
@@ -90,3 +92,171 @@ func TestNewLogger(t *testing.T) {
 		require.NoError(t, os.Remove(logfile))
 	})
 }
+
+func TestReporter_Close(t *testing.T) {
+	p := t.TempDir()
+	var calls int32
+	clientMock := &httpClientMock{
+		GetFunc: func(url string) (*http.Response, error) {
+			code := 302
+			if atomic.AddInt32(&calls, 1); strings.HasSuffix(url, "/2?single") {
+				code = 200 // deleted message
+			}
+			return &http.Response{StatusCode: code, Body: io.NopCloser(bytes.NewBuffer([]byte("")))}, nil
+		},
+	}
+
+	reporter := newLogger(p, 100*time.Millisecond, "radio_t_chat", clientMock)
+	for i := 1; i <= 3; i++ {
+		reporter.Save(&bot.Message{ID: i, Text: fmt.Sprintf("msg %d", i)})
+	}
+	reporter.Save(&bot.Message{ID: -42, Text: "edited topic"}) // synthetic id, not probed
+	require.NoError(t, reporter.Close())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	data, err := os.ReadFile(fmt.Sprintf("%s/%s.log", p, time.Now().Format("20060102")))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"Text":"msg 1"`)
+	assert.Contains(t, lines[1], `"Text":"msg 3"`)
+	assert.Contains(t, lines[2], `"Text":"edited topic"`)
+
+	fi, err := os.Stat(path.Join(p, "reporter.journal"))
+	require.NoError(t, err)
+	assert.Zero(t, fi.Size(), "journal cleared after flush")
+
+	reporter.Save(&bot.Message{ID: 4, Text: "after close"})
+	require.NoError(t, reporter.Close())
+}
+
+func TestReporter_Replay(t *testing.T) {
+	p := t.TempDir()
+	at := time.Date(2024, 3, 2, 20, 0, 0, 0, time.Local)
+	j, _, err := openJournal(path.Join(p, "reporter.journal"))
+	require.NoError(t, err)
+	require.NoError(t, j.add(msgEntry{Seq: 1, MessageID: 1, At: at, Data: `{"ID":1,"Text":"written"}` + "\n"}))
+	require.NoError(t, j.add(msgEntry{Seq: 2, MessageID: 2, At: at, Data: `{"ID":2,"Text":"pending"}` + "\n"}))
+	require.NoError(t, j.done(1))
+	require.NoError(t, j.close())
+
+	clientMock := &httpClientMock{
+		GetFunc: func(url string) (*http.Response, error) {
+			assert.Equal(t, "https://t.me/radio_t_chat/2?single", url)
+			return &http.Response{StatusCode: 302, Body: io.NopCloser(bytes.NewBuffer([]byte("")))}, nil
+		},
+	}
+	reporter := newLogger(p, time.Hour, "radio_t_chat", clientMock)
+	require.NoError(t, reporter.Close())
+
+	data, err := os.ReadFile(path.Join(p, "20240302.log"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"ID":2,"Text":"pending"}`+"\n", string(data), "written to the day of the original message")
+	assert.Len(t, clientMock.GetCalls(), 1)
+}
+
+func TestReporter_Delete(t *testing.T) {
+	logLines := func(t *testing.T, p string) []string {
+		data, err := os.ReadFile(fmt.Sprintf("%s/%s.log", p, time.Now().Format("20060102")))
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	t.Run("pending", func(t *testing.T) {
+		p := t.TempDir()
+		reporter := NewLogger(p, 100*time.Millisecond, "")
+		reporter.Save(&bot.Message{ID: 1, Text: "spam"})
+		reporter.Save(&bot.Message{ID: 2, Text: "msg 2"})
+		reporter.Delete(1)
+		require.NoError(t, reporter.Close())
+		lines := logLines(t, p)
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], `"Text":"msg 2"`)
+	})
+
+	t.Run("buffered", func(t *testing.T) {
+		p := t.TempDir()
+		reporter := NewLogger(p, 0, "")
+		reporter.Save(&bot.Message{ID: 1, Text: "msg 1"})
+		reporter.Save(&bot.Message{ID: 2, Text: "spam"})
+		require.Eventually(t, func() bool {
+			reporter.mu.Lock()
+			defer reporter.mu.Unlock()
+			return len(reporter.buffer) == 2
+		}, time.Second, 10*time.Millisecond)
+		reporter.Delete(2)
+		require.NoError(t, reporter.Close())
+		lines := logLines(t, p)
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], `"Text":"msg 1"`)
+	})
+
+	t.Run("written", func(t *testing.T) {
+		p := t.TempDir()
+		reporter := NewLogger(p, 0, "")
+		reporter.Save(&bot.Message{ID: 1, Text: "msg 1"})
+		reporter.Save(&bot.Message{ID: 2, Text: "spam"})
+		reporter.Save(&bot.Message{ID: 3, Text: "msg 3"})
+		require.Eventually(t, func() bool {
+			reporter.flushCh <- struct{}{}
+			data, err := os.ReadFile(fmt.Sprintf("%s/%s.log", p, time.Now().Format("20060102")))
+			return err == nil && strings.Count(string(data), "\n") == 3
+		}, time.Second, 50*time.Millisecond)
+
+		reporter.Delete(2)
+		require.NoError(t, reporter.Close())
+		lines := logLines(t, p)
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"Text":"msg 1"`)
+		assert.Contains(t, lines[1], `"Text":"msg 3"`)
+	})
+
+	t.Run("journal", func(t *testing.T) {
+		p := t.TempDir()
+		j, _, err := openJournal(path.Join(p, "reporter.journal"))
+		require.NoError(t, err)
+		at := time.Date(2024, 3, 2, 20, 0, 0, 0, time.Local)
+		require.NoError(t, j.add(msgEntry{Seq: 1, MessageID: 1, At: at, Data: `{"ID":1,"Text":"spam"}` + "\n"}))
+		require.NoError(t, j.add(msgEntry{Seq: 2, MessageID: 2, At: at, Data: `{"ID":2,"Text":"msg 2"}` + "\n"}))
+		require.NoError(t, j.deleted(1))
+		require.NoError(t, j.close())
+
+		reporter := NewLogger(p, 0, "")
+		require.NoError(t, reporter.Close())
+		data, err := os.ReadFile(path.Join(p, "20240302.log"))
+		require.NoError(t, err)
+		assert.Equal(t, `{"ID":2,"Text":"msg 2"}`+"\n", string(data))
+	})
+}
+
+func TestReporter_WithHistory(t *testing.T) {
+	p := t.TempDir()
+	h, err := NewHistory(path.Join(p, "history.db"))
+	require.NoError(t, err)
+	defer h.Close()
+
+	reporter := NewLogger(p, 0, "", WithHistory(h), WithoutFileLog())
+	ts := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	reporter.Save(&bot.Message{ID: 1, Sent: ts, Text: "msg 1", From: bot.User{Username: "user"}})
+	reporter.Save(&bot.Message{ID: 2, Sent: ts.Add(time.Second), Text: "spam", From: bot.User{Username: "spammer"}})
+	require.Eventually(t, func() bool {
+		reporter.flushCh <- struct{}{}
+		res, e := h.Query(HistoryQuery{})
+		return e == nil && len(res) == 2
+	}, time.Second, 50*time.Millisecond)
+
+	reporter.Delete(2)
+	require.NoError(t, reporter.Close())
+
+	res, err := h.Query(HistoryQuery{})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, "msg 1", res[0].Message.Text)
+	assert.Equal(t, ts, res[0].Message.Sent.UTC())
+
+	files, err := os.ReadDir(p)
+	require.NoError(t, err)
+	for _, f := range files {
+		assert.NotEqual(t, ".log", path.Ext(f.Name()), "no daily log files")
+	}
+}
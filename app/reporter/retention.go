@@ -0,0 +1,274 @@
+package reporter
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// minCompressAfter keeps today and yesterday logs uncompressed, they are appended and cleaned from deleted messages
+const minCompressAfter = 2
+
+// Retention compresses daily logs older than CompressAfter days and deletes or archives logs older than DeleteAfter days
+type Retention struct {
+	RetentionParams
+	nowFn func() time.Time // for testing
+}
+
+// RetentionParams defines retention policy, zero CompressAfter or DeleteAfter disables the step
+type RetentionParams struct {
+	LogsPath      string
+	CompressAfter int    // days to keep log uncompressed, at least 2
+	DeleteAfter   int    // days to keep log, compressed or not
+	ArchivePath   string // move expired logs here instead of deleting, optional
+}
+
+// NewRetention makes retention for daily logs
+func NewRetention(params RetentionParams) *Retention {
+	if params.CompressAfter > 0 && params.CompressAfter < minCompressAfter {
+		log.Printf("[WARN] compress after %d days is too early, using %d", params.CompressAfter, minCompressAfter)
+		params.CompressAfter = minCompressAfter
+	}
+	log.Printf("[INFO] logs retention %+v", params)
+	return &Retention{RetentionParams: params, nowFn: time.Now}
+}
+
+// Run applies retention every interval, blocks until context canceled
+func (r *Retention) Run(ctx context.Context, interval time.Duration) {
+	r.Apply()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Apply()
+		}
+	}
+}
+
+// Apply compresses and expires logs once, errors for single files are logged and skipped
+func (r *Retention) Apply() {
+	entries, err := os.ReadDir(r.LogsPath)
+	if err != nil {
+		log.Printf("[WARN] can't read logs dir %s, %v", r.LogsPath, err)
+		return
+	}
+	now := r.nowFn()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, e := range entries {
+		if e.IsDir() || !logFileRe.MatchString(e.Name()) {
+			continue
+		}
+		day, err := time.ParseInLocation("20060102", e.Name()[:8], now.Location())
+		if err != nil {
+			continue
+		}
+		age := int(today.Sub(day).Hours() / 24)
+		path := filepath.Join(r.LogsPath, e.Name())
+
+		switch {
+		case r.DeleteAfter > 0 && age >= r.DeleteAfter:
+			if err := r.expire(path); err != nil {
+				log.Printf("[WARN] can't expire %s, %v", path, err)
+			}
+		case r.CompressAfter > 0 && age >= r.CompressAfter && !strings.HasSuffix(path, ".gz"):
+			if err := compressLog(path); err != nil {
+				log.Printf("[WARN] can't compress %s, %v", path, err)
+			}
+		}
+	}
+}
+
+// expire moves log to the archive or removes it
+func (r *Retention) expire(path string) error {
+	if r.ArchivePath == "" {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove: %w", err)
+		}
+		log.Printf("[INFO] expired log %s removed", path)
+		return nil
+	}
+
+	if err := os.MkdirAll(r.ArchivePath, 0o750); err != nil {
+		return fmt.Errorf("failed to make archive dir: %w", err)
+	}
+	dest := filepath.Join(r.ArchivePath, filepath.Base(path))
+	if err := os.Rename(path, dest); err != nil {
+		// archive can be on another device, copy and remove
+		if err := copyFile(path, dest); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove archived: %w", err)
+		}
+	}
+	log.Printf("[INFO] expired log %s archived to %s", path, dest)
+	return nil
+}
+
+// compressLog gzips the log to path.gz and removes the original. If path.gz exists already, i.e. the log was written
+// after compression, the log is added to it as a new gzip member, concatenated members read as a single stream.
+func compressLog(path string) error {
+	src, err := os.Open(path) // nolint
+	if err != nil {
+		return fmt.Errorf("failed to open: %w", err)
+	}
+	defer src.Close() // nolint
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o660) // nolint
+	if err != nil {
+		return fmt.Errorf("failed to create: %w", err)
+	}
+	err = appendCompressed(dst, path+".gz")
+	if err == nil {
+		gz := gzip.NewWriter(dst)
+		gz.Name = filepath.Base(path)
+		_, err = io.Copy(gz, src)
+		if e := gz.Close(); err == nil {
+			err = e
+		}
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to compress: %w", err)
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return fmt.Errorf("failed to rename: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove compressed: %w", err)
+	}
+	log.Printf("[INFO] log %s compressed", path)
+	return nil
+}
+
+// appendCompressed copies previously compressed log to w, nothing copied if there is no such log
+func appendCompressed(w io.Writer, gzPath string) error {
+	fh, err := os.Open(gzPath) // nolint
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", gzPath, err)
+	}
+	defer fh.Close() // nolint
+	if _, err = io.Copy(w, fh); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", gzPath, err)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src) // nolint
+	if err != nil {
+		return fmt.Errorf("failed to open: %w", err)
+	}
+	defer in.Close() // nolint
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o660) // nolint
+	if err != nil {
+		return fmt.Errorf("failed to create: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("failed to copy: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close: %w", err)
+	}
+	return nil
+}
+
+// openLog opens daily log. For the log path both compressed path.gz and path are read, in this order,
+// as the log can be written after compression, i.e. by journal replay; error if none of them exists.
+func openLog(path string) (io.ReadCloser, error) {
+	if strings.HasSuffix(path, ".gz") {
+		fh, err := os.Open(path) // nolint
+		if err != nil {
+			return nil, err
+		}
+		return newGzipReadCloser(fh)
+	}
+
+	fh, err := os.Open(path) // nolint
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	gzFh, gzErr := os.Open(path + ".gz") // nolint
+	if gzErr != nil {
+		if err != nil {
+			return nil, err // report the original error
+		}
+		if !errors.Is(gzErr, os.ErrNotExist) {
+			_ = fh.Close()
+			return nil, gzErr
+		}
+		return fh, nil
+	}
+	gz, gzErr := newGzipReadCloser(gzFh)
+	if gzErr != nil {
+		if fh != nil {
+			_ = fh.Close()
+		}
+		return nil, gzErr
+	}
+	if fh == nil {
+		return gz, nil
+	}
+	return &multiReadCloser{Reader: io.MultiReader(gz, fh), closers: []io.Closer{gz, fh}}, nil
+}
+
+// multiReadCloser reads compressed and plain parts of the log one after another, closes all of them
+type multiReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes all parts
+func (m *multiReadCloser) Close() error {
+	var err error
+	for _, c := range m.closers {
+		if e := c.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// gzipReadCloser closes both gzip reader and the underlying file
+type gzipReadCloser struct {
+	*gzip.Reader
+	fh *os.File
+}
+
+func newGzipReadCloser(fh *os.File) (io.ReadCloser, error) {
+	gz, err := gzip.NewReader(fh)
+	if err != nil {
+		_ = fh.Close()
+		return nil, fmt.Errorf("failed to read gzip %s: %w", fh.Name(), err)
+	}
+	return &gzipReadCloser{Reader: gz, fh: fh}, nil
+}
+
+// Close closes gzip reader and the file
+func (g *gzipReadCloser) Close() error {
+	err := g.Reader.Close()
+	if e := g.fh.Close(); err == nil {
+		err = e
+	}
+	return err
+}
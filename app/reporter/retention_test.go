@@ -0,0 +1,154 @@
+package reporter
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+func TestRetention_Apply(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	files := []string{"20240310.log", "20240309.log", "20240307.log", "20240305.log.gz", "20240301.log", "reporter.journal"}
+
+	tbl := []struct {
+		name    string
+		params  RetentionParams
+		logs    []string
+		archive []string
+	}{
+		{"compress only", RetentionParams{CompressAfter: 3},
+			[]string{"20240301.log.gz", "20240305.log.gz", "20240307.log.gz", "20240309.log", "20240310.log", "reporter.journal"}, nil},
+		{"too early compress", RetentionParams{CompressAfter: 1},
+			[]string{"20240301.log.gz", "20240305.log.gz", "20240307.log.gz", "20240309.log", "20240310.log", "reporter.journal"}, nil},
+		{"compress and delete", RetentionParams{CompressAfter: 3, DeleteAfter: 5},
+			[]string{"20240307.log.gz", "20240309.log", "20240310.log", "reporter.journal"}, nil},
+		{"delete to archive", RetentionParams{DeleteAfter: 5, ArchivePath: "archive"},
+			[]string{"20240307.log", "20240309.log", "20240310.log", "archive", "reporter.journal"},
+			[]string{"20240301.log", "20240305.log.gz"}},
+		{"disabled", RetentionParams{}, files, nil},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, f), []byte(`{"ID":1}`+"\n"), 0o600))
+			}
+			tt.params.LogsPath = dir
+			if tt.params.ArchivePath != "" {
+				tt.params.ArchivePath = filepath.Join(dir, tt.params.ArchivePath)
+			}
+			r := NewRetention(tt.params)
+			r.nowFn = func() time.Time { return now }
+			r.Apply()
+
+			assert.Equal(t, sorted(tt.logs), dirFiles(t, dir))
+			if tt.archive != nil {
+				assert.Equal(t, tt.archive, dirFiles(t, tt.params.ArchivePath))
+			}
+		})
+	}
+}
+
+func TestCompressLog(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "20240302.log")
+	require.NoError(t, os.WriteFile(path, []byte("line1\nline2\n"), 0o600))
+	require.NoError(t, compressLog(path))
+
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "original removed")
+
+	fh, err := os.Open(path + ".gz")
+	require.NoError(t, err)
+	defer fh.Close()
+	gz, err := gzip.NewReader(fh)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "line1\nline2\n", string(data))
+	assert.Equal(t, "20240302.log", gz.Name)
+}
+
+func TestCompressLog_LateWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "20240302.log")
+	require.NoError(t, createFile(path, []bot.Message{{ID: 1, Text: "first"}}))
+	require.NoError(t, compressLog(path))
+
+	// journal replay writes the message of the compressed day to the new plain log
+	require.NoError(t, createFile(path, []bot.Message{{ID: 2, Text: "late"}}))
+	texts := func() (res []string) {
+		msgs, err := readMessages(path)
+		require.NoError(t, err)
+		for _, m := range msgs {
+			res = append(res, m.Text)
+		}
+		return res
+	}
+	assert.Equal(t, []string{"first", "late"}, texts(), "both compressed and late logs read")
+
+	require.NoError(t, compressLog(path))
+	assert.Equal(t, []string{"20240302.log.gz"}, dirFiles(t, dir))
+	assert.Equal(t, []string{"first", "late"}, texts(), "late log added to the compressed one")
+}
+
+func TestOpenLog(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "20240301.log")
+	require.NoError(t, os.WriteFile(plain, []byte("plain\n"), 0o600))
+	compressed := filepath.Join(dir, "20240302.log")
+	require.NoError(t, os.WriteFile(compressed, []byte("compressed\n"), 0o600))
+	require.NoError(t, compressLog(compressed))
+
+	tbl := []struct {
+		path string
+		data string
+		fail bool
+	}{
+		{plain, "plain\n", false},
+		{compressed, "compressed\n", false},
+		{compressed + ".gz", "compressed\n", false},
+		{filepath.Join(dir, "20240303.log"), "", true},
+		{filepath.Join(dir, "20240303.log.gz"), "", true},
+	}
+	for _, tt := range tbl {
+		t.Run(filepath.Base(tt.path), func(t *testing.T) {
+			rd, err := openLog(tt.path)
+			if tt.fail {
+				assert.Error(t, err)
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+			require.NoError(t, err)
+			data, err := io.ReadAll(rd)
+			require.NoError(t, err)
+			assert.NoError(t, rd.Close())
+			assert.Equal(t, tt.data, string(data))
+		})
+	}
+}
+
+func dirFiles(t *testing.T, dir string) (res []string) {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		res = append(res, e.Name())
+	}
+	return res
+}
+
+func sorted(s []string) []string {
+	res := append([]string(nil), s...)
+	sort.Strings(res)
+	return res
+}
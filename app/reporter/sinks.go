@@ -0,0 +1,142 @@
+package reporter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+//go:generate moq -out mock_sink.go . Sink
+
+// Sink receives chat messages and deletions of messages
+type Sink interface {
+	Save(msg *bot.Message)
+	Delete(msgID int)
+}
+
+// Sinks sends the message stream to all registered sinks. Each sink has its own buffered queue and goroutine,
+// so a slow or failing sink doesn't block or break others. Events for a full queue are dropped.
+// Sinks which never block, like Reporter, can be added as direct to skip the queue and never lose events.
+type Sinks struct {
+	bufSize int
+
+	mu     sync.RWMutex
+	queues []*sinkQueue
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type sinkQueue struct {
+	name string
+	sink Sink
+	ch   chan sinkEvent // nil for direct sink
+}
+
+// sinkEvent is a saved message or deleted message id
+type sinkEvent struct {
+	msg     *bot.Message
+	deleted int
+}
+
+// NewSinks makes empty sinks registry with queue size for each sink
+func NewSinks(bufSize int) *Sinks {
+	return &Sinks{bufSize: bufSize}
+}
+
+// Add registers sink and starts its queue
+func (s *Sinks) Add(name string, sink Sink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := &sinkQueue{name: name, sink: sink, ch: make(chan sinkEvent, s.bufSize)}
+	s.queues = append(s.queues, q)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for ev := range q.ch {
+			q.handle(ev)
+		}
+	}()
+	log.Printf("[INFO] message sink %q added", name)
+}
+
+// AddDirect registers non-blocking sink called synchronously, without a queue
+func (s *Sinks) AddDirect(name string, sink Sink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queues = append(s.queues, &sinkQueue{name: name, sink: sink})
+	log.Printf("[INFO] direct message sink %q added", name)
+}
+
+// Save passes message to all sinks, never blocks
+func (s *Sinks) Save(msg *bot.Message) {
+	s.send(sinkEvent{msg: msg})
+}
+
+// Delete passes deleted message id to all sinks, never blocks
+func (s *Sinks) Delete(msgID int) {
+	s.send(sinkEvent{deleted: msgID})
+}
+
+// Close stops accepting events, waits for queued events and closes sinks implementing io.Closer
+func (s *Sinks) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	for _, q := range s.queues {
+		if q.ch != nil {
+			close(q.ch)
+		}
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	var errs []error
+	for _, q := range s.queues {
+		if c, ok := q.sink.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("sink %q: %w", q.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Sinks) send(ev sinkEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	for _, q := range s.queues {
+		if q.ch == nil {
+			q.handle(ev)
+			continue
+		}
+		select {
+		case q.ch <- ev:
+		default:
+			log.Printf("[WARN] message sink %q queue is full, event dropped", q.name)
+		}
+	}
+}
+
+// handle passes event to the sink, panic in the sink doesn't stop the queue
+func (q *sinkQueue) handle(ev sinkEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[WARN] message sink %q panicked, %v", q.name, r)
+		}
+	}()
+	if ev.msg != nil {
+		q.sink.Save(ev.msg)
+		return
+	}
+	q.sink.Delete(ev.deleted)
+}
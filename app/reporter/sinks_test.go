@@ -0,0 +1,80 @@
+package reporter
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+type closableSink struct {
+	*SinkMock
+	closed int32
+}
+
+func (c *closableSink) Close() error {
+	atomic.AddInt32(&c.closed, 1)
+	return errors.New("close failed")
+}
+
+func TestSinks(t *testing.T) {
+	good := &closableSink{SinkMock: &SinkMock{SaveFunc: func(msg *bot.Message) {}, DeleteFunc: func(msgID int) {}}}
+	panicky := &SinkMock{
+		SaveFunc:   func(msg *bot.Message) { panic("oops") },
+		DeleteFunc: func(msgID int) {},
+	}
+	release := make(chan struct{})
+	slow := &SinkMock{
+		SaveFunc:   func(msg *bot.Message) { <-release },
+		DeleteFunc: func(msgID int) {},
+	}
+
+	direct := &SinkMock{SaveFunc: func(msg *bot.Message) {}, DeleteFunc: func(msgID int) { panic("oops") }}
+
+	sinks := NewSinks(10)
+	sinks.AddDirect("direct", direct)
+	sinks.Add("good", good)
+	sinks.Add("panicky", panicky)
+	sinks.Add("slow", slow)
+
+	for i := 1; i <= 5; i++ {
+		sinks.Save(&bot.Message{ID: i, Text: "msg"})
+	}
+	sinks.Delete(3)
+	assert.Len(t, direct.SaveCalls(), 5, "direct sink called synchronously")
+	assert.Len(t, direct.DeleteCalls(), 1, "panic in direct sink isolated")
+	require.Eventually(t, func() bool { return len(good.DeleteCalls()) == 1 }, time.Second, 10*time.Millisecond,
+		"slow sink doesn't block others")
+	assert.Len(t, good.SaveCalls(), 5)
+	assert.Equal(t, 3, good.DeleteCalls()[0].MsgID)
+	assert.Len(t, panicky.SaveCalls(), 5, "panic doesn't stop the queue")
+	assert.Len(t, panicky.DeleteCalls(), 1)
+
+	close(release)
+	err := sinks.Close()
+	assert.EqualError(t, err, `sink "good": close failed`)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&good.closed))
+	assert.Len(t, slow.SaveCalls(), 5, "queued events delivered on close")
+
+	sinks.Save(&bot.Message{ID: 6, Text: "after close"})
+	assert.Len(t, good.SaveCalls(), 5)
+	assert.NoError(t, sinks.Close())
+}
+
+func TestSinks_FullQueue(t *testing.T) {
+	release := make(chan struct{})
+	slow := &SinkMock{SaveFunc: func(msg *bot.Message) { <-release }}
+	sinks := NewSinks(1)
+	sinks.Add("slow", slow)
+	for i := 1; i <= 5; i++ {
+		sinks.Save(&bot.Message{ID: i, Text: "msg"})
+	}
+	close(release)
+	require.NoError(t, sinks.Close())
+	assert.Less(t, len(slow.SaveCalls()), 5, "events for full queue dropped")
+}
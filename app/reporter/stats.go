@@ -0,0 +1,308 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// statsTop is the max number of entries in the top lists of stats
+const statsTop = 10
+
+// banMarkers are parts of bot messages about bans, from wtf and banhammer bots
+var banMarkers = []string{"получает бан на", "прощай @"}
+
+// ShowStats is a chat statistics of the show
+type ShowStats struct {
+	Messages     int              // chat messages, without reactions
+	Participants []StatsCount     // messages per participant, most active first, bot excluded
+	Timeline     []StatsTimePoint // messages per minute, from the first to the last message
+	Domains      []StatsCount     // most shared link domains
+	Reacted      []ReactedMessage // messages with most +1/-1 replies
+	Commands     []StatsCount     // bot commands by number of uses
+	Bans         int              // bans announced by the bot
+}
+
+// StatsCount is a named counter
+type StatsCount struct {
+	Name  string
+	Count int
+}
+
+// StatsTimePoint is a number of messages in the minute
+type StatsTimePoint struct {
+	Time  time.Time
+	Label string // local time, 15:04
+	Count int
+}
+
+// ReactedMessage is a message with reactions, reactions are +1 and -1 replies as telegram reactions are not logged
+type ReactedMessage struct {
+	Msg    bot.Message
+	Anchor string // html anchor of the message
+	Up     int
+	Down   int
+}
+
+// Stats calculates chat statistics of the export window
+func (e *Exporter) Stats(window ExportWindow) (ShowStats, error) {
+	messages, _, err := e.readWindow(window)
+	if err != nil {
+		return ShowStats{}, err
+	}
+	return calcStats(e.Redaction.Apply(messages), e.BotUsername, e.BotCommands, e.Location), nil
+}
+
+// calcStats makes statistics of messages, reactions are still in messages. Commands are counted
+// from bot_command entities and from messages answered by the bot starting with one of botCommands.
+func calcStats(messages []bot.Message, botUsername string, botCommands []string, location *time.Location) ShowStats {
+	res := ShowStats{}
+	participants := counter{}
+	domains := counter{}
+	commands := counter{}
+	perMinute := map[int64]int{}
+	byKey := map[string]int{}
+	reactions := map[int]*ReactedMessage{}
+	var first, last time.Time
+
+	for i, msg := range messages {
+		byKey[messageKey(msg.ID, msg.From, msg.Sent)] = i
+		byKey[messageKey(0, msg.From, msg.Sent)] = i
+		if filter(msg) {
+			if parent, ok := byKey[messageKey(msg.ReplyTo.ID, msg.ReplyTo.From, msg.ReplyTo.Sent)]; ok {
+				r, found := reactions[parent]
+				if !found {
+					r = &ReactedMessage{Msg: messages[parent], Anchor: fmt.Sprintf("msg-%d", messages[parent].ID)}
+					reactions[parent] = r
+				}
+				if strings.Contains(msg.Text, "-") {
+					r.Down++
+				} else {
+					r.Up++
+				}
+			}
+			continue
+		}
+
+		res.Messages++
+		if first.IsZero() || msg.Sent.Before(first) {
+			first = msg.Sent
+		}
+		if msg.Sent.After(last) {
+			last = msg.Sent
+		}
+		perMinute[msg.Sent.Unix()/60]++
+
+		for _, d := range linkDomains(msg) {
+			domains.add(d)
+		}
+
+		if isBot(msg.From, botUsername) {
+			for _, m := range banMarkers {
+				if strings.Contains(msg.Text, m) {
+					res.Bans++
+					break
+				}
+			}
+			// bot answered the message, it may start with a command
+			if parent, ok := byKey[messageKey(msg.ReplyTo.ID, msg.ReplyTo.From, msg.ReplyTo.Sent)]; ok {
+				if c := commandKey(messages[parent].Text, botCommands); c != "" && !hasCommandEntity(messages[parent]) {
+					commands.add(c)
+				}
+			}
+			continue
+		}
+
+		participants.add(participantName(msg.From))
+		for _, c := range entityTexts(msg.Text, msg.Entities, "bot_command") {
+			c, _, _ = strings.Cut(c, "@") // "/ping@bot" is "/ping"
+			commands.add(strings.ToLower(c))
+		}
+	}
+
+	res.Participants = participants.top(statsTop)
+	res.Domains = domains.top(statsTop)
+	res.Commands = commands.top(statsTop)
+	res.Reacted = topReacted(reactions, statsTop)
+
+	if res.Messages > 0 {
+		for minute := first.Unix() / 60; minute <= last.Unix()/60; minute++ {
+			ts := time.Unix(minute*60, 0).In(location)
+			res.Timeline = append(res.Timeline, StatsTimePoint{Time: ts, Label: ts.Format("15:04"), Count: perMinute[minute]})
+		}
+	}
+	return res
+}
+
+// WriteText writes stats as plain text
+func (s ShowStats) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "сообщений: %d, банов: %d\n", s.Messages, s.Bans)
+	writeCounts := func(title string, counts []StatsCount) {
+		if len(counts) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for i, c := range counts {
+			fmt.Fprintf(&b, "%2d. %s - %d\n", i+1, c.Name, c.Count)
+		}
+	}
+	writeCounts("самые активные слушатели", s.Participants)
+	writeCounts("домены", s.Domains)
+	writeCounts("команды", s.Commands)
+	if len(s.Reacted) > 0 {
+		fmt.Fprintf(&b, "\nреакции:\n")
+		for i, r := range s.Reacted {
+			text := []rune(strings.Join(strings.Fields(r.Msg.Text), " "))
+			if len(text) > maxReplySnippetLen {
+				text = append(text[:maxReplySnippetLen], '…')
+			}
+			fmt.Fprintf(&b, "%2d. +%d/-%d %s: %s\n", i+1, r.Up, r.Down, r.Msg.From.Username, string(text))
+		}
+	}
+	if len(s.Timeline) > 0 {
+		peak := s.Timeline[0]
+		for _, p := range s.Timeline {
+			if p.Count > peak.Count {
+				peak = p
+			}
+		}
+		fmt.Fprintf(&b, "\nпик: %d сообщений в %s, %s - %s\n", peak.Count, peak.Label,
+			s.Timeline[0].Label, s.Timeline[len(s.Timeline)-1].Label)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// messageKey identifies message by id, or by author and time with zero id for replies logged without reply id
+func messageKey(id int, from bot.User, sent time.Time) string {
+	if id != 0 {
+		return fmt.Sprintf("id:%d", id)
+	}
+	return fmt.Sprintf("at:%d:%s:%d", from.ID, from.Username, sent.Unix())
+}
+
+// participantName is username, or display name for users without username
+func participantName(u bot.User) string {
+	if u.Username != "" {
+		return u.Username
+	}
+	return strings.TrimSpace(u.DisplayName)
+}
+
+// messageLinks returns links of the message and image caption, from url and text_link entities
+func messageLinks(msg bot.Message) []string {
+	links := append(entityTexts(msg.Text, msg.Entities, "url"), entityURLs(msg.Entities)...)
+	if msg.Image != nil {
+		links = append(links, entityTexts(msg.Image.Caption, msg.Image.Entities, "url")...)
+		links = append(links, entityURLs(msg.Image.Entities)...)
+	}
+	return links
+}
+
+// linkDomains returns domains of links in the message and image caption
+func linkDomains(msg bot.Message) (res []string) {
+	for _, link := range messageLinks(msg) {
+		if !strings.Contains(link, "://") {
+			link = "https://" + link
+		}
+		u, err := url.Parse(link)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		res = append(res, strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."))
+	}
+	return res
+}
+
+// entityTexts returns texts of entities of the type, offsets are in utf-16 code units
+func entityTexts(text string, entities *[]bot.Entity, entityType string) (res []string) {
+	if entities == nil {
+		return nil
+	}
+	units := utf16.Encode([]rune(text))
+	for _, e := range *entities {
+		if e.Type != entityType || e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > len(units) {
+			continue
+		}
+		res = append(res, string(utf16.Decode(units[e.Offset:e.Offset+e.Length])))
+	}
+	return res
+}
+
+func entityURLs(entities *[]bot.Entity) (res []string) {
+	if entities == nil {
+		return nil
+	}
+	for _, e := range *entities {
+		if e.Type == "text_link" && e.URL != "" {
+			res = append(res, e.URL)
+		}
+	}
+	return res
+}
+
+// commandKey returns the longest of keys the text starts with, case-insensitive, empty if none
+func commandKey(text string, keys []string) (res string) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	for _, k := range keys {
+		k = strings.ToLower(k)
+		if k != "" && strings.HasPrefix(text, k) && len(k) > len(res) {
+			res = k
+		}
+	}
+	return res
+}
+
+func hasCommandEntity(msg bot.Message) bool {
+	return len(entityTexts(msg.Text, msg.Entities, "bot_command")) > 0
+}
+
+func topReacted(reactions map[int]*ReactedMessage, limit int) []ReactedMessage {
+	res := make([]ReactedMessage, 0, len(reactions))
+	for _, r := range reactions {
+		res = append(res, *r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Up+res[i].Down != res[j].Up+res[j].Down {
+			return res[i].Up+res[i].Down > res[j].Up+res[j].Down
+		}
+		return res[i].Msg.Sent.Before(res[j].Msg.Sent)
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+// counter counts names, top returns the most frequent ones, ties ordered by name
+type counter map[string]int
+
+func (c counter) add(name string) {
+	if name != "" {
+		c[name]++
+	}
+}
+
+func (c counter) top(limit int) []StatsCount {
+	res := make([]StatsCount, 0, len(c))
+	for name, count := range c {
+		res = append(res, StatsCount{Name: name, Count: count})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Name < res[j].Name
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
@@ -0,0 +1,88 @@
+package reporter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+func Test_calcStats(t *testing.T) {
+	ts := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	user1 := bot.User{ID: 1, Username: "user1"}
+	user2 := bot.User{ID: 2, Username: "user2"}
+	rtBot := bot.User{ID: 3, Username: "rtbot"}
+	msg := func(id int, from bot.User, d time.Duration, text string, entities ...bot.Entity) bot.Message {
+		m := bot.Message{ID: id, From: from, Sent: ts.Add(d), Text: text}
+		if len(entities) > 0 {
+			m.Entities = &entities
+		}
+		return m
+	}
+	replyTo := func(m, to bot.Message, withID bool) bot.Message {
+		m.ReplyTo.From, m.ReplyTo.Sent, m.ReplyTo.Text = to.From, to.Sent, to.Text
+		if withID {
+			m.ReplyTo.ID = to.ID
+		}
+		return m
+	}
+
+	link := msg(1, user1, 0, "👍 https://www.Example.com/a и github.com",
+		bot.Entity{Type: "url", Offset: 3, Length: 25}, bot.Entity{Type: "url", Offset: 31, Length: 10})
+	question := msg(2, user2, 30*time.Second, "когда? скоро")
+	messages := []bot.Message{
+		link,
+		question,
+		replyTo(msg(3, rtBot, 40*time.Second, "через час"), question, true),
+		msg(4, user1, 2*time.Minute, "/ping@rtbot", bot.Entity{Type: "bot_command", Offset: 0, Length: 11}),
+		msg(5, user1, 2*time.Minute, "ссылка", bot.Entity{Type: "text_link", Offset: 0, Length: 6, URL: "https://example.com/b"}),
+		replyTo(msg(6, user2, 3*time.Minute, "+1"), link, true),
+		replyTo(msg(7, rtBot, 3*time.Minute, ":+1:"), link, false),
+		replyTo(msg(8, user1, 3*time.Minute, "-1"), question, true),
+		msg(9, rtBot, 3*time.Minute, "Some User получает бан на 2m3s"),
+	}
+
+	stats := calcStats(messages, "rtbot", []string{"когда?", "when?"}, time.UTC)
+	assert.Equal(t, 6, stats.Messages)
+	assert.Equal(t, 1, stats.Bans)
+	assert.Equal(t, []StatsCount{{"user1", 3}, {"user2", 1}}, stats.Participants)
+	assert.Equal(t, []StatsCount{{"example.com", 2}, {"github.com", 1}}, stats.Domains)
+	assert.Equal(t, []StatsCount{{"/ping", 1}, {"когда?", 1}}, stats.Commands)
+	require.Len(t, stats.Reacted, 2)
+	assert.Equal(t, 1, stats.Reacted[0].Msg.ID)
+	assert.Equal(t, "msg-1", stats.Reacted[0].Anchor)
+	assert.Equal(t, 2, stats.Reacted[0].Up)
+	assert.Equal(t, 2, stats.Reacted[1].Msg.ID)
+	assert.Equal(t, 1, stats.Reacted[1].Down)
+	assert.Equal(t, []StatsTimePoint{
+		{Time: ts, Label: "20:00", Count: 3},
+		{Time: ts.Add(time.Minute), Label: "20:01", Count: 0},
+		{Time: ts.Add(2 * time.Minute), Label: "20:02", Count: 2},
+		{Time: ts.Add(3 * time.Minute), Label: "20:03", Count: 1},
+	}, stats.Timeline)
+
+	var buf bytes.Buffer
+	require.NoError(t, stats.WriteText(&buf))
+	assert.Equal(t, "сообщений: 6, банов: 1\n\n"+
+		"самые активные слушатели:\n 1. user1 - 3\n 2. user2 - 1\n\n"+
+		"домены:\n 1. example.com - 2\n 2. github.com - 1\n\n"+
+		"команды:\n 1. /ping - 1\n 2. когда? - 1\n\n"+
+		"реакции:\n 1. +2/-0 user1: 👍 https://www.Example.com/a и github.com\n 2. +0/-1 user2: когда? скоро\n\n"+
+		"пик: 3 сообщений в 20:00, 20:00 - 20:03\n", buf.String())
+
+	// answered by the bot messages counted only if started with the bot command
+	chat := msg(10, user2, 4*time.Minute, "бот, привет")
+	timeQuestion := msg(11, user2, 5*time.Minute, "Который час? в Москве")
+	answered := []bot.Message{chat, replyTo(msg(12, rtBot, 4*time.Minute, "привет"), chat, true),
+		timeQuestion, replyTo(msg(13, rtBot, 5*time.Minute, "20:05"), timeQuestion, true)}
+	assert.Equal(t, []StatsCount{{"который час?", 1}},
+		calcStats(answered, "rtbot", []string{"когда?", "который", "который час?"}, time.UTC).Commands)
+	assert.Equal(t, []StatsCount{}, calcStats(answered, "rtbot", nil, time.UTC).Commands)
+
+	assert.Equal(t, ShowStats{Participants: []StatsCount{}, Domains: []StatsCount{}, Commands: []StatsCount{},
+		Reacted: []ReactedMessage{}}, calcStats(nil, "rtbot", nil, time.UTC))
+}
@@ -0,0 +1,104 @@
+package reporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// ChatSummarizer makes a short summary of the chat transcript and the list of its main discussion threads,
+// i.e. openai.OpenAI
+type ChatSummarizer interface {
+	ChatSummary(lines []string) (summary string, threads []string, err error)
+}
+
+// ChatSummary is what the listeners talked about in the chat during the show
+type ChatSummary struct {
+	Text    string   `json:"summary"`
+	Threads []string `json:"threads"`
+	Hash    string   `json:"hash"` // hash of the summarized transcript, the summary made again if it changed
+}
+
+// chatSummary returns summary of the show chat, cached in the output root per show, so re-export doesn't ask
+// the summarizer again while the transcript is the same. Nil if there is no summary and it can't be made.
+func (e *Exporter) chatSummary(num int, messages []bot.Message) *ChatSummary {
+	lines := e.transcript(messages)
+	if len(lines) == 0 {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	hash := hex.EncodeToString(sum[:])
+
+	file := filepath.Join(e.OutputRoot, fmt.Sprintf("radio-t-%d.summary.json", num))
+	cached, err := readChatSummary(file)
+	if err != nil {
+		log.Printf("[WARN] %v", err)
+	}
+	if cached != nil && cached.Hash == hash {
+		return cached
+	}
+	if e.Summarizer == nil || e.Offline {
+		return nil
+	}
+
+	text, threads, err := e.Summarizer.ChatSummary(lines)
+	if err != nil {
+		log.Printf("[WARN] can't make chat summary of #%d, %v", num, err)
+		return nil
+	}
+	res := &ChatSummary{Text: text, Threads: threads, Hash: hash}
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		log.Printf("[WARN] failed to marshal chat summary of #%d, %v", num, err)
+		return res
+	}
+	if err = os.WriteFile(file, data, 0o644); err != nil { // nolint
+		log.Printf("[WARN] failed to write %s, %v", file, err)
+	}
+	log.Printf("[INFO] chat summary of #%d made from %d lines", num, len(lines))
+	return res
+}
+
+// readChatSummary reads cached summary, nil if there is no cache yet
+func readChatSummary(file string) (*ChatSummary, error) {
+	data, err := os.ReadFile(file) // nolint
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chat summary %s: %w", file, err)
+	}
+	res := ChatSummary{}
+	if err = json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("failed to parse chat summary %s: %w", file, err)
+	}
+	return &res, nil
+}
+
+// transcript makes lines "15:04 user: text" of chat messages, bot messages and votes skipped
+func (e *Exporter) transcript(messages []bot.Message) []string {
+	res := []string{}
+	for _, msg := range messages {
+		if filter(msg) || isBot(msg.From, e.BotUsername) {
+			continue
+		}
+		text := msg.Text
+		if msg.Image != nil {
+			text = strings.TrimSpace("[image] " + msg.Image.Caption + " " + text)
+		}
+		text = strings.Join(strings.Fields(text), " ")
+		if text == "" {
+			continue
+		}
+		res = append(res, fmt.Sprintf("%s %s: %s", msg.Sent.In(e.Location).Format("15:04"), participantName(msg.From), text))
+	}
+	return res
+}
@@ -0,0 +1,92 @@
+package reporter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// summarizerMock records transcripts and returns the same summary, fails if err set
+type summarizerMock struct {
+	calls [][]string
+	err   error
+}
+
+func (m *summarizerMock) ChatSummary(lines []string) (summary string, threads []string, err error) {
+	m.calls = append(m.calls, lines)
+	if m.err != nil {
+		return "", nil, m.err
+	}
+	return "обсуждали go", []string{"дженерики", "итераторы"}, nil
+}
+
+func TestExporter_chatSummary(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	messages := []bot.Message{
+		{Sent: ts, From: bot.User{Username: "user1"}, Text: "что нового\n  в go?"},
+		{Sent: ts.Add(time.Minute), From: bot.User{Username: "rtbot"}, Text: "ответ бота"},
+		{Sent: ts.Add(2 * time.Minute), From: bot.User{DisplayName: "User Two"}, Text: "+1"},
+		{Sent: ts.Add(3 * time.Minute), From: bot.User{DisplayName: "User Two"}, Image: &bot.Image{FileID: "img", Caption: "вот"}},
+		{Sent: ts.Add(4 * time.Minute), From: bot.User{Username: "user1"}, Text: " "},
+	}
+	summarizer := &summarizerMock{}
+	e := NewExporter(nil, nil, ExporterParams{OutputRoot: dir, BotUsername: "rtbot", Summarizer: summarizer,
+		Location: time.FixedZone("MSK", 3*3600)})
+
+	res := e.chatSummary(900, messages)
+	require.NotNil(t, res)
+	assert.Equal(t, "обсуждали go", res.Text)
+	assert.Equal(t, []string{"дженерики", "итераторы"}, res.Threads)
+	assert.Equal(t, [][]string{{"23:00 user1: что нового в go?", "23:03 User Two: [image] вот"}}, summarizer.calls)
+	assert.FileExists(t, filepath.Join(dir, "radio-t-900.summary.json"))
+
+	assert.Equal(t, res, e.chatSummary(900, messages))
+	assert.Len(t, summarizer.calls, 1, "cached summary used")
+
+	e.Offline = true
+	assert.Equal(t, res, e.chatSummary(900, messages), "cached summary used offline")
+	assert.Nil(t, e.chatSummary(900, messages[:1]), "transcript changed, no summary offline")
+	e.Offline = false
+
+	assert.NotEqual(t, res.Hash, e.chatSummary(900, messages[:1]).Hash, "transcript changed, summary made again")
+	assert.Len(t, summarizer.calls, 2)
+
+	assert.Nil(t, e.chatSummary(901, messages[1:3]), "nothing to summarize")
+	assert.Len(t, summarizer.calls, 2)
+
+	summarizer.err = errors.New("too many requests")
+	assert.Nil(t, e.chatSummary(902, messages))
+	assert.NoFileExists(t, filepath.Join(dir, "radio-t-902.summary.json"), "failed summary not cached")
+
+	e.Summarizer = nil
+	assert.Nil(t, e.chatSummary(902, messages))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "radio-t-903.summary.json"), []byte("bad"), 0o600))
+	e.Summarizer = &summarizerMock{}
+	assert.NotNil(t, e.chatSummary(903, messages), "broken cache replaced")
+}
+
+func TestExporter_ExportSummary(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	require.NoError(t, createFile(filepath.Join(dir, "20240302.log"), []bot.Message{
+		{ID: 1, Sent: ts, Text: "что нового в go?", From: bot.User{Username: "user"}}}))
+
+	e := NewExporter(nil, nil, ExporterParams{InputRoot: dir, OutputRoot: dir, TemplateFile: "../../data/logs.html",
+		SuperUsers: SuperUserMock{}, Summarizer: &summarizerMock{}})
+	_, err := e.Export(900, ExportWindow{Day: 20240302})
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "radio-t-900.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<h4>О чём говорили в чате</h4>
+            <p>обсуждали go</p>
+            <ul><li>дженерики</li><li>итераторы</li></ul>`)
+}
@@ -0,0 +1,62 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+// Webhook sink posts messages and deletions as json to the url, i.e. for the live chat widget on the site
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// webhookEvent is a body of webhook request, Message for "message" event and ID for "delete" event
+type webhookEvent struct {
+	Event   string       `json:"event"`
+	Message *bot.Message `json:"message,omitempty"`
+	ID      int          `json:"id,omitempty"`
+}
+
+// NewWebhook makes webhook sink
+func NewWebhook(url string, client *http.Client) *Webhook {
+	log.Printf("[INFO] webhook sink %s", url)
+	return &Webhook{url: url, client: client}
+}
+
+// Save posts message event
+func (w *Webhook) Save(msg *bot.Message) {
+	if err := w.post(webhookEvent{Event: "message", Message: msg}); err != nil {
+		log.Printf("[WARN] webhook failed for message %d, %v", msg.ID, err)
+	}
+}
+
+// Delete posts delete event
+func (w *Webhook) Delete(msgID int) {
+	if err := w.post(webhookEvent{Event: "delete", ID: msgID}); err != nil {
+		log.Printf("[WARN] webhook failed for deleted message %d, %v", msgID, err)
+	}
+}
+
+func (w *Webhook) post(ev webhookEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to post: %w", err)
+	}
+	defer resp.Body.Close() // nolint
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
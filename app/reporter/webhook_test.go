@@ -0,0 +1,60 @@
+package reporter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/super-bot/app/bot"
+)
+
+func TestWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	wh := NewWebhook(ts.URL, &http.Client{Timeout: time.Second})
+	wh.Save(&bot.Message{ID: 1, Text: "hello", From: bot.User{Username: "user"}})
+	wh.Delete(1)
+
+	require.Len(t, bodies, 2)
+	assert.Contains(t, bodies[0], `{"event":"message","message":{"ID":1,"From":{"ID":0,"Username":"user"`)
+	assert.Equal(t, `{"event":"delete","id":1}`, bodies[1])
+
+	// failed requests don't panic
+	NewWebhook("http://127.0.0.1:1/none", &http.Client{Timeout: 100 * time.Millisecond}).Save(&bot.Message{ID: 2})
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.Save(&bot.Message{Text: "text"})
+	m.Save(&bot.Message{Text: "text"})
+	m.Save(&bot.Message{Image: &bot.Image{FileID: "f"}})
+	m.Delete(1)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	assert.Equal(t, `# HELP superbot_messages_total Chat messages seen by the bot.
+# TYPE superbot_messages_total counter
+superbot_messages_total{type="image"} 1
+superbot_messages_total{type="text"} 2
+# HELP superbot_deleted_messages_total Chat messages deleted by the bot.
+# TYPE superbot_deleted_messages_total counter
+superbot_deleted_messages_total 1
+`, rec.Body.String())
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 implements Storage interface with S3-compatible object storage (aws, minio, etc.),
+// objects are addressed in path style, i.e. https://s3.example.com/bucket/prefix/file
+type S3 struct {
+	S3Params
+	nowFn func() time.Time // for testing
+}
+
+// S3Params defines location of objects and credentials
+type S3Params struct {
+	Endpoint  string // i.e. https://s3.amazonaws.com or http://minio:9000
+	Region    string // us-east-1 by default
+	Bucket    string
+	Prefix    string // key prefix of all files, i.e. logs/900
+	AccessKey string
+	SecretKey string
+	PublicURL string // base url of public links, endpoint/bucket by default
+	ACL       string // canned acl of created objects, i.e. public-read, bucket default if empty
+	Client    *http.Client
+}
+
+// NewS3 creates new S3 storage
+func NewS3(params S3Params) (*S3, error) {
+	u, err := url.Parse(params.Endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", params.Endpoint)
+	}
+	if params.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket not set")
+	}
+	params.Endpoint = strings.TrimSuffix(params.Endpoint, "/")
+	params.Prefix = strings.Trim(params.Prefix, "/")
+	if params.Region == "" {
+		params.Region = "us-east-1"
+	}
+	if params.PublicURL == "" {
+		params.PublicURL = params.Endpoint + "/" + params.Bucket
+	}
+	params.PublicURL = strings.TrimSuffix(params.PublicURL, "/")
+	if params.Client == nil {
+		params.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &S3{S3Params: params, nowFn: time.Now}, nil
+}
+
+// FileExists checks if object with the file name exists in the bucket
+func (s *S3) FileExists(fileName string) (bool, error) {
+	resp, err := s.do(http.MethodHead, fileName, nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if file %s exists: %w", fileName, err)
+	}
+	defer resp.Body.Close() // nolint
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to check if file %s exists: status %d", fileName, resp.StatusCode)
+}
+
+// CreateFile uploads object with a given name and body, returns public link to it
+func (s *S3) CreateFile(fileName string, body []byte) (string, error) {
+	headers := map[string]string{"Content-Type": http.DetectContentType(body)}
+	if s.ACL != "" {
+		headers["X-Amz-Acl"] = s.ACL
+	}
+	resp, err := s.do(http.MethodPut, fileName, body, headers)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file %s: %w", fileName, err)
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("failed to upload file %s: status %d, %s", fileName, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return s.BuildLink(fileName), nil
+}
+
+// BuildLink builds public-accessible link to file
+func (s *S3) BuildLink(fileName string) string {
+	return s.PublicURL + "/" + uriEncode(s.key(fileName), false)
+}
+
+// BuildPath builds s3 url of the file, i.e. s3://bucket/prefix/file
+func (s *S3) BuildPath(fileName string) string {
+	return "s3://" + s.Bucket + "/" + s.key(fileName)
+}
+
+func (s *S3) key(fileName string) string {
+	if s.Prefix == "" {
+		return fileName
+	}
+	return s.Prefix + "/" + fileName
+}
+
+func (s *S3) do(method, fileName string, body []byte, headers map[string]string) (*http.Response, error) {
+	reqURL := s.Endpoint + "/" + uriEncode(s.Bucket+"/"+s.key(fileName), false)
+	req, err := http.NewRequest(method, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request %s: %w", reqURL, err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	signV4(req, body, s.AccessKey, s.SecretKey, s.Region, s.nowFn())
+	return s.Client.Do(req)
+}
+
+// signV4 signs request with aws signature version 4, all set headers and host are signed
+func signV4(req *http.Request, body []byte, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate, day := now.Format("20060102T150405Z"), now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// path encoded as aws requires, go's escaping keeps sub-delims like "$" as is
+	canonicalRequest := strings.Join([]string{req.Method, uriEncode(req.URL.Path, false), canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode encodes all but unreserved characters as aws requires, slash kept in paths
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data)) // nolint
+	return h.Sum(nil)
+}
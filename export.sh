@@ -1,3 +1,3 @@
 #!/bin/sh
 echo "export log for $1"
-docker exec -i telegram-bot /srv/telegram-rt-bot --super=Umputun --super=bobuk --super=ksenks --super=grayru --dbg --export-num=$1 --export-path=/srv/html
+docker exec -i telegram-bot /srv/telegram-rt-bot export --super=Umputun --super=bobuk --super=ksenks --super=grayru --dbg --shows=$1 --export-path=/srv/html